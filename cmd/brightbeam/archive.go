@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"propertytreeanalyzer/pkg/streams"
+)
+
+// archives keeps the opened archives by path, the members of an archive share one copy
+var archives = map[string]*streams.Archive{}
+
+// archiveSources keeps the inputs of the opened archives, members are read from them until the program ends
+var archiveSources []io.Closer
+
+// openArchive opens the zip or tar archive at path, a file, URL or stdin.
+// Archives which are not regular files are buffered like other random access inputs.
+func openArchive(ctx context.Context, path string) (*streams.Archive, error) {
+	if archive, ok := archives[path]; ok {
+		return archive, nil
+	}
+	source, err := open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	archiveSources = append(archiveSources, source)
+	file, size, err := randomAccess(path, source)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := streams.OpenArchive(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sourceName(path), err)
+	}
+	archives[path] = archive
+	return archive, nil
+}
+
+// closeArchives closes the inputs of the archives opened by openArchive
+func closeArchives() {
+	for _, source := range archiveSources {
+		source.Close()
+	}
+}
+
+// expandMembers resolves "archive!/pattern" paths. The archive part may be a glob pattern
+// matching several archives, the member part is matched against the members of each of them.
+func expandMembers(ctx context.Context, archivePattern, memberPattern string) ([]string, error) {
+	archivePaths, err := expandPaths(ctx, []string{archivePattern})
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, archivePath := range archivePaths {
+		if !strings.ContainsAny(memberPattern, "*?[") {
+			// the member is looked up when it is opened
+			paths = append(paths, archivePath+streams.ArchiveSeparator+memberPattern)
+			continue
+		}
+		archive, err := openArchive(ctx, archivePath)
+		if err != nil {
+			return nil, err
+		}
+		members, err := archive.Glob(memberPattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sourceName(archivePath), err)
+		}
+		for _, member := range members {
+			paths = append(paths, archivePath+streams.ArchiveSeparator+member)
+		}
+	}
+	return paths, nil
+}
+
+// expandTreesPath resolves a glob pattern inside an archive given for the trees file,
+// it has to match exactly one member
+func expandTreesPath(ctx context.Context, path string) (string, error) {
+	archivePattern, memberPattern, ok := streams.SplitArchivePath(path)
+	if !ok {
+		return path, nil
+	}
+	paths, err := expandMembers(ctx, archivePattern, memberPattern)
+	if err != nil {
+		return "", err
+	}
+	if len(paths) != 1 {
+		return "", fmt.Errorf("trees pattern %q matches %d files, want one", path, len(paths))
+	}
+	return paths[0], nil
+}
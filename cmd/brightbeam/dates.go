@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	"propertytreeanalyzer/pkg/csvparser"
+)
+
+// filterDates reports whether --from or --to restrict the sale dates
+func filterDates() bool {
+	return dateFrom != "" || dateTo != ""
+}
+
+// dateOptions reads the sale dates from --date-column with the --date-layout layouts when the
+// header has the column, and keeps the ones within --from and --to when they are given.
+// Filtering needs the column.
+func dateOptions(header []string) ([]csvparser.PriceParserOption, error) {
+	if !filterDates() && !slices.ContainsFunc(header, func(col string) bool {
+		return strings.EqualFold(strings.TrimSpace(col), strings.TrimSpace(dateColumn))
+	}) {
+		return nil, nil
+	}
+	opts := []csvparser.PriceParserOption{
+		csvparser.WithDateColumn(dateColumn),
+		csvparser.WithDateLayouts(dateLayouts...),
+	}
+	if !filterDates() {
+		return opts, nil
+	}
+	from, err := parseDateBound(dateFrom, false)
+	if err != nil {
+		return nil, fmt.Errorf("--from: %w", err)
+	}
+	to, err := parseDateBound(dateTo, true)
+	if err != nil {
+		return nil, fmt.Errorf("--to: %w", err)
+	}
+	return append(opts, csvparser.WithDateRange(from, to)), nil
+}
+
+// dateBoundLayouts are the layouts of --from and --to, a year, a month or a day
+var dateBoundLayouts = []string{"2006", "2006-01", "2006-01-02"}
+
+// parseDateBound reads a --from or --to date, empty for an open bound. A year or a month
+// starts on its first day for --from and ends on its last day for --to, so --from 2019
+// --to 2023 covers both years.
+func parseDateBound(text string, end bool) (attr.Date, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return attr.Date{}, nil
+	}
+	for i, layout := range dateBoundLayouts {
+		t, err := time.Parse(layout, text)
+		if err != nil {
+			continue
+		}
+		if end {
+			switch i {
+			case 0:
+				t = t.AddDate(1, 0, -1)
+			case 1:
+				t = t.AddDate(0, 1, -1)
+			}
+		}
+		return attr.DateOf(t), nil
+	}
+	return attr.Date{}, fmt.Errorf("date %q is not written yyyy, yyyy-mm or yyyy-mm-dd", text)
+}
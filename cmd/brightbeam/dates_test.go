@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+)
+
+func TestParseDateBound(t *testing.T) {
+	day := func(year int, month time.Month, d int) attr.Date {
+		return attr.DateOf(time.Date(year, month, d, 0, 0, 0, 0, time.UTC))
+	}
+	tests := []struct {
+		text    string
+		end     bool
+		want    attr.Date
+		wantErr bool
+	}{
+		{text: "", end: true},
+		{text: "2023", want: day(2023, time.January, 1)},
+		{text: "2023", end: true, want: day(2023, time.December, 31)},
+		{text: "2024-02", want: day(2024, time.February, 1)},
+		{text: "2024-02", end: true, want: day(2024, time.February, 29)},
+		{text: "2023-02", end: true, want: day(2023, time.February, 28)},
+		{text: " 2024-03-15 ", end: true, want: day(2024, time.March, 15)},
+		{text: "15/03/2024", wantErr: true},
+		{text: "2024-13", end: true, wantErr: true},
+		{text: "2023-02-29", wantErr: true},
+		{text: "last year", end: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseDateBound(tt.text, tt.end)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDateBound(%q, %v) error = %v, wantErr %v", tt.text, tt.end, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseDateBound(%q, %v) = %v, want %v", tt.text, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestDateOptions(t *testing.T) {
+	defer func(column, from, to string) { dateColumn, dateFrom, dateTo = column, from, to }(dateColumn, dateFrom, dateTo)
+	dateColumn = "Date of Sale (dd/mm/yyyy)"
+	withDate := []string{"Date of Sale (dd/mm/yyyy)", "Street Name", "Price"}
+	withoutDate := []string{"Street Name", "Price"}
+
+	tests := []struct {
+		name     string
+		from, to string
+		header   []string
+		want     int
+		wantErr  bool
+	}{
+		{name: "date column without a range", header: withDate, want: 2},
+		{name: "no date column without a range", header: withoutDate},
+		{name: "range", to: "2023", header: withDate, want: 3},
+		{name: "range without the column is left to the parser", from: "2019", header: withoutDate, want: 3},
+		{name: "bad --to", to: "2023/12", header: withDate, wantErr: true},
+		{name: "bad --from", from: "yesterday", header: withDate, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dateFrom, dateTo = tt.from, tt.to
+			opts, err := dateOptions(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("dateOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(opts) != tt.want {
+				t.Errorf("dateOptions() = %d options, want %d", len(opts), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"propertytreeanalyzer/pkg/streams"
+)
+
+// followPoll is how often a followed file is checked for new data
+const followPoll = 250 * time.Millisecond
+
+// openProperties opens a properties file, a followed file is read until the context is done
+func openProperties(ctx context.Context, path string) (io.ReadCloser, error) {
+	if !follow {
+		return open(ctx, path)
+	}
+	// a header row written again after truncation or rotation is not a record
+	skipHeader := propertiesFormat(path) == formatCsv && propertiesHeader == "" && !propertiesNoHeader
+	return streams.Follow(ctx, path, followPoll, skipHeader)
+}
+
+// checkFollow makes sure --follow reads one local file sequentially in a line based format
+func checkFollow(paths []string) error {
+	if !follow {
+		return nil
+	}
+	if len(paths) != 1 {
+		return fmt.Errorf("--follow needs exactly one properties file, got %d", len(paths))
+	}
+	path := paths[0]
+	if _, _, ok := streams.SplitArchivePath(path); ok || path == stdinPath || isRemote(path) {
+		return fmt.Errorf("--follow needs a local properties file, got %q", path)
+	}
+	if info, err := os.Stat(path); err != nil {
+		return err
+	} else if !info.Mode().IsRegular() {
+		return fmt.Errorf("--follow needs a regular properties file, got %q", path)
+	}
+	switch propertiesFormat(path) {
+	case formatCsv, formatNdjson, formatFixed:
+	default:
+		return fmt.Errorf("--follow cannot read %s files, they are not line based", propertiesFormat(path))
+	}
+	if csvWorkers > 0 || csvMmap {
+		return errors.New("--follow reads the file as it grows, --csv-workers and --csv-mmap cannot be used")
+	}
+	if csvDelimiter == "auto" {
+		// the guess needs a sample the followed file may never grow to
+		return errors.New("--follow needs an explicit --csv-delimiter")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -9,31 +10,113 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
 
 	"propertytreeanalyzer/pkg/aggregator"
+	apiAggregator "propertytreeanalyzer/pkg/api/aggregator"
 	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
+	apiGroupify "propertytreeanalyzer/pkg/api/groupify"
+	apiParser "propertytreeanalyzer/pkg/api/parsers"
+	"propertytreeanalyzer/pkg/batch"
 	"propertytreeanalyzer/pkg/csvparser"
-	"propertytreeanalyzer/pkg/groupify"
+	"propertytreeanalyzer/pkg/rejects"
+	"propertytreeanalyzer/pkg/sources"
 	"propertytreeanalyzer/pkg/streams"
 )
 
 var (
-	logPath        string
-	treesPath      string
-	propertiesPath string
-	verbose        bool
-	logCfg         slog.HandlerOptions = slog.HandlerOptions{
+	logPath             string
+	treesPath           string
+	propertiesPaths     []string
+	verbose             bool
+	csvDelimiter        string
+	csvQuote            string
+	csvComment          string
+	csvLazyQuotes       bool
+	csvTSV              bool
+	csvWorkers          int
+	csvMmap             bool
+	encodingName        string
+	propertiesFmt       string
+	propertiesSheet     string
+	propertiesFields    []string
+	propertiesHeaderRow int
+	propertiesHeader    string
+	propertiesNoHeader  bool
+	streetColumn        string
+	priceColumn         string
+	streetIndex         int
+	priceIndex          int
+	priceLocale         string
+	onError             string
+	rejectsPath         string
+	dateColumn          string
+	dateLayouts         []string
+	dateFrom            string
+	dateTo              string
+	fixedWidthSpec      string
+	fixedWidthHeader    int
+	fixedWidthFooter    int
+	treesSheet          string
+	treesHeaderRow      int
+	cacheDir            string
+	sha256Pins          []string
+	s3Endpoint          string
+	follow              bool
+	followInterval      time.Duration
+	batchSize           int
+	batchFlush          time.Duration
+	logCfg              slog.HandlerOptions = slog.HandlerOptions{
 		Level: slog.LevelWarn,
 	}
 )
 
 func cmdLineParse() {
 	pflag.StringVarP(&logPath, "log", "l", "", "path to log file. Default is stderr")
-	pflag.StringVarP(&treesPath, "trees", "t", "dublin-trees.json", "path to JSON file with group of trees (short/tall)")
-	pflag.StringVarP(&propertiesPath, "properties", "p", "dublin-property.csv", "path to CSV file with property prices")
+	pflag.StringVarP(&treesPath, "trees", "t", "dublin-trees.json", `path, http(s) or s3:// URL of JSON file with group of trees (short/tall), optionally gzip/bzip2/zstd/xz compressed; .xlsx workbooks list "Street Name" and "Tree Size" columns; "-" reads stdin; "archive.zip!/member" reads a zip or tar member, a pattern must match one member`)
+	pflag.StringArrayVarP(&propertiesPaths, "properties", "p", []string{"dublin-property.csv"}, "paths, glob patterns, http(s) or s3:// URLs of CSV files with property prices, optionally gzip/bzip2/zstd/xz compressed; repeat the flag to read several files in order; - reads stdin; archive.zip!/pattern reads zip or tar members")
 	pflag.BoolVarP(&verbose, "verbose", "v", false, "enable verbose (debug) logging")
+	pflag.StringVar(&csvDelimiter, "csv-delimiter", ",", `CSV field delimiter: a single character, "tab" or "auto" to guess it with the quote character`)
+	pflag.StringVar(&csvQuote, "csv-quote", `"`, "CSV quote character")
+	pflag.StringVar(&csvComment, "csv-comment", "", "CSV comment character, lines starting with it are ignored")
+	pflag.BoolVar(&csvLazyQuotes, "csv-lazy-quotes", false, "tolerate bare and unescaped quotes in CSV fields")
+	pflag.IntVar(&csvWorkers, "csv-workers", 0, "parse uncompressed CSV files in chunks on this many goroutines, 0 reads them sequentially")
+	pflag.BoolVar(&csvMmap, "csv-mmap", false, "tokenize uncompressed CSV files in place, memory-mapping regular files, and read only the street and price columns")
+	pflag.BoolVar(&csvTSV, "tsv", false, "properties file is tab separated (same as --csv-delimiter tab)")
+	pflag.StringVar(&propertiesFmt, "properties-format", formatAuto, `properties file format: "csv", "ndjson", "parquet", "xlsx", "fixed" or "auto" to pick it by file extension`)
+	pflag.StringSliceVar(&propertiesFields, "properties-fields", nil, "comma separated keys of NDJSON properties objects read as columns in this order, by default the keys of the first 100 objects")
+	pflag.StringVar(&propertiesSheet, "properties-sheet", "", "worksheet name or 1-based position when the properties file is a spreadsheet, the first sheet by default")
+	pflag.IntVar(&propertiesHeaderRow, "properties-header-row", 0, "1-based spreadsheet row with the properties column names, the first non-empty row by default")
+	pflag.StringVar(&propertiesHeader, "properties-header", "", `comma separated column names of CSV properties files without a header row, e.g. "date,address,street,price"; the first row is data`)
+	pflag.BoolVar(&propertiesNoHeader, "properties-no-header", false, "CSV properties files have no header row, columns are named col1, col2, ...; the first row is data")
+	pflag.StringVar(&streetColumn, "street-column", defaultStreetColumn, "name of the properties column with the street name")
+	pflag.StringVar(&priceColumn, "price-column", defaultPriceColumn, "name of the properties column with the price")
+	pflag.StringVar(&priceLocale, "price-locale", csvparser.AutoPriceLocale, `how prices are written: "en-IE" (1,234.50), "de-DE" (1.234,50), "fr-FR" (1 234,50) or "auto" to infer the separators of every price`)
+	pflag.StringVar(&onError, "on-error", "skip", `what to do with properties rows which cannot be read: "skip" drops them, "fail" stops the run with an error, "collect" drops them and logs them at the end`)
+	pflag.StringVar(&rejectsPath, "rejects", "", "path of a CSV file receiving every dropped properties row with its source, line number and reason code; rows are re-encoded as CSV rather than copied verbatim, in the columns of the first properties file, missing fields are left empty and extra ones cut")
+	pflag.StringVar(&dateColumn, "date-column", "Date of Sale (dd/mm/yyyy)", "name of the properties column with the sale date, read with --from and --to")
+	pflag.StringSliceVar(&dateLayouts, "date-layout", []string{csvparser.DefaultDateLayout}, `Go layouts of the sale dates tried in order, e.g. "02/01/2006" (dd/mm/yyyy) or "2006-01-02"`)
+	pflag.StringVar(&dateFrom, "from", "", "only average properties sold on or after this date, written yyyy, yyyy-mm or yyyy-mm-dd")
+	pflag.StringVar(&dateTo, "to", "", "only average properties sold on or before this date, written yyyy, yyyy-mm or yyyy-mm-dd; --from 2019 --to 2023 covers both years")
+	pflag.IntVar(&streetIndex, "street-index", 0, "1-based position of the street name column, used with --price-index instead of the column names")
+	pflag.IntVar(&priceIndex, "price-index", 0, "1-based position of the price column, used with --street-index instead of the column names")
+	pflag.StringVar(&fixedWidthSpec, "fixed-width-layout", "", `column layout of fixed-width properties files: inline "Street Name:1:40,Price:41:12" (name:start:width) or a .json/.yaml layout file; selects the "fixed" format`)
+	pflag.IntVar(&fixedWidthHeader, "fixed-width-header", 0, "number of header lines skipped at the start of fixed-width files, overrides the layout file")
+	pflag.IntVar(&fixedWidthFooter, "fixed-width-footer", 0, "number of footer lines dropped at the end of fixed-width files, overrides the layout file")
+	pflag.StringVar(&treesSheet, "trees-sheet", "", "worksheet name or 1-based position when the trees file is a spreadsheet, the first sheet by default")
+	pflag.IntVar(&treesHeaderRow, "trees-header-row", 0, "1-based spreadsheet row with the trees column names, the first non-empty row by default")
+	pflag.StringVar(&cacheDir, "cache-dir", defaultCacheDir(), "directory caching http(s) inputs, revalidated with ETag/Last-Modified; empty disables the cache")
+	pflag.StringArrayVar(&sha256Pins, "sha256", nil, "URL=HEX requires the http(s) input URL to have the SHA-256 checksum HEX, repeat the flag for several inputs")
+	pflag.StringVar(&s3Endpoint, "s3-endpoint", "", "URL of an S3 compatible object store for s3://bucket/key inputs, e.g. http://localhost:9000 for MinIO; defaults to AWS_ENDPOINT_URL_S3 or AWS")
+	pflag.BoolVar(&follow, "follow", false, "keep reading the properties file as it grows, like tail -F, and print the averages every --follow-interval; truncation and rotation are detected, an interrupt prints the final averages")
+	pflag.DurationVar(&followInterval, "follow-interval", 10*time.Second, "interval of the averages printed in --follow mode")
+	pflag.IntVar(&batchSize, "batch-size", batch.DefaultSize, "number of prices passed between the pipeline stages at once")
+	pflag.DurationVar(&batchFlush, "batch-flush-interval", time.Second, "in --follow mode, interval after which a partial batch of prices is passed on")
+	pflag.StringVar(&encodingName, "encoding", streams.AutoEncoding, `character encoding of the properties file, e.g. "windows-1252", "latin1", "utf-8" or "auto" to detect it`)
 	pflag.Parse()
 }
 
@@ -53,7 +136,35 @@ func initLog() io.Closer {
 	return nil
 }
 
-func open(path string) (io.ReadCloser, error) {
+// queue sizes of the channels between the pipeline stages, prices are queued in batches
+const (
+	pricesQueueSize = 16
+	groupsQueueSize = 1000
+)
+
+// stdinPath stands for the standard input in --properties and --trees
+const stdinPath = "-"
+
+// open opens the input at path, stdin for "-", downloads http(s) and s3:// URLs and reads "archive!/member"
+// paths from zip and tar archives. Named pipes and other special files are opened like regular files,
+// formats which need random access buffer them (see randomAccess).
+func open(ctx context.Context, path string) (io.ReadCloser, error) {
+	if archivePath, member, ok := streams.SplitArchivePath(path); ok {
+		archive, err := openArchive(ctx, archivePath)
+		if err != nil {
+			return nil, err
+		}
+		return archive.Open(member)
+	}
+	if path == stdinPath {
+		return os.Stdin, nil
+	}
+	if sources.IsURL(path) {
+		return fetcher.Open(ctx, path)
+	}
+	if sources.IsS3URL(path) {
+		return s3Fetcher.Open(ctx, path)
+	}
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -61,81 +172,273 @@ func open(path string) (io.ReadCloser, error) {
 	return file, nil
 }
 
+// checkStdin makes sure at most one input is read from stdin, the members of an archive read
+// from stdin share one read
+func checkStdin(paths []string) error {
+	uses, archive := 0, false
+	for _, path := range paths {
+		if archivePath, _, ok := streams.SplitArchivePath(path); ok && archivePath == stdinPath {
+			archive = true
+		} else if path == stdinPath {
+			uses++
+		}
+	}
+	if archive {
+		uses++
+	}
+	if uses > 1 {
+		return errors.New("stdin can be read by only one input")
+	}
+	return nil
+}
+
 func main() {
+	// a failed run exits once the deferred cleanups are done
+	var exitCode int
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	cmdLineParse()
 	if l := initLog(); l != nil {
 		defer l.Close()
 	}
 
+	defer closeSpools()
+	defer closeArchives()
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	propertiesSource, err := open(propertiesPath)
+	var err error
+	if fetcher, err = newFetcher(); err == nil {
+		s3Fetcher, err = newS3Fetcher()
+	}
+	if err != nil {
+		slog.Error("configure downloads", "error", err)
+		exitCode = 1
+		return
+	}
+
+	paths, err := expandPaths(ctx, propertiesPaths)
+	if err == nil {
+		treesPath, err = expandTreesPath(ctx, treesPath)
+	}
+	if err == nil {
+		err = checkStdin(append(paths, treesPath))
+	}
+	if err == nil {
+		err = checkFollow(paths)
+	}
 	if err != nil {
 		slog.Error("CSV open", "error", err)
 		if len(os.Args) < 4 {
 			pflag.Usage()
 		}
-		os.Exit(1)
+		exitCode = 1
+		return
 	}
-	defer propertiesSource.Close()
 
-	cvsStream, err := streams.NewCsvStream(propertiesSource)
+	cvsStream, err := newPropertiesMultiStream(ctx, paths)
 	if err != nil {
 		slog.Error("create CSV stream", "error", err)
-		os.Exit(2)
+		exitCode = 2
+		return
+	}
+	if closer, ok := cvsStream.(io.Closer); ok {
+		defer closer.Close()
 	}
 
-	parser, err := csvparser.NewPriceParser(cvsStream, csvparser.WithColNames("Street Name", "Price"))
+	columns, err := priceColumns()
+	if err != nil {
+		slog.ErrorContext(ctx, "create price parser", "error", err)
+		exitCode = 3
+		return
+	}
+	policy, err := csvparser.ParseErrorPolicy(onError)
+	if err != nil {
+		slog.ErrorContext(ctx, "create price parser", "error", err)
+		exitCode = 3
+		return
+	}
+	dateOpts, err := dateOptions(cvsStream.GetHeader())
 	if err != nil {
 		slog.ErrorContext(ctx, "create price parser", "error", err)
-		os.Exit(3)
+		exitCode = 3
+		return
+	}
+	parserOpts := []csvparser.PriceParserOption{
+		columns,
+		csvparser.WithPriceLocale(priceLocale),
+		csvparser.WithBatchSize(batchSize),
+		csvparser.WithErrorPolicy(policy),
+	}
+	parserOpts = append(parserOpts, dateOpts...)
+	if follow {
+		parserOpts = append(parserOpts, csvparser.WithFlushInterval(batchFlush))
+	}
+	aggOpts := []aggregator.Option{aggregator.WithBatchSize(batchSize)}
+	if rejectsPath != "" {
+		rejectsFile, err := os.Create(rejectsPath)
+		if err != nil {
+			slog.ErrorContext(ctx, "create rejects file", "error", err)
+			exitCode = 3
+			return
+		}
+		defer rejectsFile.Close()
+		sink, err := rejects.NewWriter(rejectsFile, cvsStream.GetHeader())
+		if err != nil {
+			slog.ErrorContext(ctx, "create rejects file", "error", err)
+			exitCode = 3
+			return
+		}
+		defer flushRejects(ctx, sink)
+		parserOpts = append(parserOpts, csvparser.WithRejects(sink))
+		aggOpts = append(aggOpts, aggregator.WithRejects(sink))
+	}
+	parser, err := csvparser.NewPriceParser(cvsStream, parserOpts...)
+	if err != nil {
+		slog.ErrorContext(ctx, "create price parser", "error", err)
+		exitCode = 3
+		return
 	}
 
-	jsonSource, err := open(treesPath)
+	treesSource, err := open(ctx, treesPath)
 	if err != nil {
-		slog.ErrorContext(ctx, "JSON open", "error", err)
+		slog.ErrorContext(ctx, "trees open", "error", err)
 		if len(os.Args) < 4 {
 			pflag.Usage()
 		}
+		exitCode = 4
+		return
+	}
+	defer treesSource.Close()
 
-		os.Exit(4)
+	grouper, _, err := newTreesGrouper(treesPath, treesSource)
+	if err != nil {
+		slog.ErrorContext(ctx, "create trees grouper", "error", err)
+		exitCode = 5
+		return
+	}
+	keyed, ok := grouper.(apiGroupify.KeyedStreetGroupIterator[apiGroupify.TreeSize])
+	if !ok {
+		slog.ErrorContext(ctx, "trees grouper does not key streets by tree size")
+		exitCode = 5
+		return
+	}
+	batches, ok := parser.(apiParser.StreetValueBatchParser[attr.Decimal])
+	if !ok {
+		slog.ErrorContext(ctx, "price parser does not parse decimal prices")
+		exitCode = 3
+		return
+	}
+	runCtx := ctx
+	if follow {
+		// an interrupt ends following, the prices read so far are still averaged and printed,
+		// a second one stops the process
+		runCtx = context.WithoutCancel(ctx)
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+		aggOpts = append(aggOpts, aggregator.WithSnapshots(followInterval, printAverages))
 	}
-	defer jsonSource.Close()
 
-	jsonStream := streams.NewJsonStream(jsonSource)
-	grouper, groups := groupify.NewTreesGrouper(jsonStream)
-	calculator := aggregator.NewAvgPriceBy(groups)
+	groups := make(chan apiGroupify.GroupItem[apiGroupify.TreeSize], groupsQueueSize)
+	calculator, ok := aggregator.NewAvgBy(groups, aggOpts...).(apiAggregator.BatchAverageAggregator[apiGroupify.TreeSize])
+	if !ok {
+		slog.ErrorContext(ctx, "average aggregator does not read price batches")
+		exitCode = 6
+		return
+	}
 
-	go func() {
-		if err := grouper.GroupStreets(ctx, groups); err != nil {
-			slog.ErrorContext(ctx, "group streets", "error", err)
+	// prices travel in batches, one channel operation passes up to --batch-size of them
+	prices := make(chan apiBatch.Batch[attr.StreetValue[attr.Decimal]], pricesQueueSize)
+	eg, egCtx := errgroup.WithContext(runCtx)
+	eg.Go(func() error {
+		if err := batches.ParseValueBatches(egCtx, prices); err != nil {
+			return fmt.Errorf("parse prices: %w", err)
 		}
-	}()
+		return nil
+	})
 
-	prices := make(chan attr.StreetAttribute, 10000)
-	go func() {
-		if err := parser.ParseAttributes(ctx, prices); err != nil {
-			slog.ErrorContext(ctx, "Error parsing prices", "error", err)
+	// a grouping error fails the run, the averages of partial groups are not printed
+	eg.Go(func() error {
+		defer close(groups)
+		for item, err := range keyed.KeyedItems(egCtx) {
+			if err != nil {
+				return fmt.Errorf("group streets: %w", err)
+			}
+			select {
+			case <-egCtx.Done():
+				return egCtx.Err()
+			case groups <- item:
+			}
 		}
-	}()
+		return nil
+	})
 
-	if result, err := calculator.Process(ctx, prices); err != nil {
-		slog.ErrorContext(ctx, "Error processing prices", "error", err)
+	var result []apiAggregator.GroupAverage[apiGroupify.TreeSize]
+	eg.Go(func() error {
+		var err error
+		if result, err = calculator.ProcessBatches(egCtx, prices); err != nil {
+			return fmt.Errorf("process prices: %w", err)
+		}
+		return nil
+	})
+	if err := eg.Wait(); err != nil {
+		// averages of part of the prices would look like a complete result
+		slog.ErrorContext(runCtx, "Error processing prices", "error", err)
+		exitCode = 6
 	} else {
-		// Simulate JSON output
-		fmt.Println("[")
-		for i, g := range result {
-			comma := ","
-			if i == len(result)-1 {
-				comma = ""
-			}
-			fmt.Printf("  {\"group\":\"%s\",\"average\":\"%s\"}%s\n",
-				g.GroupKey(),
-				g.AverageValue(),
-				comma,
-			)
+		printAverages(result)
+	}
+	logParseErrors(runCtx, parser)
+}
+
+// flushRejects writes the buffered rejected rows to the rejects file
+func flushRejects(ctx context.Context, sink *rejects.Writer) {
+	if err := sink.Flush(); err != nil {
+		slog.ErrorContext(ctx, "write rejects file", "error", err)
+		return
+	}
+	if n := sink.Count(); n > 0 {
+		slog.InfoContext(ctx, "properties rows rejected", "count", n, "file", rejectsPath)
+	}
+}
+
+// logParseErrors logs the rows the parser dropped under the collect policy
+func logParseErrors(ctx context.Context, parser any) {
+	collector, ok := parser.(csvparser.ErrorCollector)
+	if !ok {
+		return
+	}
+	errs := collector.ParseErrors()
+	for _, perr := range errs {
+		slog.WarnContext(ctx, "dropped properties row", "row", perr.Row, "column", perr.Column,
+			"value", perr.Value, "reason", perr.Reason, "error", perr.Err)
+	}
+	if len(errs) > 0 {
+		slog.WarnContext(ctx, "properties rows dropped", "count", len(errs))
+	}
+}
+
+// printAverages writes the group averages to stdout as a JSON array
+func printAverages[G apiAggregator.AverageByGroup](result []G) {
+	// Simulate JSON output
+	fmt.Println("[")
+	for i, g := range result {
+		comma := ","
+		if i == len(result)-1 {
+			comma = ""
 		}
-		fmt.Println("]")
+		fmt.Printf("  {\"group\":\"%s\",\"average\":\"%s\"}%s\n",
+			g.GroupKey(),
+			g.AverageValue(),
+			comma,
+		)
 	}
+	fmt.Println("]")
 }
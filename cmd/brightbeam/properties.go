@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+	"propertytreeanalyzer/pkg/csvparser"
+	"propertytreeanalyzer/pkg/streams"
+)
+
+// properties file formats accepted by --properties-format
+const (
+	formatAuto    = "auto"
+	formatCsv     = "csv"
+	formatNdjson  = "ndjson"
+	formatParquet = "parquet"
+	formatXlsx    = "xlsx"
+	formatFixed   = "fixed"
+)
+
+// default columns of the properties file used by the price parser
+const (
+	defaultStreetColumn = "Street Name"
+	defaultPriceColumn  = "Price"
+)
+
+// compressionExts are skipped when the format is guessed by file extension
+var compressionExts = map[string]bool{".gz": true, ".bz2": true, ".zst": true, ".xz": true}
+
+// formatExt is the lowercase extension of the file, or of the URL path, before any compression extension
+func formatExt(path string) string {
+	if isRemote(path) {
+		if u, err := url.Parse(path); err == nil {
+			path = u.Path
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if compressionExts[ext] {
+		path = strings.TrimSuffix(path, filepath.Ext(path))
+		ext = strings.ToLower(filepath.Ext(path))
+	}
+	return ext
+}
+
+// detectFormat guesses the file format by its extension, e.g. "sales.jsonl.gz" is NDJSON.
+// An unknown extension gives an empty format.
+func detectFormat(path string) string {
+	switch formatExt(path) {
+	case ".ndjson", ".jsonl", ".json":
+		// the NDJSON stream reads JSON Lines and JSON arrays of objects
+		return formatNdjson
+	case ".csv", ".tsv", ".txt":
+		return formatCsv
+	case ".parquet", ".parq":
+		return formatParquet
+	case ".xlsx", ".xlsm":
+		return formatXlsx
+	default:
+		return ""
+	}
+}
+
+// expandPaths resolves the glob patterns of the properties flag keeping their order, also inside archives.
+// Plain paths are checked to exist, a pattern matching no file is an error.
+func expandPaths(ctx context.Context, patterns []string) ([]string, error) {
+	var paths []string
+	for _, pattern := range patterns {
+		if archivePattern, memberPattern, ok := streams.SplitArchivePath(pattern); ok {
+			members, err := expandMembers(ctx, archivePattern, memberPattern)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, members...)
+			continue
+		}
+		if pattern == stdinPath || isRemote(pattern) {
+			paths = append(paths, pattern)
+			continue
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			if _, err := os.Stat(pattern); err != nil {
+				return nil, err
+			}
+			paths = append(paths, pattern)
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("pattern %q matches no files", pattern)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no properties file given")
+	}
+	return paths, nil
+}
+
+// newPropertiesMultiStream reads the properties files one after another as a single stream.
+// Every file must have the street and price columns, other columns are matched by name.
+func newPropertiesMultiStream(ctx context.Context, paths []string) (iface.CsvStream, error) {
+	sources := make([]streams.CsvSource, 0, len(paths))
+	for _, path := range paths {
+		sources = append(sources, streams.CsvSource{
+			Name: sourceName(path),
+			Open: func() (iface.CsvStream, io.Closer, error) {
+				source, err := openProperties(ctx, path)
+				if err != nil {
+					return nil, nil, err
+				}
+				stream, err := newPropertiesStream(path, source)
+				if err != nil {
+					source.Close()
+					return nil, nil, err
+				}
+				return stream, source, nil
+			},
+		})
+	}
+	var opts []streams.Option
+	if !columnsByIndex() {
+		opts = append(opts, streams.WithRequired(parserColumns()...))
+	}
+	return streams.NewMultiCsvStream(sources, opts...)
+}
+
+// propertiesFormat is the --properties-format or the format guessed for the file
+func propertiesFormat(path string) string {
+	format := strings.ToLower(propertiesFmt)
+	if format == formatAuto && fixedWidthSpec != "" {
+		format = formatFixed
+	}
+	if format == formatAuto {
+		if format = detectFormat(path); format == "" {
+			format = formatCsv
+		}
+	}
+	return format
+}
+
+// newPropertiesStream creates the CSV stream for the properties file in the selected format
+func newPropertiesStream(path string, source io.Reader) (iface.CsvStream, error) {
+	switch propertiesFormat(path) {
+	case formatCsv:
+		opts, err := csvStreamOptions(path)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, streams.WithName(sourceName(path)))
+		if csvMmap {
+			if csvWorkers > 0 {
+				return nil, errors.New("--csv-mmap and --csv-workers cannot be used together")
+			}
+			file, size, err := randomAccess(path, source)
+			if err != nil {
+				return nil, err
+			}
+			if !columnsByIndex() && rejectsPath == "" {
+				// only the parser columns are materialised, rejected rows are written whole
+				opts = append(opts, projectColumns()...)
+			}
+			return streams.NewMappedCsvStream(file, size, opts...)
+		}
+		if csvWorkers > 0 {
+			file, size, err := randomAccess(path, source)
+			if err != nil {
+				return nil, err
+			}
+			// the average does not depend on the record order
+			return streams.NewParallelCsvStream(file, size, append(opts, streams.WithWorkers(csvWorkers))...)
+		}
+		return streams.NewCsvStream(source, opts...)
+	case formatNdjson:
+		return streams.NewNdjsonStream(source, streams.WithEncoding(encodingName), streams.WithFields(propertiesFields...), streams.WithName(sourceName(path)))
+	case formatParquet:
+		file, size, err := randomAccess(path, source)
+		if err != nil {
+			return nil, err
+		}
+		opts := []streams.Option{streams.WithName(sourceName(path))}
+		if !columnsByIndex() && rejectsPath == "" {
+			// only the parser columns are read from the file, rejected rows are written whole
+			opts = append(opts, projectColumns()...)
+		}
+		return streams.NewParquetStream(file, size, opts...)
+	case formatXlsx:
+		file, size, err := randomAccess(path, source)
+		if err != nil {
+			return nil, err
+		}
+		return streams.NewXlsxStream(file, size, streams.WithSheet(propertiesSheet), streams.WithHeaderRow(propertiesHeaderRow), streams.WithName(sourceName(path)))
+	case formatFixed:
+		layout, err := fixedWidthLayout()
+		if err != nil {
+			return nil, err
+		}
+		return streams.NewFixedWidthStream(source, layout, streams.WithEncoding(encodingName), streams.WithName(sourceName(path)))
+	default:
+		return nil, fmt.Errorf("unknown properties format %q", propertiesFmt)
+	}
+}
+
+// fixedWidthLayout reads the --fixed-width-layout file or inline layout, the header and
+// footer flags override the layout
+func fixedWidthLayout() (streams.FixedWidthLayout, error) {
+	var layout streams.FixedWidthLayout
+	var err error
+	if fixedWidthSpec == "" {
+		return layout, errors.New("fixed-width format needs --fixed-width-layout")
+	}
+	switch strings.ToLower(filepath.Ext(fixedWidthSpec)) {
+	case ".json", ".yaml", ".yml":
+		layout, err = streams.LoadFixedWidthLayout(fixedWidthSpec)
+	default:
+		layout, err = streams.ParseFixedWidthLayout(fixedWidthSpec)
+	}
+	if err != nil {
+		return layout, err
+	}
+	if pflag.Lookup("fixed-width-header").Changed {
+		layout.Header = fixedWidthHeader
+	}
+	if pflag.Lookup("fixed-width-footer").Changed {
+		layout.Footer = fixedWidthFooter
+	}
+	return layout, nil
+}
+
+// spools keeps the buffered copies of non-seekable inputs until the program ends
+var spools []io.Closer
+
+// randomAccess returns the source as io.ReaderAt for formats which are not read sequentially.
+// Regular files are used in place, stdin, pipes and FIFOs are buffered first.
+func randomAccess(path string, source io.Reader) (io.ReaderAt, int64, error) {
+	spooled, err := streams.Spool(source)
+	if err != nil {
+		return nil, 0, fmt.Errorf("buffer %s: %w", sourceName(path), err)
+	}
+	spools = append(spools, spooled)
+	return spooled, spooled.Size(), nil
+}
+
+// closeSpools removes the buffered copies made by randomAccess
+func closeSpools() {
+	for _, spooled := range spools {
+		spooled.Close()
+	}
+}
+
+// sourceName is the input name shown in positions and errors
+func sourceName(path string) string {
+	if path == stdinPath {
+		return "stdin"
+	}
+	if archivePath, member, ok := streams.SplitArchivePath(path); ok && archivePath == stdinPath {
+		return "stdin" + streams.ArchiveSeparator + member
+	}
+	return path
+}
+
+// parseDialectChar converts a dialect flag value into a single character
+func parseDialectChar(flag, value string) (rune, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r := []rune(value)
+	if len(r) != 1 {
+		return 0, fmt.Errorf("--%s must be a single character, got %q", flag, value)
+	}
+	return r[0], nil
+}
+
+// tabSeparated reports whether the properties file is read with tabs, given by --tsv or
+// by a .tsv extension when --csv-delimiter keeps the default
+func tabSeparated(path string) bool {
+	return csvTSV || formatExt(path) == ".tsv" && csvDelimiter == ","
+}
+
+// csvStreamOptions builds the CSV dialect options of the properties file from the command line
+func csvStreamOptions(path string) ([]streams.Option, error) {
+	opts := []streams.Option{streams.WithEncoding(encodingName)}
+	switch {
+	case tabSeparated(path):
+		opts = append(opts, streams.WithTSV())
+	case csvDelimiter == "auto":
+		opts = append(opts, streams.WithSniff())
+	default:
+		delimiter, err := parseDialectChar("csv-delimiter", csvDelimiter)
+		if err != nil {
+			return nil, err
+		}
+		if delimiter != 0 {
+			opts = append(opts, streams.WithDelimiter(delimiter))
+		}
+	}
+
+	quote, err := parseDialectChar("csv-quote", csvQuote)
+	if err != nil {
+		return nil, err
+	}
+	if quote != 0 {
+		opts = append(opts, streams.WithQuote(quote))
+	}
+	comment, err := parseDialectChar("csv-comment", csvComment)
+	if err != nil {
+		return nil, err
+	}
+	if comment != 0 {
+		opts = append(opts, streams.WithComment(comment))
+	}
+	if csvLazyQuotes {
+		opts = append(opts, streams.WithLazyQuotes())
+	}
+	switch {
+	case propertiesHeader != "":
+		names := strings.Split(propertiesHeader, ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+		opts = append(opts, streams.WithHeader(names...))
+	case propertiesNoHeader:
+		opts = append(opts, streams.WithSyntheticHeader())
+	}
+	return opts, nil
+}
+
+// columnsByIndex reports whether the price parser columns are given by position
+func columnsByIndex() bool {
+	return streetIndex != 0 || priceIndex != 0
+}
+
+// parserColumns are the names of the columns the price parser needs, the sale date
+// is optional unless the dates are filtered
+func parserColumns() []string {
+	if filterDates() {
+		return []string{streetColumn, priceColumn, dateColumn}
+	}
+	return []string{streetColumn, priceColumn}
+}
+
+// projectColumns restricts a projected stream to the columns read by the price parser
+func projectColumns() []streams.Option {
+	if filterDates() {
+		return []streams.Option{streams.WithFields(parserColumns()...)}
+	}
+	return []streams.Option{streams.WithFields(parserColumns()...), streams.WithOptionalFields(dateColumn)}
+}
+
+// priceColumns locates the street and price columns for the price parser,
+// by their 1-based position when it is given and by name otherwise
+func priceColumns() (csvparser.PriceParserOption, error) {
+	if !columnsByIndex() {
+		return csvparser.WithColNames(streetColumn, priceColumn), nil
+	}
+	if streetIndex < 1 || priceIndex < 1 {
+		return nil, errors.New("--street-index and --price-index must both be given as 1-based column positions")
+	}
+	return csvparser.WithColIndexes(streetIndex-1, priceIndex-1), nil
+}
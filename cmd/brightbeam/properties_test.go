@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"sales.csv", formatCsv},
+		{"sales.TSV", formatCsv},
+		{"sales.jsonl", formatNdjson},
+		{"sales.ndjson.gz", formatNdjson},
+		{"sales.json", formatNdjson},
+		{"sales.json.zst", formatNdjson},
+		{"sales.parquet", formatParquet},
+		{"sales.xlsx", formatXlsx},
+		{"https://example.com/sales.json?version=2", formatNdjson},
+		{"sales.dat", ""},
+	}
+	for _, tt := range tests {
+		if got := detectFormat(tt.path); got != tt.want {
+			t.Errorf("detectFormat(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTabSeparated(t *testing.T) {
+	defer func(tsv bool, delimiter string) { csvTSV, csvDelimiter = tsv, delimiter }(csvTSV, csvDelimiter)
+	tests := []struct {
+		path      string
+		tsv       bool
+		delimiter string
+		want      bool
+	}{
+		{path: "sales.tsv", delimiter: ",", want: true},
+		{path: "sales.TSV.gz", delimiter: ",", want: true},
+		{path: "sales.tsv", delimiter: ";"},
+		{path: "sales.csv", delimiter: ","},
+		{path: "sales.csv", tsv: true, delimiter: ",", want: true},
+	}
+	for _, tt := range tests {
+		csvTSV, csvDelimiter = tt.tsv, tt.delimiter
+		if got := tabSeparated(tt.path); got != tt.want {
+			t.Errorf("tabSeparated(%q) with --tsv=%v --csv-delimiter=%q = %v, want %v", tt.path, tt.tsv, tt.delimiter, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"propertytreeanalyzer/pkg/sources"
+)
+
+// fetcher opens http(s) inputs and s3Fetcher s3:// inputs, they are set up from the command line in main
+var (
+	fetcher   *sources.HttpFetcher
+	s3Fetcher *sources.S3Fetcher
+)
+
+// isRemote reports whether the input is downloaded
+func isRemote(path string) bool {
+	return sources.IsURL(path) || sources.IsS3URL(path)
+}
+
+// defaultCacheDir is the brightbeam directory in the user cache directory, empty when there is none
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "brightbeam")
+}
+
+// newFetcher creates the downloader with the cache directory and the --sha256 pins
+func newFetcher() (*sources.HttpFetcher, error) {
+	opts := []sources.FetchOption{sources.WithCacheDir(cacheDir)}
+	for _, pin := range sha256Pins {
+		// hex digits never contain "=", URLs may
+		i := strings.LastIndex(pin, "=")
+		if i < 0 || !sources.IsURL(pin[:i]) {
+			return nil, fmt.Errorf("--sha256 must be URL=HEX, got %q", pin)
+		}
+		opts = append(opts, sources.WithPin(pin[:i], pin[i+1:]))
+	}
+	return sources.NewHttpFetcher(opts...)
+}
+
+// newS3Fetcher creates the object store client, credentials come from the AWS environment variables
+func newS3Fetcher() (*sources.S3Fetcher, error) {
+	var opts []sources.S3Option
+	if s3Endpoint != "" {
+		opts = append(opts, sources.WithS3Endpoint(s3Endpoint))
+	}
+	return sources.NewS3Fetcher(fetcher, opts...)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"io"
+
+	apiGroupify "propertytreeanalyzer/pkg/api/groupify"
+	"propertytreeanalyzer/pkg/groupify"
+	"propertytreeanalyzer/pkg/streams"
+)
+
+// columns of a trees workbook
+const (
+	treesStreetColumn = "Street Name"
+	treesSizeColumn   = "Tree Size"
+)
+
+// newTreesGrouper creates the street grouper for the trees file. The nested JSON document
+// is the default, .xlsx workbooks list one street per row with its tree size.
+func newTreesGrouper(path string, source io.Reader) (apiGroupify.StreetGroups, chan apiGroupify.StreetGroupItem, error) {
+	if detectFormat(path) == formatXlsx {
+		file, size, err := randomAccess(path, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		stream, err := streams.NewXlsxStream(file, size, streams.WithSheet(treesSheet), streams.WithHeaderRow(treesHeaderRow), streams.WithName(sourceName(path)))
+		if err != nil {
+			return nil, nil, err
+		}
+		return groupify.NewTableTreesGrouper(stream, treesStreetColumn, treesSizeColumn)
+	}
+
+	stream, err := streams.NewJsonStreamWithOptions(source, streams.WithName(sourceName(path)))
+	if err != nil {
+		return nil, nil, err
+	}
+	grouper, groups := groupify.NewTreesGrouper(stream)
+	return grouper, groups, nil
+}
@@ -4,8 +4,19 @@ go 1.24
 
 require (
 	github.com/cockroachdb/apd/v3 v3.2.1
+	github.com/klauspost/compress v1.18.0
+	github.com/parquet-go/parquet-go v0.25.1
 	github.com/spf13/pflag v1.0.6
+	github.com/ulikunitz/xz v0.5.12
 	github.com/xyproto/randomstring v1.2.0
 	golang.org/x/sync v0.13.0
 	golang.org/x/text v0.24.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.21 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
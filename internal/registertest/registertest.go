@@ -0,0 +1,61 @@
+// Package registertest writes synthetic Property Price Register files for benchmarks
+package registertest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// DefaultRows is the size of the benchmark register file, ten times the full register export
+const DefaultRows = 10_000_000
+
+// Header is the header row of the register files written by WriteRegister
+const Header = "Date of Sale (dd/mm/yyyy),Address,Street Name,Postal Code,County,Price (€),Not Full Market Price,VAT Exclusive,Description of Property\n"
+
+// WriteRegister writes a register export with the columns of the Property Price Register,
+// prices are quoted euro amounts and addresses contain commas
+func WriteRegister(w io.Writer, rows int) error {
+	bw := bufio.NewWriterSize(w, 1<<20)
+	bw.WriteString(Header)
+	for i := range rows {
+		fmt.Fprintf(bw, "%02d/%02d/2024,\"%d Abbey Drive, Dublin %d\",Abbey Drive %d,Dublin %d,Dublin,\"€%d.00\",No,No,Second-Hand Dwelling house /Apartment\n",
+			i%28+1, i%12+1, i, i%24, i%5000, i%24, 100_000+i%900_000)
+	}
+	return bw.Flush()
+}
+
+// File writes a file in the test's temporary directory with write and opens it for reading.
+// The file is closed when the test ends.
+func File(tb testing.TB, name string, write func(io.Writer) error) (*os.File, int64) {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), name)
+	file, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := write(file); err != nil {
+		tb.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	if file, err = os.Open(path); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { file.Close() })
+	info, err := file.Stat()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return file, info.Size()
+}
+
+// RegisterFile writes a register file of the given rows, see WriteRegister and File
+func RegisterFile(tb testing.TB, rows int) (*os.File, int64) {
+	tb.Helper()
+	return File(tb, "register.csv", func(w io.Writer) error { return WriteRegister(w, rows) })
+}
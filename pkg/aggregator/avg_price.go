@@ -2,33 +2,45 @@ package aggregator
 
 import (
 	"context"
+	"iter"
 	"log/slog"
 	"sync"
+	"time"
 
 	api "propertytreeanalyzer/pkg/api/aggregator"
 	apiAttr "propertytreeanalyzer/pkg/api/attribute"
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
 	apiGroupify "propertytreeanalyzer/pkg/api/groupify"
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+	"propertytreeanalyzer/pkg/batch"
 
 	"github.com/cockroachdb/apd/v3"
 	"golang.org/x/sync/errgroup"
 )
 
 const (
-	groupsQueueSize = 1000
-	priceQueueSize  = 10000
+	// priceQueueSize is the number of value batches queued for the worker of a group
+	priceQueueSize = 16
 )
 
-type avgByGroup struct {
-	key string
-	val string
+// groupAverage is the average of a group with a typed key
+type groupAverage[K comparable] struct {
+	key K
+	avg apd.Decimal
 }
 
-func (a avgByGroup) GroupKey() string     { return a.key }
-func (a avgByGroup) AverageValue() string { return a.val }
+func (g groupAverage[K]) GroupKey() string     { return apiAttr.FormatValue(g.key) }
+func (g groupAverage[K]) AverageValue() string { return g.avg.String() }
+func (g groupAverage[K]) Group() K             { return g.key }
+func (g groupAverage[K]) Average() apd.Decimal { return g.avg }
 
 var (
-	_ api.AverageByGroup     = (*avgByGroup)(nil)
-	_ api.AvgerageAggregator = (*avgPriceBy)(nil)
+	_ api.AverageByGroup                               = (*groupAverage[string])(nil)
+	_ api.GroupAverage[apiGroupify.TreeSize]           = (*groupAverage[apiGroupify.TreeSize])(nil)
+	_ api.AvgerageAggregator                           = (*avgPriceBy)(nil)
+	_ api.AverageAggregator[apiGroupify.TreeSize]      = (*avgBy[apiGroupify.TreeSize])(nil)
+	_ api.BatchAverageAggregator[apiGroupify.TreeSize] = (*avgBy[apiGroupify.TreeSize])(nil)
 
 	sumCtx apd.Context = apd.Context{
 		Precision:   100,
@@ -47,134 +59,533 @@ var (
 	}
 )
 
+// settings are the options shared by the aggregators
+type settings struct {
+	// interval and report publish intermediate averages while Process runs, report is nil without them
+	interval time.Duration
+	report   func([]api.AverageByGroup)
+	// batchSize is the number of values passed to the worker of a group at once
+	batchSize int
+	// rejects receives the streets in no group, nil drops them silently
+	rejects apiRejects.Sink
+}
+
+// avgPriceBy averages the string prices of StreetAttributes per group key text
 type avgPriceBy struct {
 	groups <-chan apiGroupify.StreetGroupItem
+	settings
 }
 
-func NewAvgPriceBy(groups <-chan apiGroupify.StreetGroupItem) api.AvgerageAggregator {
-	return &avgPriceBy{
-		groups: groups,
+// avgBy averages typed decimal values per typed group key
+type avgBy[K comparable] struct {
+	groups <-chan apiGroupify.GroupItem[K]
+	settings
+}
+
+// Option configures the average aggregators
+type Option func(*settings)
+
+// WithSnapshots calls report with the averages of the prices received so far every interval
+// until Process returns, e.g. while a followed file grows. Groups without prices are left out.
+func WithSnapshots(interval time.Duration, report func([]api.AverageByGroup)) Option {
+	return func(s *settings) {
+		if interval > 0 {
+			s.interval, s.report = interval, report
+		}
 	}
 }
 
-// averagePrice calculates the average price of a group of attributes
-func averagePrice(ctx context.Context, in <-chan string) (string, error) {
-	sumDec := apd.New(0, 0)
-	valDec := apd.New(0, 0)
-	cnt := int64(0)
-	done := ctx.Done()
+// WithBatchSize sets the number of values passed to the worker of a group in one channel
+// operation, batch.DefaultSize by default
+func WithBatchSize(size int) Option {
+	return func(s *settings) {
+		if size > 0 {
+			s.batchSize = size
+		}
+	}
+}
+
+// WithRejects passes the values of streets in no group to the sink as UnmatchedStreet rejects.
+// Values implementing rejects.Recorder are rejected with their input row, the others with the street name.
+func WithRejects(sink apiRejects.Sink) Option {
+	return func(s *settings) {
+		s.rejects = sink
+	}
+}
+
+func newSettings(opts []Option) settings {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// NewAvgPriceBy creates the aggregator of string attributes, it adapts them to the typed aggregator.
+// Attributes which are decimal StreetValues are not parsed again.
+func NewAvgPriceBy(groups <-chan apiGroupify.StreetGroupItem, opts ...Option) api.AvgerageAggregator {
+	return &avgPriceBy{groups: groups, settings: newSettings(opts)}
+}
+
+// NewAvgBy creates the aggregator of decimal values grouped by typed keys, e.g. tree sizes
+func NewAvgBy[K comparable](groups <-chan apiGroupify.GroupItem[K], opts ...Option) api.AverageAggregator[K] {
+	return &avgBy[K]{groups: groups, settings: newSettings(opts)}
+}
+
+// runningAverage is the sum and count of the prices of a group, snapshots read it while prices are added
+type runningAverage struct {
+	mu  sync.Mutex
+	sum apd.Decimal
+	cnt int64
+}
+
+func (r *runningAverage) add(ctx context.Context, vals []apd.Decimal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range vals {
+		if _, err := sumCtx.Add(&r.sum, &r.sum, &vals[i]); err != nil {
+			slog.ErrorContext(ctx, "Error adding price to sum", "price", vals[i].String(), "error", err)
+			return err
+		}
+		r.cnt++
+	}
+	return nil
+}
+
+// count returns the number of prices added so far
+func (r *runningAverage) count() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cnt
+}
 
-	for val := range in {
+// average returns the average rounded to cents
+func (r *runningAverage) average(ctx context.Context) (apd.Decimal, error) {
+	var sum, avg apd.Decimal
+	r.mu.Lock()
+	sum.Set(&r.sum)
+	cnt := r.cnt
+	r.mu.Unlock()
+
+	if _, err := avgCtx.Quo(&avg, &sum, apd.New(cnt, 0)); err != nil {
+		slog.ErrorContext(ctx, "Error calculating average", "error", err)
+		return avg, err
+	}
+	if _, err := avgCtx.Quantize(&avg, &avg, -2); err != nil {
+		slog.ErrorContext(ctx, "Error quantizing average", "error", err)
+		return avg, err
+	}
+	return avg, nil
+}
+
+// averagePrice sums the value batches of a group
+func averagePrice(ctx context.Context, in <-chan *batch.Batch[apd.Decimal], acc *runningAverage) error {
+	done := ctx.Done()
+	for vals := range in {
 		select {
 		case <-done:
-			return "", ctx.Err()
+			vals.Release()
+			return ctx.Err()
 		default:
 		}
+		err := acc.add(ctx, vals.Items())
+		vals.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		// minimize memory allocations
-		if _, c, err := valDec.SetString(val); err != nil {
-			slog.ErrorContext(ctx, "Error parsing value", "value", val, "condition", c, "error", err)
-			return "", err
-		} else {
-			if _, err := sumCtx.Add(sumDec, sumDec, valDec); err != nil {
-				slog.ErrorContext(ctx, "Error adding price to sum", "price", valDec.String(), "error", err)
-				return "", err
-			}
+// groupQueue collects the values of a group into batches for its worker
+type groupQueue struct {
+	ch      chan *batch.Batch[apd.Decimal]
+	pending *batch.Batch[apd.Decimal]
+}
+
+// router passes the values of a street to the worker of its group in batches
+type router struct {
+	streets map[string]*groupQueue
+	queues  []*groupQueue
+	pool    *batch.Pool[apd.Decimal]
+	ctx     context.Context
+	// idle sends the partial batches whenever a channel source has no value ready,
+	// snapshots must see the values of a slow source
+	idle bool
+	// reject passes a street in no group to the rejects sink, nil without one
+	reject func(apiRejects.Reject) error
+}
+
+// streetNamer is a street attribute or value
+type streetNamer interface {
+	StreetName() string
+}
+
+// queue returns the queue of the group of the street, nil when the street is in no group.
+// Such streets are rejected when the aggregator has a rejects sink.
+func (r *router) queue(street streetNamer) (*groupQueue, error) {
+	q := r.streets[street.StreetName()]
+	if q != nil || r.reject == nil {
+		return q, nil
+	}
+	reject := apiRejects.Reject{Reason: apiRejects.UnmatchedStreet}
+	if recorder, ok := street.(apiRejects.Recorder); ok {
+		reject.Record = recorder.Record()
+	}
+	if reject.Record == nil {
+		reject.Record = []string{street.StreetName()}
+	}
+	if positioner, ok := street.(apiStreams.Positioner); ok {
+		reject.Pos = positioner.Position()
+	}
+	return nil, r.reject(reject)
+}
+
+// add appends the value to the batch of the group and sends it when full,
+// it returns the context error once the context is done
+func (r *router) add(q *groupQueue, val apd.Decimal) error {
+	if q.pending == nil {
+		q.pending = r.pool.Get()
+	}
+	if q.pending.Append(val) {
+		return r.send(q)
+	}
+	return nil
+}
+
+// flush sends the partial batches of all groups, it returns the context error once the context is done
+func (r *router) flush() error {
+	for _, q := range r.queues {
+		if q.pending == nil {
+			continue
+		}
+		if err := r.send(q); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		cnt++
+// send passes the pending batch of the group to its worker
+func (r *router) send(q *groupQueue) error {
+	vals := q.pending
+	q.pending = nil
+	select {
+	case <-r.ctx.Done():
+		vals.Release()
+		return r.ctx.Err()
+	case q.ch <- vals:
+		return nil
 	}
+}
 
-	// calculate average
-	valDec.SetInt64(0)
-	if _, err := avgCtx.Quo(valDec, sumDec, apd.New(cnt, 0)); err != nil {
-		slog.ErrorContext(ctx, "Error calculating average", "error", err)
-		return "", err
+// snapshots reports the running averages every interval until stop is closed
+func snapshots[K comparable](ctx context.Context, s settings, order []K, accs map[K]*runningAverage, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		var outputs []api.AverageByGroup
+		for _, key := range order {
+			if accs[key].count() == 0 {
+				continue
+			}
+			if avg, err := accs[key].average(ctx); err == nil {
+				outputs = append(outputs, groupAverage[K]{key: key, avg: avg})
+			}
+		}
+		s.report(outputs)
 	}
-	if _, err := avgCtx.Quantize(valDec, valDec, -2); err != nil {
-		slog.ErrorContext(ctx, "Error quantizing average", "error", err)
-		return "", err
+}
+
+// chanSeq returns an iterator over the values received from ch until it is closed
+func chanSeq[T any](ch <-chan T) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v := range ch {
+			if !yield(v, nil) {
+				return
+			}
+		}
 	}
-	return valDec.String(), nil
 }
 
-// Process implements aggregators.AvgerageAggregator.
-func (a *avgPriceBy) Process(ctx context.Context, streets <-chan apiAttr.StreetAttribute) (outputs []api.AverageByGroup, resultErr error) {
-	// I use regular map here because the number of groups is immutable in the Process method
+// source returns the values the router passes on
+type source[T any] func(r *router) iter.Seq2[T, error]
+
+// seqSource is the source of the values of an iterator
+func seqSource[T any](seq iter.Seq2[T, error]) source[T] {
+	return func(*router) iter.Seq2[T, error] { return seq }
+}
+
+// chanSource is the source of the values received from ch until it is closed. When the router
+// is idle, its partial batches are sent before waiting for a value which is not ready yet.
+func chanSource[T any](ch <-chan T) source[T] {
+	return func(r *router) iter.Seq2[T, error] {
+		if !r.idle {
+			return chanSeq(ch)
+		}
+		return func(yield func(T, error) bool) {
+			for {
+				var v T
+				var ok bool
+				select {
+				case v, ok = <-ch:
+				default:
+					if err := r.flush(); err != nil {
+						yield(v, err)
+						return
+					}
+					v, ok = <-ch
+				}
+				if !ok || !yield(v, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// process averages the values per group in parallel. The groups are read first, then feed passes
+// every value to the router; it returns when its input is drained, the context is done or on error.
+// A context done before all values are averaged is an error, the averages would be partial.
+func process[K comparable](ctx context.Context, groups iter.Seq2[apiGroupify.GroupItem[K], error], s settings,
+	feed func(r *router) error) ([]groupAverage[K], error) {
+	// I use regular map here because the number of groups is immutable in the process function
 	// So I precreate and fill maps
-	prices := make(map[string]chan string) // parallel calculation AVG price per groups
+	prices := make(map[K]*groupQueue) // parallel calculation AVG price per groups
 	// here is the biggest storage complexity, but I do not expect to have more than 100K streets
 	// for golang 1.24 it is swiss table and for my microbenchmarks it works faster than existing Patricia tree in Go
-	streetToSize := make(map[string]string) // joining street names with group ids
-	done := ctx.Done()
-
-	type result struct {
-		avg string
-		err error
-	}
+	streetToGroup := make(map[string]*groupQueue) // joining street names with group queues
 
 	var (
-		results sync.Map
-		order   []string
+		order []K
+		accs  = make(map[K]*runningAverage)
 	)
 
-	// prefill maps from JSON stream
-	for item := range a.groups {
-		groupId := item.Key().String()
-		streetToSize[item.StreetName().String()] = groupId
-		if _, ok := prices[groupId]; !ok {
-			prices[groupId] = make(chan string, priceQueueSize)
-			results.Store(groupId, result{avg: "", err: nil})
-			order = append(order, groupId)
+	// prefill maps from the groups stream
+	for item, err := range groups {
+		if err != nil {
+			slog.ErrorContext(ctx, "Error reading groups", "error", err)
+			return nil, err
+		}
+		key := item.GroupKey()
+		if _, ok := prices[key]; !ok {
+			prices[key] = &groupQueue{ch: make(chan *batch.Batch[apd.Decimal], priceQueueSize)}
+			accs[key] = &runningAverage{}
+			order = append(order, key)
 		}
+		streetToGroup[item.StreetName().String()] = prices[key]
 	}
 
 	// spawn workers under errgroup
 	eg, ctx := errgroup.WithContext(ctx)
-	for groupId, ch := range prices {
-		groupId, ch := groupId, ch
+	for key, q := range prices {
 		eg.Go(func() error {
-			avgVal, err := averagePrice(ctx, ch)
-			results.Store(groupId, result{avg: avgVal, err: err})
-			return err
+			return averagePrice(ctx, q.ch, accs[key])
 		})
 	}
 
 	// feed price channels
-	go func() {
+	eg.Go(func() error {
 		defer func() {
 			// close() is a cheap operation
-			for _, ch := range prices {
-				close(ch)
+			for _, q := range prices {
+				close(q.ch)
 			}
 		}()
-		for street := range streets {
-			select {
-			case <-done:
+		r := &router{
+			streets: streetToGroup,
+			pool:    batch.NewPool[apd.Decimal](s.batchSize),
+			ctx:     ctx,
+			idle:    s.report != nil,
+		}
+		if s.rejects != nil {
+			r.reject = func(reject apiRejects.Reject) error { return s.rejects.Reject(ctx, reject) }
+		}
+		for _, key := range order {
+			r.queues = append(r.queues, prices[key])
+		}
+		if err := feed(r); err != nil {
+			return err
+		}
+		if err := r.flush(); err != nil {
+			return err
+		}
+		// a source closed by the cancellation looks drained
+		return ctx.Err()
+	})
+
+	if s.report != nil {
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snapshots(ctx, s, order, accs, stop)
+		}()
+		// no snapshot is reported after process returns
+		defer wg.Wait()
+		defer close(stop)
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	// build outputs in recorded order, a group without prices, e.g. after a date filter, has no average
+	outputs := make([]groupAverage[K], 0, len(order))
+	for _, key := range order {
+		if accs[key].count() == 0 {
+			continue
+		}
+		avg, err := accs[key].average(ctx)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, groupAverage[K]{key: key, avg: avg})
+	}
+	return outputs, nil
+}
+
+// Process implements aggregators.AvgerageAggregator.
+func (a *avgPriceBy) Process(ctx context.Context, streets <-chan apiAttr.StreetAttribute) ([]api.AverageByGroup, error) {
+	return averagePrices(ctx, chanSeq(a.groups), chanSource(streets), a.settings)
+}
+
+// ProcessValues implements aggregators.AverageAggregator.
+func (a *avgBy[K]) ProcessValues(ctx context.Context, streets <-chan apiAttr.StreetValue[apiAttr.Decimal]) ([]api.GroupAverage[K], error) {
+	return averageValues(ctx, chanSeq(a.groups), chanSource(streets), a.settings)
+}
+
+// ProcessBatches implements aggregators.BatchAverageAggregator.
+// The values of a batch are passed on before the next batch is awaited.
+func (a *avgBy[K]) ProcessBatches(ctx context.Context, streets <-chan apiBatch.Batch[apiAttr.StreetValue[apiAttr.Decimal]]) ([]api.GroupAverage[K], error) {
+	averages, err := process(ctx, chanSeq(a.groups), a.settings, func(r *router) error {
+		for values := range streets {
+			for _, street := range values.Items() {
+				q, err := r.queue(street)
+				if err != nil {
+					values.Release()
+					return err
+				}
+				if q == nil {
+					continue
+				}
+				if err := r.add(q, street.Value()); err != nil {
+					values.Release()
+					return err
+				}
+			}
+			values.Release()
+			if err := r.flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]api.GroupAverage[K], 0, len(averages))
+	for _, avg := range averages {
+		outputs = append(outputs, avg)
+	}
+	return outputs, nil
+}
+
+// AveragePrices averages the prices of the street attributes per group key text like
+// the Process method of NewAvgPriceBy, with the groups and the streets read from iterators.
+// The first error yielded by an iterator ends it and is returned.
+func AveragePrices(ctx context.Context, groups iter.Seq2[apiGroupify.StreetGroupItem, error],
+	streets iter.Seq2[apiAttr.StreetAttribute, error], opts ...Option) ([]api.AverageByGroup, error) {
+	return averagePrices(ctx, groups, seqSource(streets), newSettings(opts))
+}
+
+// AverageValues averages the decimal values per typed group key like the ProcessValues method
+// of NewAvgBy, with the groups and the values read from iterators.
+// The first error yielded by an iterator ends it and is returned.
+func AverageValues[K comparable](ctx context.Context, groups iter.Seq2[apiGroupify.GroupItem[K], error],
+	values iter.Seq2[apiAttr.StreetValue[apiAttr.Decimal], error], opts ...Option) ([]api.GroupAverage[K], error) {
+	return averageValues(ctx, groups, seqSource(values), newSettings(opts))
+}
+
+func averagePrices(ctx context.Context, groups iter.Seq2[apiGroupify.StreetGroupItem, error],
+	streets source[apiAttr.StreetAttribute], s settings) ([]api.AverageByGroup, error) {
+	keyed := func(yield func(apiGroupify.GroupItem[string], error) bool) {
+		for item, err := range groups {
+			if err != nil {
+				yield(nil, err)
 				return
-			default:
 			}
-			groupID, ok := streetToSize[street.StreetName()]
-			if !ok {
-				continue
+			if !yield(apiGroupify.GroupItemOf(item), nil) {
+				return
 			}
-			prices[groupID] <- street.AttributeValue()
 		}
-	}()
+	}
 
-	if err := eg.Wait(); err != nil {
+	averages, err := process(ctx, keyed, s, func(r *router) error {
+		for street, err := range streets(r) {
+			if err != nil {
+				return err
+			}
+			q, err := r.queue(street)
+			if err != nil {
+				return err
+			}
+			if q == nil {
+				continue
+			}
+			typed, err := apiAttr.StreetValueOf(street)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error parsing value", "value", street.AttributeValue(), "error", err)
+				return err
+			}
+			if err := r.add(q, typed.Value()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	outputs := make([]api.AverageByGroup, 0, len(averages))
+	for _, avg := range averages {
+		outputs = append(outputs, avg)
+	}
+	return outputs, nil
+}
 
-	// build outputs in recorded order
-	for _, id := range order {
-		if v, found := results.Load(id); found {
-			r := v.(result)
-			if r.err != nil {
-				return nil, r.err
+func averageValues[K comparable](ctx context.Context, groups iter.Seq2[apiGroupify.GroupItem[K], error],
+	values source[apiAttr.StreetValue[apiAttr.Decimal]], s settings) ([]api.GroupAverage[K], error) {
+	averages, err := process(ctx, groups, s, func(r *router) error {
+		for street, err := range values(r) {
+			if err != nil {
+				return err
+			}
+			q, err := r.queue(street)
+			if err != nil {
+				return err
+			}
+			if q == nil {
+				continue
+			}
+			if err := r.add(q, street.Value()); err != nil {
+				return err
 			}
-			outputs = append(outputs, avgByGroup{key: id, val: r.avg})
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	outputs := make([]api.GroupAverage[K], 0, len(averages))
+	for _, avg := range averages {
+		outputs = append(outputs, avg)
 	}
 	return outputs, nil
 }
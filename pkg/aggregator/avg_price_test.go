@@ -2,10 +2,21 @@ package aggregator
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	api "propertytreeanalyzer/pkg/api/aggregator"
 	apiAttr "propertytreeanalyzer/pkg/api/attribute"
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
 	apiGroupify "propertytreeanalyzer/pkg/api/groupify"
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+	"propertytreeanalyzer/pkg/batch"
 
 	"github.com/xyproto/randomstring"
 )
@@ -142,6 +153,49 @@ func TestProcess_NoData(t *testing.T) {
 	}
 }
 
+func TestProcess_GroupWithoutPrices(t *testing.T) {
+	// g2 gets no prices, e.g. all of them were filtered out → only g1 is averaged
+	groups := make(chan apiGroupify.StreetGroupItem, 2)
+	groups <- mockGroupItem{"g1", "s1"}
+	groups <- mockGroupItem{"g2", "s2"}
+	close(groups)
+	streets := make(chan apiAttr.StreetAttribute, 1)
+	streets <- mockStreetAttr{"s1", "5"}
+	close(streets)
+
+	out, err := NewAvgPriceBy(groups).Process(t.Context(), streets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].GroupKey() != "g1" || out[0].AverageValue() != "5.00" {
+		t.Errorf("averages = %v, want only g1 5.00", out)
+	}
+}
+
+// positionedAttr is a street attribute which knows where it was read
+type positionedAttr struct {
+	mockStreetAttr
+	pos apiStreams.Position
+}
+
+func (p positionedAttr) Position() apiStreams.Position { return p.pos }
+
+func TestProcess_InvalidPricePosition(t *testing.T) {
+	groups := make(chan apiGroupify.StreetGroupItem, 1)
+	groups <- mockGroupItem{"g1", "s1"}
+	close(groups)
+
+	streets := make(chan apiAttr.StreetAttribute, 2)
+	streets <- mockStreetAttr{"s1", "1"}
+	streets <- positionedAttr{mockStreetAttr{"s1", "1.2.3"}, apiStreams.Position{Source: "prices.csv", Line: 3, Column: 2}}
+	close(streets)
+
+	_, err := NewAvgPriceBy(groups).Process(t.Context(), streets)
+	if err == nil || !strings.HasPrefix(err.Error(), `prices.csv:3:2: invalid price "1.2.3"`) {
+		t.Errorf("Process() error = %v, want it prefixed with the price position", err)
+	}
+}
+
 func BenchmarkProcess(b *testing.B) {
 	const Ngroups = 5
 	const Nper = 10000
@@ -179,3 +233,324 @@ func BenchmarkProcess(b *testing.B) {
 		}
 	}
 }
+
+func TestProcess_Snapshots(t *testing.T) {
+	groups := make(chan apiGroupify.StreetGroupItem, 2)
+	groups <- mockGroupItem{"g1", "s1"}
+	groups <- mockGroupItem{"g2", "s2"}
+	close(groups)
+
+	reports := make(chan []api.AverageByGroup, 1)
+	agg := NewAvgPriceBy(groups, WithSnapshots(time.Millisecond, func(out []api.AverageByGroup) {
+		select {
+		case reports <- out:
+		default:
+		}
+	}))
+
+	streets := make(chan apiAttr.StreetAttribute)
+	done := make(chan error, 1)
+	go func() {
+		_, err := agg.Process(t.Context(), streets)
+		done <- err
+	}()
+	streets <- mockStreetAttr{"s1", "10"}
+	streets <- mockStreetAttr{"s1", "20"}
+
+	// g2 has no prices yet, so it is left out
+	deadline := time.After(5 * time.Second)
+	for {
+		var out []api.AverageByGroup
+		select {
+		case out = <-reports:
+		case <-deadline:
+			t.Fatal("no snapshot with the average of g1")
+		}
+		if len(out) == 1 && out[0].GroupKey() == "g1" && out[0].AverageValue() == "15.00" {
+			break
+		}
+	}
+
+	streets <- mockStreetAttr{"s2", "5"}
+	close(streets)
+	if err := <-done; err != nil {
+		t.Fatalf("Process error = %v", err)
+	}
+}
+
+// mockKeyedItem implements apiGroupify.GroupItem keyed by tree size.
+type mockKeyedItem struct {
+	key    apiGroupify.TreeSize
+	street string
+}
+
+func (m mockKeyedItem) GroupKey() apiGroupify.TreeSize     { return m.key }
+func (m mockKeyedItem) StreetName() apiGroupify.StreetName { return apiGroupify.StreetName(m.street) }
+
+// mockStreetValue implements apiAttr.StreetValue for decimals.
+type mockStreetValue struct {
+	street string
+	val    apiAttr.Decimal
+}
+
+func (m mockStreetValue) StreetName() string     { return m.street }
+func (m mockStreetValue) Value() apiAttr.Decimal { return m.val }
+
+// decimal parses s or fails the test.
+func decimal(t *testing.T, s string) apiAttr.Decimal {
+	t.Helper()
+	var d apiAttr.Decimal
+	if _, _, err := d.SetString(s); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestProcessValues(t *testing.T) {
+	groups := make(chan apiGroupify.GroupItem[apiGroupify.TreeSize], 3)
+	groups <- mockKeyedItem{apiGroupify.TreeSizeShort, "s1"}
+	groups <- mockKeyedItem{apiGroupify.TreeSizeTall, "t1"}
+	groups <- mockKeyedItem{apiGroupify.TreeSizeShort, "s2"}
+	close(groups)
+
+	streets := make(chan apiAttr.StreetValue[apiAttr.Decimal], 5)
+	streets <- mockStreetValue{"s1", decimal(t, "10")}
+	streets <- mockStreetValue{"s2", decimal(t, "25.5")}
+	streets <- mockStreetValue{"t1", decimal(t, "7")}
+	streets <- mockStreetValue{"unknown", decimal(t, "1000")}
+	close(streets)
+
+	out, err := NewAvgBy(groups).ProcessValues(t.Context(), streets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(out))
+	}
+	if out[0].Group() != apiGroupify.TreeSizeShort || out[0].GroupKey() != "short" {
+		t.Errorf("first group = %v (%q), want short", out[0].Group(), out[0].GroupKey())
+	}
+	avg := out[0].Average()
+	if avg.String() != "17.75" || out[0].AverageValue() != "17.75" {
+		t.Errorf("short avg = %s, want 17.75", avg.String())
+	}
+	if out[1].Group() != apiGroupify.TreeSizeTall || out[1].AverageValue() != "7.00" {
+		t.Errorf("second group = %v avg %s, want tall avg 7.00", out[1].Group(), out[1].AverageValue())
+	}
+}
+
+func TestStreetValueOf(t *testing.T) {
+	typed, err := apiAttr.StreetValueOf(mockStreetAttr{"s1", "12.50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := typed.Value(); typed.StreetName() != "s1" || v.String() != "12.50" {
+		t.Errorf("StreetValueOf = %s %s, want s1 12.50", typed.StreetName(), v.String())
+	}
+	if _, err := apiAttr.StreetValueOf(mockStreetAttr{"s1", "n/a"}); err == nil {
+		t.Error("StreetValueOf accepted an invalid price")
+	}
+	legacy := apiAttr.StreetAttributeOf[apiAttr.Decimal](mockStreetValue{"s1", decimal(t, "3.10")})
+	if legacy.AttributeValue() != "3.10" {
+		t.Errorf("StreetAttributeOf value = %q, want 3.10", legacy.AttributeValue())
+	}
+}
+
+func TestAverageValues(t *testing.T) {
+	groups := func(yield func(apiGroupify.GroupItem[apiGroupify.TreeSize], error) bool) {
+		_ = yield(mockKeyedItem{apiGroupify.TreeSizeShort, "s1"}, nil) &&
+			yield(mockKeyedItem{apiGroupify.TreeSizeTall, "t1"}, nil)
+	}
+	values := func(yield func(apiAttr.StreetValue[apiAttr.Decimal], error) bool) {
+		_ = yield(mockStreetValue{"s1", decimal(t, "1")}, nil) &&
+			yield(mockStreetValue{"s1", decimal(t, "2")}, nil) &&
+			yield(mockStreetValue{"t1", decimal(t, "9.99")}, nil)
+	}
+
+	out, err := AverageValues(t.Context(), groups, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(out))
+	}
+	if out[0].GroupKey() != "short" || out[0].AverageValue() != "1.50" {
+		t.Errorf("first group = %s avg %s, want short avg 1.50", out[0].GroupKey(), out[0].AverageValue())
+	}
+	if out[1].GroupKey() != "tall" || out[1].AverageValue() != "9.99" {
+		t.Errorf("second group = %s avg %s, want tall avg 9.99", out[1].GroupKey(), out[1].AverageValue())
+	}
+}
+
+func TestAveragePricesError(t *testing.T) {
+	wantErr := errors.New("read failed")
+	groups := func(yield func(apiGroupify.StreetGroupItem, error) bool) {
+		yield(mockGroupItem{"g1", "s1"}, nil)
+	}
+	streets := func(yield func(apiAttr.StreetAttribute, error) bool) {
+		_ = yield(mockStreetAttr{"s1", "1"}, nil) && yield(nil, wantErr)
+	}
+
+	if _, err := AveragePrices(t.Context(), groups, streets); !errors.Is(err, wantErr) {
+		t.Errorf("AveragePrices error = %v, want %v", err, wantErr)
+	}
+}
+
+// valueBatch implements apiBatch.Batch for testing.
+type valueBatch []apiAttr.StreetValue[apiAttr.Decimal]
+
+func (v valueBatch) Items() []apiAttr.StreetValue[apiAttr.Decimal] { return v }
+func (v valueBatch) Release()                                      {}
+
+func TestProcessBatches(t *testing.T) {
+	groups := make(chan apiGroupify.GroupItem[apiGroupify.TreeSize], 2)
+	groups <- mockKeyedItem{apiGroupify.TreeSizeShort, "s1"}
+	groups <- mockKeyedItem{apiGroupify.TreeSizeTall, "t1"}
+	close(groups)
+
+	streets := make(chan apiBatch.Batch[apiAttr.StreetValue[apiAttr.Decimal]], 2)
+	streets <- valueBatch{mockStreetValue{"s1", decimal(t, "1")}, mockStreetValue{"t1", decimal(t, "4")}, mockStreetValue{"s1", decimal(t, "2")}}
+	streets <- valueBatch{mockStreetValue{"unknown", decimal(t, "9")}, mockStreetValue{"s1", decimal(t, "3")}}
+	close(streets)
+
+	agg := NewAvgBy(groups, WithBatchSize(2)).(api.BatchAverageAggregator[apiGroupify.TreeSize])
+	out, err := agg.ProcessBatches(t.Context(), streets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(out))
+	}
+	if out[0].GroupKey() != "short" || out[0].AverageValue() != "2.00" {
+		t.Errorf("first group = %s avg %s, want short avg 2.00", out[0].GroupKey(), out[0].AverageValue())
+	}
+	if out[1].GroupKey() != "tall" || out[1].AverageValue() != "4.00" {
+		t.Errorf("second group = %s avg %s, want tall avg 4.00", out[1].GroupKey(), out[1].AverageValue())
+	}
+}
+
+func TestProcessBatchesCanceled(t *testing.T) {
+	groups := make(chan apiGroupify.GroupItem[apiGroupify.TreeSize], 1)
+	groups <- mockKeyedItem{apiGroupify.TreeSizeShort, "s1"}
+	close(groups)
+
+	// the source stops early when it is canceled, closing looks like the end of the input
+	streets := make(chan apiBatch.Batch[apiAttr.StreetValue[apiAttr.Decimal]], 1)
+	streets <- valueBatch{mockStreetValue{"s1", decimal(t, "1")}}
+	close(streets)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	agg := NewAvgBy(groups).(api.BatchAverageAggregator[apiGroupify.TreeSize])
+	if out, err := agg.ProcessBatches(ctx, streets); !errors.Is(err, context.Canceled) {
+		t.Errorf("ProcessBatches() = %v, %v, want %v", out, err, context.Canceled)
+	}
+}
+
+// BenchmarkTransport compares passing values to the aggregator one per channel operation
+// with passing them in batches
+func BenchmarkTransport(b *testing.B) {
+	const Ngroups = 5
+	const Nper = 100_000
+	streets := make([]string, Ngroups)
+	for i := range streets {
+		streets[i] = randomstring.HumanFriendlyEnglishString(15)
+	}
+	var price apiAttr.Decimal
+	price.SetInt64(100)
+	feedGroups := func() chan apiGroupify.GroupItem[apiGroupify.TreeSize] {
+		groups := make(chan apiGroupify.GroupItem[apiGroupify.TreeSize], Ngroups)
+		for i, street := range streets {
+			groups <- mockKeyedItem{apiGroupify.TreeSize(i%2 + 1), street}
+		}
+		close(groups)
+		return groups
+	}
+
+	b.Run("per-record", func(b *testing.B) {
+		for b.Loop() {
+			values := make(chan apiAttr.StreetValue[apiAttr.Decimal], 10000)
+			go func() {
+				defer close(values)
+				for i := range Ngroups * Nper {
+					values <- mockStreetValue{streets[i%Ngroups], price}
+				}
+			}()
+			if _, err := NewAvgBy(feedGroups()).ProcessValues(context.Background(), values); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	for _, size := range []int{256, 1024, 4096} {
+		b.Run(fmt.Sprintf("batch-%d", size), func(b *testing.B) {
+			for b.Loop() {
+				values := make(chan apiBatch.Batch[apiAttr.StreetValue[apiAttr.Decimal]], 16)
+				go func() {
+					defer close(values)
+					sender := batch.NewSender(context.Background(), values, batch.NewPool[apiAttr.StreetValue[apiAttr.Decimal]](size), 0)
+					for i := range Ngroups * Nper {
+						sender.Add(mockStreetValue{streets[i%Ngroups], price})
+					}
+					sender.Close()
+				}()
+				agg := NewAvgBy(feedGroups(), WithBatchSize(size)).(api.BatchAverageAggregator[apiGroupify.TreeSize])
+				if _, err := agg.ProcessBatches(context.Background(), values); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// rejectSink collects the rejected records
+type rejectSink struct {
+	mu      sync.Mutex
+	rejects []apiRejects.Reject
+}
+
+func (s *rejectSink) Reject(_ context.Context, r apiRejects.Reject) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejects = append(s.rejects, r)
+	return nil
+}
+
+// recordedAttr is a street attribute which keeps its input row
+type recordedAttr struct {
+	positionedAttr
+	record []string
+}
+
+func (r recordedAttr) Record() []string { return r.record }
+
+func TestProcess_Rejects(t *testing.T) {
+	groups := make(chan apiGroupify.StreetGroupItem, 1)
+	groups <- mockGroupItem{"g1", "s1"}
+	close(groups)
+
+	streets := make(chan apiAttr.StreetAttribute, 3)
+	streets <- mockStreetAttr{"s1", "10"}
+	streets <- mockStreetAttr{"s2", "20"}
+	streets <- recordedAttr{
+		positionedAttr{mockStreetAttr{"s3", "30"}, apiStreams.Position{Source: "prices.csv", Line: 4, Column: 2}},
+		[]string{"S3", "30"},
+	}
+	close(streets)
+
+	sink := &rejectSink{}
+	out, err := NewAvgPriceBy(groups, WithRejects(sink)).Process(t.Context(), streets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 1 || out[0].AverageValue() != "10.00" {
+		t.Errorf("averages = %v, want g1 10.00", out)
+	}
+
+	want := []apiRejects.Reject{
+		{Record: []string{"s2"}, Reason: apiRejects.UnmatchedStreet},
+		{Record: []string{"S3", "30"}, Pos: apiStreams.Position{Source: "prices.csv", Line: 4, Column: 2}, Reason: apiRejects.UnmatchedStreet},
+	}
+	if !reflect.DeepEqual(sink.rejects, want) {
+		t.Errorf("rejects = %v, want %v", sink.rejects, want)
+	}
+}
@@ -0,0 +1,29 @@
+package aggregators
+
+import (
+	"context"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
+)
+
+// GroupAverage is the typed average of a group. It also implements AverageByGroup,
+// GroupKey and AverageValue format the key and the average.
+type GroupAverage[K comparable] interface {
+	AverageByGroup
+	// Group returns the group key
+	Group() K
+	// Average returns the average rounded to cents
+	Average() attr.Decimal
+}
+
+// AverageAggregator averages typed values per typed group key, the typed form of AvgerageAggregator
+type AverageAggregator[K comparable] interface {
+	ProcessValues(ctx context.Context, streets <-chan attr.StreetValue[attr.Decimal]) ([]GroupAverage[K], error)
+}
+
+// BatchAverageAggregator averages typed values received in batches, consumers type-assert
+// an AverageAggregator to it. Every batch is released once its values are taken.
+type BatchAverageAggregator[K comparable] interface {
+	ProcessBatches(ctx context.Context, streets <-chan apiBatch.Batch[attr.StreetValue[attr.Decimal]]) ([]GroupAverage[K], error)
+}
@@ -0,0 +1,133 @@
+package attribute
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/apd/v3"
+
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+)
+
+// Decimal is an exact decimal value such as a price. Values are passed by copy and treated as immutable.
+type Decimal = apd.Decimal
+
+// Date is a calendar date without time of day or time zone
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+var _ fmt.Stringer = Date{}
+
+// DateOf returns the calendar date of t in its location
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// Time returns midnight UTC of the date
+func (d Date) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// Compare returns -1, 0 or +1 when d is before, equal to or after other
+func (d Date) Compare(other Date) int {
+	return d.Time().Compare(other.Time())
+}
+
+// IsZero reports whether the date is unset
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// String formats the date as yyyy-mm-dd
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// Dated is implemented by attributes and values which carry the date of their record, e.g. the sale
+// date of a price, so later stages can filter or group them by period
+type Dated interface {
+	// Date returns the date of the record, false when the record has none
+	Date() (Date, bool)
+}
+
+// Enum is a value from a closed set with a name, such as a tree size. Enums are used as group keys.
+type Enum interface {
+	comparable
+	fmt.Stringer
+}
+
+// StreetValue is a street name with a typed attribute value, the typed form of StreetAttribute.
+// Integer values are int64, decimals Decimal, dates Date and enumerations Enum types.
+type StreetValue[T any] interface {
+	// StreetName returns the name of the street
+	StreetName() string
+
+	// Value returns the attribute value
+	Value() T
+}
+
+// FormatValue formats a typed value the way AttributeValue presents it
+func FormatValue[T any](v T) string {
+	// Decimal formats itself through a pointer receiver
+	if s, ok := any(&v).(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v)
+}
+
+// streetValue adapts a StreetAttribute holding a decimal text to StreetValue
+type streetValue struct {
+	StreetAttribute
+	value Decimal
+}
+
+func (s streetValue) Value() Decimal { return s.value }
+
+// positionedStreetValue is a streetValue which keeps the position of its attribute
+type positionedStreetValue struct {
+	streetValue
+	apiStreams.Positioner
+}
+
+// StreetValueOf adapts a StreetAttribute to a decimal StreetValue. Attributes which already
+// are decimal StreetValues are returned as they are, otherwise AttributeValue is parsed.
+// The result implements Positioner when the attribute does.
+func StreetValueOf(a StreetAttribute) (StreetValue[Decimal], error) {
+	if typed, ok := a.(StreetValue[Decimal]); ok {
+		return typed, nil
+	}
+	s := streetValue{StreetAttribute: a}
+	if _, _, err := s.value.SetString(a.AttributeValue()); err != nil {
+		if positioner, ok := a.(apiStreams.Positioner); ok {
+			return nil, fmt.Errorf("%s: invalid price %q: %w", positioner.Position(), a.AttributeValue(), err)
+		}
+		return nil, fmt.Errorf("invalid price %q: %w", a.AttributeValue(), err)
+	}
+	if positioner, ok := a.(apiStreams.Positioner); ok {
+		return positionedStreetValue{s, positioner}, nil
+	}
+	return s, nil
+}
+
+// streetAttribute adapts a StreetValue to StreetAttribute
+type streetAttribute[T any] struct {
+	StreetValue[T]
+}
+
+func (s streetAttribute[T]) AttributeValue() string { return FormatValue(s.Value()) }
+
+func (s streetAttribute[T]) EqualTo(other StreetAttribute) bool {
+	return other != nil && s.StreetName() == other.StreetName() && s.AttributeValue() == other.AttributeValue()
+}
+
+// StreetAttributeOf adapts a StreetValue to the string based StreetAttribute, AttributeValue formats the value
+func StreetAttributeOf[T any](v StreetValue[T]) StreetAttribute {
+	if a, ok := v.(StreetAttribute); ok {
+		return a
+	}
+	return streetAttribute[T]{v}
+}
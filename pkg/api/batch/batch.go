@@ -0,0 +1,12 @@
+package batch
+
+// Batch is a slice of records passed between pipeline stages in one channel operation.
+// Batches are pooled: the receiver owns a batch until it calls Release and must not use
+// its items afterwards.
+type Batch[T any] interface {
+	// Items returns the records of the batch
+	Items() []T
+
+	// Release returns the batch to its pool for reuse
+	Release()
+}
@@ -0,0 +1,21 @@
+package groupify
+
+import (
+	"context"
+	"iter"
+)
+
+// StreetGroupIterator groups street names into an iterator instead of a channel,
+// so no goroutine is needed to consume them. Consumers type-assert a StreetGroups to it.
+type StreetGroupIterator interface {
+	// Items returns an iterator over the grouped streets. Grouping runs while the iterator
+	// is ranged over, an error is yielded once with a nil item and ends it.
+	Items(ctx context.Context) iter.Seq2[StreetGroupItem, error]
+}
+
+// KeyedStreetGroupIterator is the typed form of StreetGroupIterator
+type KeyedStreetGroupIterator[K comparable] interface {
+	// KeyedItems returns an iterator over the streets grouped under typed keys. Grouping runs
+	// while the iterator is ranged over, an error is yielded once with a nil item and ends it.
+	KeyedItems(ctx context.Context) iter.Seq2[GroupItem[K], error]
+}
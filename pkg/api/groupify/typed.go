@@ -0,0 +1,47 @@
+package groupify
+
+import (
+	"context"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+)
+
+// GroupItem is a street with the typed key of its group, the typed form of StreetGroupItem
+type GroupItem[K comparable] interface {
+	GroupKey() K
+	StreetName() StreetName
+}
+
+// KeyedStreetGroups groups street names under typed keys. Consumers type-assert
+// a StreetGroups to it, groupers of this module implement both.
+type KeyedStreetGroups[K comparable] interface {
+	// GroupKeyedStreets sends the grouped streets to dst and closes it when done or on error
+	GroupKeyedStreets(ctx context.Context, dst chan<- GroupItem[K]) error
+}
+
+// stringKeyItem adapts a StreetGroupItem to a GroupItem keyed by the key text
+type stringKeyItem struct {
+	StreetGroupItem
+}
+
+func (s stringKeyItem) GroupKey() string { return s.Key().String() }
+
+// GroupItemOf adapts a StreetGroupItem to a GroupItem keyed by the text of its key
+func GroupItemOf(item StreetGroupItem) GroupItem[string] {
+	return stringKeyItem{item}
+}
+
+// enumKeyItem adapts a GroupItem with an enumerated key to StreetGroupItem
+type enumKeyItem[K attr.Enum] struct {
+	GroupItem[K]
+}
+
+func (e enumKeyItem[K]) Key() attr.BaseAttribute { return e.GroupKey() }
+
+// StreetGroupItemOf adapts a GroupItem with an enumerated key to StreetGroupItem
+func StreetGroupItemOf[K attr.Enum](item GroupItem[K]) StreetGroupItem {
+	if legacy, ok := item.(StreetGroupItem); ok {
+		return legacy
+	}
+	return enumKeyItem[K]{item}
+}
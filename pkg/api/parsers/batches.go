@@ -0,0 +1,17 @@
+package parsers
+
+import (
+	"context"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
+)
+
+// StreetValueBatchParser parses typed street values in batches, so one channel operation passes
+// many records. Consumers type-assert a StreetAttributeParser to it.
+type StreetValueBatchParser[T any] interface {
+	// ParseValueBatches reads data from a source and sends batches of typed street values to the
+	// provided channel, the receiver releases every batch. The channel is closed when parsing is
+	// complete or an error occurs.
+	ParseValueBatches(ctx context.Context, out chan<- apiBatch.Batch[attr.StreetValue[T]]) error
+}
@@ -0,0 +1,23 @@
+package parsers
+
+import (
+	"context"
+	"iter"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+)
+
+// StreetAttributeIterator parses street attributes into an iterator instead of a channel,
+// so no goroutine is needed to consume them. Consumers type-assert a StreetAttributeParser to it.
+type StreetAttributeIterator interface {
+	// Attributes returns an iterator over the parsed street attributes. Parsing runs while
+	// the iterator is ranged over, an error is yielded once with a nil attribute and ends it.
+	Attributes(ctx context.Context) iter.Seq2[attr.StreetAttribute, error]
+}
+
+// StreetValueIterator is the typed form of StreetAttributeIterator
+type StreetValueIterator[T any] interface {
+	// Values returns an iterator over the parsed typed street values. Parsing runs while
+	// the iterator is ranged over, an error is yielded once with a nil value and ends it.
+	Values(ctx context.Context) iter.Seq2[attr.StreetValue[T], error]
+}
@@ -0,0 +1,41 @@
+package parsers
+
+import (
+	"context"
+	"iter"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+)
+
+// Record is a parsed row with typed fields named by the schema of its parser. It is the street
+// and the price of the row as well, so records are averaged like StreetAttributes.
+type Record interface {
+	attr.StreetAttribute
+	attr.StreetValue[attr.Decimal]
+
+	// Field returns the value of the named field: a string, a Decimal, a Date, a bool or the name
+	// of an enum value. It returns false for an empty field and for a name not in the schema.
+	Field(name string) (any, bool)
+}
+
+// RecordParser parses rows into records. Consumers type-assert a StreetAttributeParser to it.
+type RecordParser interface {
+	// ParseRecords reads data from a source and sends the records to the provided channel.
+	// The channel is closed when parsing is complete or an error occurs.
+	ParseRecords(ctx context.Context, out chan<- Record) error
+
+	// Records returns an iterator over the parsed records. Parsing runs while the iterator
+	// is ranged over, an error is yielded once with a nil record and ends it.
+	Records(ctx context.Context) iter.Seq2[Record, error]
+}
+
+// FieldOf returns the named field of the record when it is set and holds a T
+func FieldOf[T any](r Record, name string) (T, bool) {
+	v, ok := r.Field(name)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	typed, ok := v.(T)
+	return typed, ok
+}
@@ -0,0 +1,15 @@
+package parsers
+
+import (
+	"context"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+)
+
+// StreetValueParser parses typed street attributes, the typed form of StreetAttributeParser.
+// Consumers type-assert a StreetAttributeParser to it.
+type StreetValueParser[T any] interface {
+	// ParseValues reads data from a source and sends the typed street values to the provided
+	// channel. The channel is closed when parsing is complete or an error occurs.
+	ParseValues(ctx context.Context, out chan<- attr.StreetValue[T]) error
+}
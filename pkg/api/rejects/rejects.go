@@ -0,0 +1,52 @@
+package rejects
+
+import (
+	"context"
+
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+)
+
+// Reason is the code telling why a record was dropped
+type Reason string
+
+const (
+	// ShortRow is a record without the street or the price column
+	ShortRow Reason = "short_row"
+	// FieldCount is a record with another number of fields than the first one
+	FieldCount Reason = "field_count"
+	// EmptyPrice is a record whose price has no digits, e.g. "n/a"
+	EmptyPrice Reason = "empty_price"
+	// InvalidPrice is a price which cannot be read as a decimal
+	InvalidPrice Reason = "invalid_price"
+	// InvalidDate is a date which cannot be read with the configured layouts or does not exist, e.g. 31/02
+	InvalidDate Reason = "invalid_date"
+	// InvalidField is a value of another column which does not match its type, e.g. an impossible date
+	InvalidField Reason = "invalid_field"
+	// UnmatchedStreet is a record whose street is in no group
+	UnmatchedStreet Reason = "unmatched_street"
+	// Filtered is a record left out by a filter, e.g. a date range
+	Filtered Reason = "filtered"
+)
+
+// Reject is a record dropped by a pipeline stage
+type Reject struct {
+	// Record holds the fields of the input row in the order of the stream header,
+	// a Sink copies it to keep it
+	Record []string
+	// Pos locates the row in its source, the zero Position when unknown
+	Pos    apiStreams.Position
+	Reason Reason
+}
+
+// Sink receives the records dropped by the pipeline stages, it is safe for concurrent use
+type Sink interface {
+	// Reject records the dropped row, an error ends the stage
+	Reject(ctx context.Context, r Reject) error
+}
+
+// Recorder is implemented by values which keep the fields of the row they were read from,
+// so later stages can reject them whole
+type Recorder interface {
+	// Record returns the fields of the input row
+	Record() []string
+}
@@ -12,3 +12,12 @@ type CsvStream interface {
 	// This is typically the first row with column names.
 	GetHeader() []string
 }
+
+// RawCsvStream is implemented by CSV streams which can return records without copying their fields.
+// Consumers type-assert a CsvStream to it and fall back to ReadCsvRecord otherwise.
+type RawCsvStream interface {
+	// ReadRawRecord reads the next CSV record from the stream.
+	// The fields point into a buffer of the stream and are only valid until the next call,
+	// they must be copied to be kept.
+	ReadRawRecord(ctx context.Context) ([][]byte, error)
+}
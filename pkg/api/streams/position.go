@@ -0,0 +1,47 @@
+package streams
+
+import "strconv"
+
+// Position locates a record, a field or a token in its source
+type Position struct {
+	// Source is the name of the input, usually the file name. It may be empty.
+	Source string
+	// Line is the 1-based line or row number, zero when unknown
+	Line int
+	// Column is the 1-based field number within a record or the character column of a token,
+	// zero when unknown
+	Column int
+	// Offset is the byte offset from the beginning of the decoded input
+	Offset int64
+}
+
+// String formats the position as "source:line:column", unknown parts are left out
+func (p Position) String() string {
+	s := p.Source
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += strconv.Itoa(p.Line)
+		if p.Column > 0 {
+			s += ":" + strconv.Itoa(p.Column)
+		}
+	}
+	if s == "" {
+		s = "offset " + strconv.FormatInt(p.Offset, 10)
+	}
+	return s
+}
+
+// Positioner is implemented by streams which know where the last record or token was read.
+// Consumers type-assert a CsvStream or JsonStream to it for diagnostics.
+type Positioner interface {
+	// Position returns the location of the beginning of the last record or token
+	Position() Position
+}
+
+// FieldPositioner is implemented by record streams which can locate single fields
+type FieldPositioner interface {
+	// FieldPosition returns the location of the 0-based field of the last record
+	FieldPosition(field int) Position
+}
@@ -0,0 +1,43 @@
+package streams
+
+import (
+	"context"
+	"io"
+	"iter"
+)
+
+// RecordIterator is implemented by CSV streams which can be ranged over.
+// Consumers type-assert a CsvStream to it or use Records.
+type RecordIterator interface {
+	// Records returns an iterator over the remaining records. It ends at the end of the stream,
+	// an error is yielded once with a nil record and ends the iteration.
+	Records(ctx context.Context) iter.Seq2[[]string, error]
+}
+
+// Records returns an iterator over the remaining records of the stream, the stream's own
+// Records when it implements RecordIterator and ReadCsvRecord calls otherwise
+func Records(ctx context.Context, stream CsvStream) iter.Seq2[[]string, error] {
+	if it, ok := stream.(RecordIterator); ok {
+		return it.Records(ctx)
+	}
+	return ReadRecords(ctx, stream.ReadCsvRecord)
+}
+
+// ReadRecords returns an iterator calling read until it returns io.EOF or an error
+func ReadRecords(ctx context.Context, read func(context.Context) ([]string, error)) iter.Seq2[[]string, error] {
+	return func(yield func([]string, error) bool) {
+		for {
+			record, err := read(ctx)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
+)
+
+// DefaultSize is the number of records of a batch when no size is configured
+const DefaultSize = 1024
+
+// Batch is a pooled slice of records, it implements the api Batch
+type Batch[T any] struct {
+	items []T
+	pool  *Pool[T]
+}
+
+var _ apiBatch.Batch[int] = (*Batch[int])(nil)
+
+// Items implements Batch.
+func (b *Batch[T]) Items() []T {
+	return b.items
+}
+
+// Append adds v to the batch and reports whether the batch is full
+func (b *Batch[T]) Append(v T) bool {
+	b.items = append(b.items, v)
+	return len(b.items) >= b.pool.size
+}
+
+// Len returns the number of records in the batch
+func (b *Batch[T]) Len() int {
+	return len(b.items)
+}
+
+// Release implements Batch, the records are cleared so the pool keeps no references to them
+func (b *Batch[T]) Release() {
+	clear(b.items)
+	b.items = b.items[:0]
+	b.pool.pool.Put(b)
+}
+
+// Pool reuses batches of one size
+type Pool[T any] struct {
+	size int
+	pool sync.Pool
+}
+
+// NewPool creates a pool of batches holding size records, DefaultSize when size is not positive
+func NewPool[T any](size int) *Pool[T] {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	p := &Pool[T]{size: size}
+	p.pool.New = func() any {
+		return &Batch[T]{items: make([]T, 0, size), pool: p}
+	}
+	return p
+}
+
+// Size returns the number of records of a full batch
+func (p *Pool[T]) Size() int {
+	return p.size
+}
+
+// Get returns an empty batch
+func (p *Pool[T]) Get() *Batch[T] {
+	return p.pool.Get().(*Batch[T])
+}
+
+// Sender collects records into batches and sends them to a channel when they are full, on Flush
+// and, when it has a flush interval, once the interval passed so a slow source such as a followed
+// file does not hold records back
+type Sender[T any] struct {
+	ctx  context.Context
+	out  chan<- apiBatch.Batch[T]
+	pool *Pool[T]
+
+	// mu guards cur and keeps batches in order between Add and the interval flushes
+	mu  sync.Mutex
+	cur *Batch[T]
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSender creates a sender of batches from pool to out until ctx is done.
+// A positive interval flushes the pending records periodically, Close stops it.
+func NewSender[T any](ctx context.Context, out chan<- apiBatch.Batch[T], pool *Pool[T], interval time.Duration) *Sender[T] {
+	s := &Sender[T]{ctx: ctx, out: out, pool: pool, stop: make(chan struct{})}
+	if interval > 0 {
+		s.wg.Add(1)
+		go s.flushEvery(interval)
+	}
+	return s
+}
+
+func (s *Sender[T]) flushEvery(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if s.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// Add appends v to the pending batch and sends it when it is full.
+// It returns the context error once the context is done.
+func (s *Sender[T]) Add(v T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cur == nil {
+		s.cur = s.pool.Get()
+	}
+	if s.cur.Append(v) {
+		return s.send()
+	}
+	return nil
+}
+
+// Flush sends the pending records, if any
+func (s *Sender[T]) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cur == nil || s.cur.Len() == 0 {
+		return nil
+	}
+	return s.send()
+}
+
+// send passes the pending batch on, the caller holds mu
+func (s *Sender[T]) send() error {
+	b := s.cur
+	s.cur = nil
+	select {
+	case <-s.ctx.Done():
+		b.Release()
+		return s.ctx.Err()
+	case s.out <- b:
+		return nil
+	}
+}
+
+// Close stops the interval flushes and sends the pending records. It does not close the channel.
+func (s *Sender[T]) Close() error {
+	close(s.stop)
+	s.wg.Wait()
+	return s.Flush()
+}
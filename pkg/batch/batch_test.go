@@ -0,0 +1,85 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
+)
+
+func TestSenderBatches(t *testing.T) {
+	out := make(chan apiBatch.Batch[int], 10)
+	s := NewSender(t.Context(), out, NewPool[int](3), 0)
+	for i := range 7 {
+		if err := s.Add(i); err != nil {
+			t.Fatalf("Add error = %v", err)
+		}
+	}
+	if len(out) != 2 {
+		t.Errorf("%d batches sent before Close, want 2 full ones", len(out))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close error = %v", err)
+	}
+	close(out)
+
+	var got [][]int
+	for b := range out {
+		got = append(got, slices.Clone(b.Items()))
+		b.Release()
+	}
+	want := [][]int{{0, 1, 2}, {3, 4, 5}, {6}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("batches = %v, want %v", got, want)
+	}
+}
+
+func TestSenderFlushInterval(t *testing.T) {
+	out := make(chan apiBatch.Batch[string], 1)
+	s := NewSender(t.Context(), out, NewPool[string](100), time.Millisecond)
+	defer s.Close()
+	if err := s.Add("slow"); err != nil {
+		t.Fatalf("Add error = %v", err)
+	}
+	select {
+	case b := <-out:
+		if !slices.Equal(b.Items(), []string{"slow"}) {
+			t.Errorf("flushed batch = %q, want [slow]", b.Items())
+		}
+		b.Release()
+	case <-time.After(5 * time.Second):
+		t.Fatal("partial batch was not flushed")
+	}
+}
+
+func TestSenderCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	s := NewSender(ctx, make(chan apiBatch.Batch[int]), NewPool[int](1), 0)
+	if err := s.Add(1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Add error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPoolRelease(t *testing.T) {
+	p := NewPool[*int](2)
+	if p.Size() != 2 {
+		t.Errorf("Size = %d, want 2", p.Size())
+	}
+	b := p.Get()
+	v := 1
+	if b.Append(&v) {
+		t.Error("batch of one value is full")
+	}
+	if !b.Append(&v) {
+		t.Error("batch of two values is not full")
+	}
+	items := b.Items()
+	b.Release()
+	if items[0] != nil || b.Len() != 0 {
+		t.Error("released batch keeps its values")
+	}
+}
@@ -15,4 +15,33 @@ var (
 	errStreetColumnMissing           = errors.New("street column not found in CSV header")
 	errPriceColumnMissing            = errors.New("price column not found in CSV header")
 	errNilParserOrStream             = errors.New("parser or stream is nil")
+	errBatchSizeNotPositive          = errors.New("batch size must be positive")
+	errUnknownPriceLocale            = errors.New("unknown price locale")
+	errUnknownErrorPolicy            = errors.New("unknown error policy")
+	errShortRecord                   = errors.New("street or price column missing")
+	errDateColumnMissing             = errors.New("date column not found in CSV header")
+	errDateLayoutEmpty               = errors.New("date layout is empty")
+	errDateRangeEmpty                = errors.New("date range ends before it starts")
+	errDateRangeWithoutColumn        = errors.New("date range needs a date column")
+	errSchemaFieldName               = errors.New("schema field has no name")
+	errSchemaFieldRepeated           = errors.New("schema field name is repeated")
+	errSchemaEnumValues              = errors.New("schema enum field has no values")
+	errSchemaColumnMissing           = errors.New("schema column not found in CSV header")
+	errSchemaStreetPrice             = errors.New("schema needs a text street field and a decimal price field with columns")
+	errSchemaDate                    = errors.New("schema date is not a date field")
+
+	// reasons of invalid prices
+	errPriceText        = errors.New("unexpected text")
+	errPriceSeparator   = errors.New("misplaced separator")
+	errPriceGrouping    = errors.New("thousands separator")
+	errPriceDecimals    = errors.New("more than one decimal separator")
+	errPriceMinus       = errors.New("misplaced minus sign")
+	errPriceParentheses = errors.New("unbalanced parentheses")
+	errPriceCurrency    = errors.New("more than one currency")
+
+	// reasons of invalid schema fields
+	errFieldDecimal = errors.New("not a decimal")
+	errFieldDate    = errors.New("not a date")
+	errFieldBool    = errors.New("not yes or no")
+	errFieldEnum    = errors.New("not an accepted value")
 )
@@ -1,6 +1,13 @@
 package csvparser
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
+)
 
 // PriceParserOption configures a PriceParser
 type PriceParserOption func(*priceParser) error
@@ -61,3 +68,103 @@ func WithColIndexes(streetColIdx, priceColIdx int) PriceParserOption {
 		return nil
 	}
 }
+
+// WithBatchSize sets the number of values of a batch sent by ParseValueBatches, batch.DefaultSize by default
+func WithBatchSize(size int) PriceParserOption {
+	return func(p *priceParser) error {
+		if size <= 0 {
+			return errBatchSizeNotPositive
+		}
+		p.batchSize = size
+		return nil
+	}
+}
+
+// WithFlushInterval makes ParseValueBatches send a partial batch once the interval passed,
+// e.g. while a followed file grows slowly. Without it batches are sent only when full or at the end.
+func WithFlushInterval(interval time.Duration) PriceParserOption {
+	return func(p *priceParser) error {
+		p.flushInterval = interval
+		return nil
+	}
+}
+
+// WithPriceLocale sets how prices are written: "en-IE" (1,234.50), "de-DE" (1.234,50), "fr-FR" (1 234,50)
+// or AutoPriceLocale, the default, which infers the separators of every price.
+// Currency symbols and codes, accounting parentheses and k/M/bn suffixes are read in all locales.
+func WithPriceLocale(name string) PriceParserOption {
+	return func(p *priceParser) error {
+		locale, ok := lookupPriceLocale(name)
+		if !ok {
+			return fmt.Errorf("%w %q", errUnknownPriceLocale, name)
+		}
+		p.locale = locale
+		return nil
+	}
+}
+
+// WithErrorPolicy sets what the parser does with records it cannot read, SkipErrors by default
+func WithErrorPolicy(policy ErrorPolicy) PriceParserOption {
+	return func(p *priceParser) error {
+		if policy < SkipErrors || policy > CollectErrors {
+			return fmt.Errorf("%w %d", errUnknownErrorPolicy, int(policy))
+		}
+		p.policy = policy
+		return nil
+	}
+}
+
+// WithRejects passes every record the parser drops to the sink, the records which cannot be read
+// and the ones without a price. The parsed values keep their record, see rejects.Recorder,
+// so later stages can reject them too.
+func WithRejects(sink apiRejects.Sink) PriceParserOption {
+	return func(p *priceParser) error {
+		p.rejects = sink
+		return nil
+	}
+}
+
+// WithDateColumn reads the sale date of every record from the named column, see attr.Dated.
+// Dates are written dd/mm/yyyy unless WithDateLayouts is given, a date which cannot be read
+// or does not exist is an invalid_date error handled by the error policy.
+func WithDateColumn(name string) PriceParserOption {
+	return func(p *priceParser) error {
+		header := p.stream.GetHeader()
+		if len(header) == 0 {
+			return errNoHeader
+		}
+		if p.dateIdx = columnIndex(header, []string{name}); p.dateIdx < 0 {
+			return fmt.Errorf("%w: %q", errDateColumnMissing, name)
+		}
+		return nil
+	}
+}
+
+// WithDateLayouts sets the time.Parse layouts of the sale dates, tried in order,
+// DefaultDateLayout by default
+func WithDateLayouts(layouts ...string) PriceParserOption {
+	return func(p *priceParser) error {
+		for _, layout := range layouts {
+			if strings.TrimSpace(layout) == "" {
+				return errDateLayoutEmpty
+			}
+		}
+		if len(layouts) > 0 {
+			p.dateLayouts = append([]string(nil), layouts...)
+		}
+		return nil
+	}
+}
+
+// WithDateRange drops the records sold before from or after to, both days included.
+// A zero bound leaves the range open on its side. Records without a sale date are dropped
+// as well, the dropped records are rejected as filtered. It needs a date column.
+func WithDateRange(from, to attr.Date) PriceParserOption {
+	return func(p *priceParser) error {
+		if !from.IsZero() && !to.IsZero() && to.Compare(from) < 0 {
+			return fmt.Errorf("%w: %s to %s", errDateRangeEmpty, from, to)
+		}
+		p.from, p.to = from, to
+		return nil
+	}
+}
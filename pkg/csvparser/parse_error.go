@@ -0,0 +1,124 @@
+package csvparser
+
+import (
+	"fmt"
+	"strings"
+
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+)
+
+// Reason is the code of a ParseError, it is also the reason of the rejected record
+type Reason = apiRejects.Reason
+
+const (
+	// ReasonShortRow is a record without the street or the price column
+	ReasonShortRow = apiRejects.ShortRow
+	// ReasonFieldCount is a record with another number of fields than the first one
+	ReasonFieldCount = apiRejects.FieldCount
+	// ReasonInvalidPrice is a price which cannot be read as a decimal
+	ReasonInvalidPrice = apiRejects.InvalidPrice
+	// ReasonInvalidDate is a sale date which cannot be read or does not exist
+	ReasonInvalidDate = apiRejects.InvalidDate
+	// ReasonInvalidField is a value of a schema field which does not match its type
+	ReasonInvalidField = apiRejects.InvalidField
+)
+
+// ParseError is a record the parser could not read
+type ParseError struct {
+	// Row is the 1-based line of the record in its source, or the 1-based number of the
+	// record after the header when the stream cannot tell the line
+	Row int
+	// Column is the header name of the column of the value, empty for errors of the whole record
+	Column string
+	// Value is the raw value, or the fields of the record joined by commas
+	Value string
+	// Reason tells why the record was not read
+	Reason Reason
+	// Pos is the location of the value or the record when the stream can tell it
+	Pos apiStreams.Position
+	// Err is the underlying error
+	Err error
+}
+
+var _ error = (*ParseError)(nil)
+
+// Error formats the error as "position: subject "value": cause"
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.Pos != (apiStreams.Position{}) {
+		b.WriteString(e.Pos.String())
+		b.WriteString(": ")
+	}
+	switch e.Reason {
+	case ReasonInvalidPrice:
+		b.WriteString("invalid price")
+	case ReasonInvalidDate:
+		b.WriteString("invalid date")
+	case ReasonInvalidField:
+		b.WriteString("invalid ")
+		if e.Column != "" {
+			b.WriteString(e.Column)
+		} else {
+			b.WriteString("field")
+		}
+	case ReasonShortRow:
+		b.WriteString("short record")
+	default:
+		b.WriteString("record")
+	}
+	fmt.Fprintf(&b, " %q", e.Value)
+	if e.Err != nil {
+		b.WriteString(": ")
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns the underlying error
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorPolicy tells the parser what to do with records it cannot read
+type ErrorPolicy int
+
+const (
+	// SkipErrors drops records which cannot be read, the default
+	SkipErrors ErrorPolicy = iota
+	// FailOnError ends parsing with the first ParseError
+	FailOnError
+	// CollectErrors drops records which cannot be read and keeps their errors, see ErrorCollector
+	CollectErrors
+)
+
+var errorPolicyNames = [...]string{
+	SkipErrors:    "skip",
+	FailOnError:   "fail",
+	CollectErrors: "collect",
+}
+
+// String returns the name of the policy, "fail", "skip" or "collect"
+func (p ErrorPolicy) String() string {
+	if p < 0 || int(p) >= len(errorPolicyNames) {
+		return fmt.Sprintf("ErrorPolicy(%d)", int(p))
+	}
+	return errorPolicyNames[p]
+}
+
+// ParseErrorPolicy returns the policy with the name, "skip", "fail" or "collect"
+func ParseErrorPolicy(name string) (ErrorPolicy, error) {
+	for p, policyName := range errorPolicyNames {
+		if strings.EqualFold(strings.TrimSpace(name), policyName) {
+			return ErrorPolicy(p), nil
+		}
+	}
+	return SkipErrors, fmt.Errorf("%w %q", errUnknownErrorPolicy, name)
+}
+
+// ErrorCollector is implemented by the parsers of this package. Consumers type-assert
+// a parser to it to read the errors collected under the CollectErrors policy.
+type ErrorCollector interface {
+	// ParseErrors returns the errors collected so far, in the order of the records
+	ParseErrors() []*ParseError
+}
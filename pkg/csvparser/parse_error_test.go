@@ -0,0 +1,191 @@
+package csvparser
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiParser "propertytreeanalyzer/pkg/api/parsers"
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+	"propertytreeanalyzer/pkg/streams"
+)
+
+func TestErrorPolicies(t *testing.T) {
+	const data = "Street Name,Price\nMain Street,100\nOak Avenue,12 apples\nElm Road,200,extra\nAsh Lane,300\n"
+	constructors := map[string]func() (apiStreams.CsvStream, error){
+		"csv-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewCsvStream(strings.NewReader(data), streams.WithName("prices.csv"))
+		},
+		"mapped-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewMappedCsvStream(strings.NewReader(data), int64(len(data)), streams.WithName("prices.csv"))
+		},
+	}
+	for name, newStream := range constructors {
+		t.Run(name+"/fail", func(t *testing.T) {
+			stream, err := newStream()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithErrorPolicy(FailOnError))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parsePairs(t, parser)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("error = %v, want a ParseError", err)
+			}
+			if perr.Row != 3 || perr.Column != "Price" || perr.Value != "12 apples" || perr.Reason != ReasonInvalidPrice {
+				t.Errorf("ParseError = %+v, want row 3, column Price, value \"12 apples\", reason %s", perr, ReasonInvalidPrice)
+			}
+			if want := []string{"main street=100"}; !slices.Equal(got, want) {
+				t.Errorf("pairs = %v, want %v", got, want)
+			}
+		})
+
+		for _, policy := range []ErrorPolicy{SkipErrors, CollectErrors} {
+			t.Run(name+"/"+policy.String(), func(t *testing.T) {
+				stream, err := newStream()
+				if err != nil {
+					t.Fatal(err)
+				}
+				parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithErrorPolicy(policy))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := parsePairs(t, parser)
+				if err != nil {
+					t.Fatalf("error = %v", err)
+				}
+				if want := []string{"main street=100", "ash lane=300"}; !slices.Equal(got, want) {
+					t.Errorf("pairs = %v, want %v", got, want)
+				}
+
+				errs := parser.(ErrorCollector).ParseErrors()
+				if policy == SkipErrors {
+					if len(errs) != 0 {
+						t.Errorf("skip policy collected %v", errs)
+					}
+					return
+				}
+				if len(errs) != 2 {
+					t.Fatalf("collected %d errors, want 2: %v", len(errs), errs)
+				}
+				if e := errs[0]; e.Row != 3 || e.Reason != ReasonInvalidPrice || !errors.Is(e, errPriceText) {
+					t.Errorf("first error = %+v, want the invalid price of row 3", e)
+				}
+				if e := errs[1]; e.Row != 4 || e.Reason != ReasonFieldCount || e.Value != "Elm Road,200,extra" || !errors.Is(e, csv.ErrFieldCount) {
+					t.Errorf("second error = %+v, want the field count of row 4", e)
+				}
+			})
+		}
+	}
+}
+
+func TestParseErrorRowWithoutPositions(t *testing.T) {
+	stream := NewMockCsvStream([]string{"Street Name", "Price"}, [][]string{
+		{"Main Street", "100"},
+		{"Oak Avenue"},
+		{"Elm Road", "12 apples"},
+	})
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithErrorPolicy(CollectErrors))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parsePairs(t, parser); err != nil {
+		t.Fatalf("error = %v", err)
+	}
+	var rows []int
+	for _, e := range parser.(ErrorCollector).ParseErrors() {
+		rows = append(rows, e.Row)
+	}
+	// the records are numbered when the stream cannot tell their lines
+	if want := []int{2, 3}; !slices.Equal(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	for _, policy := range []ErrorPolicy{FailOnError, SkipErrors, CollectErrors} {
+		if got, err := ParseErrorPolicy(strings.ToUpper(policy.String())); err != nil || got != policy {
+			t.Errorf("ParseErrorPolicy(%q) = %v, %v, want %v", policy, got, err, policy)
+		}
+	}
+	if _, err := ParseErrorPolicy("ignore"); !errors.Is(err, errUnknownErrorPolicy) {
+		t.Errorf("ParseErrorPolicy(ignore) error = %v, want %v", err, errUnknownErrorPolicy)
+	}
+	if _, err := NewPriceParser(NewMockCsvStream(nil, nil), WithErrorPolicy(ErrorPolicy(7))); !errors.Is(err, errUnknownErrorPolicy) {
+		t.Errorf("WithErrorPolicy(7) error = %v, want %v", err, errUnknownErrorPolicy)
+	}
+}
+
+// rejectSink collects the rejected records
+type rejectSink struct {
+	rejects []apiRejects.Reject
+}
+
+func (s *rejectSink) Reject(_ context.Context, r apiRejects.Reject) error {
+	s.rejects = append(s.rejects, apiRejects.Reject{Record: slices.Clone(r.Record), Pos: r.Pos, Reason: r.Reason})
+	return nil
+}
+
+func TestWithRejects(t *testing.T) {
+	const data = "Street Name,Price\nMain Street,100\nOak Avenue,n/a\nElm Road,12 apples\nAsh Lane,1,2\n"
+	constructors := map[string]func() (apiStreams.CsvStream, error){
+		"csv-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewCsvStream(strings.NewReader(data))
+		},
+		"mapped-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewMappedCsvStream(strings.NewReader(data), int64(len(data)))
+		},
+	}
+	for name, newStream := range constructors {
+		t.Run(name, func(t *testing.T) {
+			stream, err := newStream()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := &rejectSink{}
+			parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithErrorPolicy(SkipErrors), WithRejects(sink))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var records [][]string
+			for value, err := range parser.(apiParser.StreetValueIterator[attr.Decimal]).Values(t.Context()) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				records = append(records, value.(apiRejects.Recorder).Record())
+			}
+			if want := [][]string{{"Main Street", "100"}}; !reflect.DeepEqual(records, want) {
+				t.Errorf("records of the values = %v, want %v", records, want)
+			}
+
+			want := []struct {
+				record []string
+				line   int
+				reason apiRejects.Reason
+			}{
+				{[]string{"Oak Avenue", "n/a"}, 3, apiRejects.EmptyPrice},
+				{[]string{"Elm Road", "12 apples"}, 4, apiRejects.InvalidPrice},
+				{[]string{"Ash Lane", "1", "2"}, 5, apiRejects.FieldCount},
+			}
+			if len(sink.rejects) != len(want) {
+				t.Fatalf("rejects = %v, want %d of them", sink.rejects, len(want))
+			}
+			for i, w := range want {
+				got := sink.rejects[i]
+				if !slices.Equal(got.Record, w.record) || got.Pos.Line != w.line || got.Reason != w.reason {
+					t.Errorf("reject %d = %+v, want %v at line %d for %s", i, got, w.record, w.line, w.reason)
+				}
+			}
+		})
+	}
+}
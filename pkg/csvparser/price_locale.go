@@ -0,0 +1,373 @@
+package csvparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/apd/v3"
+)
+
+// AutoPriceLocale infers the decimal and thousands separators of every price
+const AutoPriceLocale = "auto"
+
+// priceLocale describes how prices are written
+type priceLocale struct {
+	name string
+	// decimal is the decimal separator, zero when it is inferred per price
+	decimal rune
+	// grouping are the accepted thousands separators
+	grouping string
+}
+
+// priceLocales are the locales selectable with WithPriceLocale, spaces and apostrophes
+// group thousands in all of them
+var priceLocales = []priceLocale{
+	{name: AutoPriceLocale, grouping: " '"},
+	{name: "en-IE", decimal: '.', grouping: ", '"},
+	{name: "de-DE", decimal: ',', grouping: ". '"},
+	{name: "fr-FR", decimal: ',', grouping: " .'"},
+}
+
+// lookupPriceLocale finds a locale by name, "en_IE" and "en-ie" name "en-IE"
+func lookupPriceLocale(name string) (priceLocale, bool) {
+	name = strings.ReplaceAll(strings.TrimSpace(name), "_", "-")
+	for _, l := range priceLocales {
+		if strings.EqualFold(l.name, name) {
+			return l, true
+		}
+	}
+	return priceLocale{}, false
+}
+
+// currencyCodes are the ISO 4217 codes accepted around a price, symbols are any unicode.Sc rune
+var currencyCodes = []string{"EUR", "USD", "GBP", "CHF"}
+
+// priceMultipliers are the suffixes scaling a price, e.g. "1,5 M" or "250k"
+var priceMultipliers = map[string]int32{
+	"k":   3,
+	"m":   6,
+	"mn":  6,
+	"mio": 6,
+	"bn":  9,
+}
+
+// separator kinds of a price, spaces and apostrophes are folded into one kind each
+const (
+	sepSpace      = ' '
+	sepApostrophe = '\''
+)
+
+// priceScan is a price split into its digit runs and the separators between them.
+// A parser reuses one for all its prices.
+type priceScan struct {
+	// digits are the ASCII digits of all runs, ends the end offsets of the runs in digits
+	digits []byte
+	ends   []int
+	// seps are the separators between consecutive runs
+	seps     []rune
+	negative bool
+	// exponent scales the price by a multiplier suffix
+	exponent int32
+	letters  []byte
+}
+
+// run returns the digits of the i-th run
+func (s *priceScan) run(i int) []byte {
+	if i == 0 {
+		return s.digits[:s.ends[0]]
+	}
+	return s.digits[s.ends[i-1]:s.ends[i]]
+}
+
+// normalize turns the price text into a plain decimal such as "-1234.50", appended to buf.
+// Fields without any digit, e.g. "n/a", give an empty price and no error.
+func (l *priceLocale) normalize(field []byte, scan *priceScan, buf []byte) (string, []byte, error) {
+	buf = buf[:0]
+	if !hasDigit(field) {
+		return "", buf, nil
+	}
+	if err := scan.scan(field); err != nil {
+		return "", buf, err
+	}
+	decimal, err := l.decimalSeparator(scan)
+	if err != nil {
+		return "", buf, err
+	}
+
+	if scan.negative {
+		buf = append(buf, '-')
+	}
+	buf = append(buf, scan.run(0)...)
+	for i, sep := range scan.seps {
+		if sep == decimal {
+			buf = append(buf, '.')
+		}
+		buf = append(buf, scan.run(i+1)...)
+	}
+	if scan.exponent == 0 {
+		return string(buf), buf, nil
+	}
+	var d apd.Decimal
+	if _, _, err := d.SetString(string(buf)); err != nil {
+		return "", buf, err
+	}
+	d.Exponent += scan.exponent
+	return d.Text('f'), buf, nil
+}
+
+// decimalSeparator returns the decimal separator of the scanned price, zero for an integer.
+// It checks that the other separators group thousands.
+func (l *priceLocale) decimalSeparator(scan *priceScan) (rune, error) {
+	decimal := l.decimal
+	if decimal == 0 {
+		decimal = inferDecimal(scan)
+	}
+	found, grouped := false, false
+	for i, sep := range scan.seps {
+		switch {
+		case sep == decimal:
+			if found {
+				return 0, errPriceDecimals
+			}
+			found = true
+		case strings.ContainsRune(l.grouping, sep) || l.decimal == 0 && (sep == '.' || sep == ','):
+			if found {
+				return 0, fmt.Errorf("%w %q after the decimal separator", errPriceGrouping, sep)
+			}
+			if len(scan.run(i+1)) != 3 {
+				return 0, fmt.Errorf("%w %q not followed by three digits", errPriceGrouping, sep)
+			}
+			grouped = true
+		default:
+			return 0, fmt.Errorf("%w %q for locale %s", errPriceSeparator, sep, l.name)
+		}
+	}
+	if grouped && len(scan.run(0)) > 3 {
+		return 0, fmt.Errorf("%w %q after more than three digits", errPriceGrouping, scan.seps[0])
+	}
+	if !found {
+		return 0, nil
+	}
+	return decimal, nil
+}
+
+// inferDecimal guesses the decimal separator of a price written in an unknown locale. With dots and
+// commas the last one is decimal, "1.234.567,00". A separator used twice groups thousands, "1,234,567".
+// A single one before three digits groups thousands too, "1,500", unless a multiplier follows, "1,500 M",
+// or the lead is zero, "0.125".
+func inferDecimal(scan *priceScan) rune {
+	var (
+		last  rune
+		count int
+		at    int
+	)
+	for i, sep := range scan.seps {
+		if sep != '.' && sep != ',' {
+			continue
+		}
+		if sep == last {
+			count++
+		} else {
+			last, count = sep, 1
+		}
+		at = i
+	}
+	switch {
+	case last == 0:
+		return 0
+	case strings.ContainsRune(string(scan.seps), otherMark(last)):
+		return last
+	case count > 1:
+		return otherMark(last)
+	case len(scan.run(at+1)) == 3 && scan.exponent == 0 && string(scan.run(at)) != "0":
+		return otherMark(last)
+	}
+	return last
+}
+
+// otherMark returns the comma for a dot and the dot for a comma
+func otherMark(r rune) rune {
+	if r == '.' {
+		return ','
+	}
+	return '.'
+}
+
+// hasDigit reports whether the text has a decimal digit of any script
+func hasDigit(field []byte) bool {
+	for i := 0; i < len(field); {
+		r, size := utf8.DecodeRune(field[i:])
+		i += size
+		if _, ok := digitValue(r); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// scan splits the price text into digit runs and separators. Currency symbols and codes,
+// signs, accounting parentheses and a multiplier suffix may surround the digits.
+func (s *priceScan) scan(field []byte) error {
+	s.digits, s.ends, s.seps, s.letters = s.digits[:0], s.ends[:0], s.seps[:0], s.letters[:0]
+	s.negative, s.exponent = false, 0
+	var (
+		inRun    bool // the last rune was a digit
+		pending  rune // separator seen after the last run
+		started  bool // a digit was seen
+		ended    bool // text after the digits was seen
+		minus    bool
+		open     bool
+		closed   bool
+		currency bool
+	)
+	endRun := func() {
+		if inRun {
+			s.ends = append(s.ends, len(s.digits))
+			inRun = false
+		}
+	}
+
+	for i := 0; i < len(field); {
+		r, size := utf8.DecodeRune(field[i:])
+		i += size
+		if unicode.IsLetter(r) {
+			s.letters = utf8.AppendRune(s.letters, r)
+			if started {
+				endRun()
+				ended = true
+			}
+			continue
+		}
+		if err := s.flushLetters(started, &currency); err != nil {
+			return err
+		}
+		if d, isDigit := digitValue(r); isDigit {
+			if ended {
+				return fmt.Errorf("%w: digits after the price", errPriceText)
+			}
+			if started && !inRun {
+				s.seps = append(s.seps, pending)
+			}
+			pending, started, inRun = 0, true, true
+			s.digits = append(s.digits, '0'+d)
+			continue
+		}
+
+		switch {
+		case r == '.' || r == ',' || r == '\'' || r == '\u2019':
+			if r == '\u2019' {
+				r = sepApostrophe
+			}
+			if !started || ended {
+				return fmt.Errorf("%w %q outside the digits", errPriceSeparator, r)
+			}
+			if !inRun {
+				return fmt.Errorf("%w %q after %q", errPriceSeparator, r, pending)
+			}
+			endRun()
+			pending = r
+		case unicode.IsSpace(r):
+			// including no-break and thin spaces, "1\u202f300"
+			if inRun && !ended {
+				endRun()
+				pending = sepSpace
+			}
+		case r == '-' || r == '\u2212':
+			if minus || open {
+				return errPriceMinus
+			}
+			if started {
+				// a trailing minus, "5000-"
+				endRun()
+				ended = true
+			}
+			minus = true
+		case r == '+':
+			if started || minus {
+				return fmt.Errorf("%w %q", errPriceText, r)
+			}
+		case r == '(':
+			if started || open || minus {
+				return errPriceParentheses
+			}
+			open = true
+		case r == ')':
+			if !open || closed || !started {
+				return errPriceParentheses
+			}
+			endRun()
+			ended, closed = true, true
+		case unicode.Is(unicode.Sc, r):
+			if currency {
+				return fmt.Errorf("%w %q", errPriceCurrency, r)
+			}
+			if started {
+				endRun()
+				ended = true
+			}
+			currency = true
+		default:
+			return fmt.Errorf("%w %q", errPriceText, r)
+		}
+	}
+	if err := s.flushLetters(started, &currency); err != nil {
+		return err
+	}
+	endRun()
+	if pending != 0 && pending != sepSpace {
+		return fmt.Errorf("%w %q outside the digits", errPriceSeparator, pending)
+	}
+	if open != closed {
+		return errPriceParentheses
+	}
+	s.negative = minus || open
+	return nil
+}
+
+// flushLetters reads the word collected before a non-letter, a currency code or, after the digits,
+// a multiplier
+func (s *priceScan) flushLetters(started bool, currency *bool) error {
+	if len(s.letters) == 0 {
+		return nil
+	}
+	word := string(s.letters)
+	s.letters = s.letters[:0]
+	for _, code := range currencyCodes {
+		if strings.EqualFold(word, code) {
+			if *currency {
+				return fmt.Errorf("%w %q", errPriceCurrency, word)
+			}
+			*currency = true
+			return nil
+		}
+	}
+	if exp, ok := priceMultipliers[strings.ToLower(word)]; ok && started && s.exponent == 0 {
+		s.exponent = exp
+		return nil
+	}
+	return fmt.Errorf("%w %q", errPriceText, word)
+}
+
+// digitValue returns the value of a decimal digit of any script, e.g. '٣' is 3
+func digitValue(r rune) (byte, bool) {
+	if '0' <= r && r <= '9' {
+		return byte(r - '0'), true
+	}
+	if r < utf8.RuneSelf || !unicode.IsDigit(r) {
+		return 0, false
+	}
+	// the decimal digits of a script are consecutive from zero in the ranges of unicode.Nd
+	for _, rng := range unicode.Nd.R16 {
+		if uint32(rng.Lo) <= uint32(r) && uint32(r) <= uint32(rng.Hi) {
+			return byte((r - rune(rng.Lo)) % 10), true
+		}
+	}
+	for _, rng := range unicode.Nd.R32 {
+		if rng.Lo <= uint32(r) && uint32(r) <= rng.Hi {
+			return byte((r - rune(rng.Lo)) % 10), true
+		}
+	}
+	return 0, false
+}
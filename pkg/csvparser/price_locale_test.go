@@ -0,0 +1,124 @@
+package csvparser
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	apiParser "propertytreeanalyzer/pkg/api/parsers"
+	"propertytreeanalyzer/pkg/streams"
+)
+
+func TestNormalizePrice(t *testing.T) {
+	tests := []struct {
+		locale string
+		field  string
+		want   string
+		err    error
+	}{
+		{AutoPriceLocale, "100,000.00", "100000.00", nil},
+		{AutoPriceLocale, "€1.234.567,00", "1234567.00", nil},
+		{AutoPriceLocale, "(5,000)", "-5000", nil},
+		{AutoPriceLocale, "1,5 M", "1500000", nil},
+		{AutoPriceLocale, "250k", "250000", nil},
+		{AutoPriceLocale, "EUR 1,500", "1500", nil},
+		{AutoPriceLocale, "1 234,50 €", "1234.50", nil},
+		{AutoPriceLocale, "1 300", "1300", nil},
+		{AutoPriceLocale, "0.125", "0.125", nil},
+		{AutoPriceLocale, "-€5", "-5", nil},
+		{AutoPriceLocale, "5000-", "-5000", nil},
+		{AutoPriceLocale, "− 12.5", "-12.5", nil},
+		{AutoPriceLocale, "٣٠٠", "300", nil},
+		{AutoPriceLocale, "n/a", "", nil},
+		{AutoPriceLocale, "", "", nil},
+		{AutoPriceLocale, "1.234.56700", "", errPriceGrouping},
+		{AutoPriceLocale, "1-2", "", errPriceText},
+		{AutoPriceLocale, "12 apples", "", errPriceText},
+		{AutoPriceLocale, "(5,000", "", errPriceParentheses},
+		{AutoPriceLocale, "--5", "", errPriceMinus},
+		{AutoPriceLocale, "€5 $", "", errPriceCurrency},
+		{AutoPriceLocale, "1.,5", "", errPriceSeparator},
+		{"en-IE", "1,234.50", "1234.50", nil},
+		{"en-IE", "1.5", "1.5", nil},
+		{"en-IE", "1,5", "", errPriceGrouping},
+		{"en-IE", "1.234,50", "", errPriceGrouping},
+		{"de-DE", "1.234,50", "1234.50", nil},
+		{"de-DE", "1,500", "1.500", nil},
+		{"de-DE", "1.500", "1500", nil},
+		{"de-DE", "1,2,3", "", errPriceDecimals},
+		{"fr-FR", "1 234 567,89 €", "1234567.89", nil},
+		{"fr_fr", "12,5 k€", "12500", nil},
+		{"fr-FR", "12,5 k", "12500", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale+"/"+tt.field, func(t *testing.T) {
+			locale, ok := lookupPriceLocale(tt.locale)
+			if !ok {
+				t.Fatalf("locale %q not found", tt.locale)
+			}
+			var scan priceScan
+			got, _, err := locale.normalize([]byte(tt.field), &scan, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("normalize(%q) error = %v, want %v", tt.field, err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("normalize(%q) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+// parsePairs runs the parser and returns its pairs as "street=price"
+func parsePairs(t *testing.T, parser apiParser.StreetAttributeParser) ([]string, error) {
+	t.Helper()
+	var got []string
+	for pair, err := range parser.(apiParser.StreetAttributeIterator).Attributes(t.Context()) {
+		if err != nil {
+			return got, err
+		}
+		got = append(got, pair.StreetName()+"="+pair.AttributeValue())
+	}
+	return got, nil
+}
+
+func TestWithPriceLocale(t *testing.T) {
+	stream := NewMockCsvStream([]string{"Street Name", "Price"}, [][]string{{"Main Street", "1.234,50"}, {"Oak Avenue", "€ 2.000"}})
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithPriceLocale("de-DE"))
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	got, err := parsePairs(t, parser)
+	if err != nil {
+		t.Fatalf("Attributes() error = %v", err)
+	}
+	if want := []string{"main street=1234.50", "oak avenue=2000"}; !slices.Equal(got, want) {
+		t.Errorf("prices = %v, want %v", got, want)
+	}
+
+	if _, err := NewPriceParser(stream, WithPriceLocale("xx-XX")); !errors.Is(err, errUnknownPriceLocale) {
+		t.Errorf("NewPriceParser(WithPriceLocale(xx-XX)) error = %v, want %v", err, errUnknownPriceLocale)
+	}
+}
+
+func TestInvalidPriceReason(t *testing.T) {
+	data := "Street Name,Price\nMain Street,100\nOak Avenue,12 apples\n"
+	stream, err := streams.NewCsvStream(strings.NewReader(data), streams.WithName("prices.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithErrorPolicy(FailOnError))
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	got, err := parsePairs(t, parser)
+	if !errors.Is(err, errPriceText) {
+		t.Fatalf("Attributes() error = %v, want %v", err, errPriceText)
+	}
+	if want := `prices.csv:3:2: invalid price "12 apples": unexpected text "apples"`; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+	if want := []string{"main street=100"}; !slices.Equal(got, want) {
+		t.Errorf("prices = %v, want %v", got, want)
+	}
+}
@@ -1,26 +1,56 @@
 package csvparser
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
+	"errors"
 	"io"
+	"iter"
 	"log/slog"
+	"slices"
 	"strings"
-	"unicode"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
 	apiParser "propertytreeanalyzer/pkg/api/parsers"
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
 	apiStreams "propertytreeanalyzer/pkg/api/streams"
+	"propertytreeanalyzer/pkg/batch"
 )
 
 var (
-	_ attr.StreetAttribute            = (*streetPricePair)(nil)
-	_ apiParser.StreetAttributeParser = (*priceParser)(nil)
+	_ attr.StreetAttribute                        = (*streetPricePair)(nil)
+	_ attr.StreetValue[attr.Decimal]              = (*streetPricePair)(nil)
+	_ apiStreams.Positioner                       = (*streetPricePair)(nil)
+	_ apiRejects.Recorder                         = (*streetPricePair)(nil)
+	_ apiParser.StreetAttributeParser             = (*priceParser)(nil)
+	_ apiParser.StreetValueParser[attr.Decimal]   = (*priceParser)(nil)
+	_ apiParser.StreetAttributeIterator           = (*priceParser)(nil)
+	_ apiParser.StreetValueIterator[attr.Decimal] = (*priceParser)(nil)
+	_ apiParser.RecordParser                      = (*priceParser)(nil)
+	_ apiParser.Record                            = (*streetPricePair)(nil)
+	_ attr.Dated                                  = (*streetPricePair)(nil)
 )
 
 // streetPricePair represents a pair of street name and price
 type streetPricePair struct {
 	streetName string
 	price      string
+	// value is the price parsed once by the parser
+	value attr.Decimal
+	// pos is the location of the price field when the stream can tell it
+	pos apiStreams.Position
+	// date is the sale date when the parser has a date column
+	date attr.Date
+	// record holds the fields of the row when the parser has a rejects sink
+	record []string
+	// fields are the values of the schema fields of a record parser
+	fields []any
+	schema *boundSchema
 }
 
 // StreetName returns the name of the street
@@ -33,6 +63,38 @@ func (s streetPricePair) AttributeValue() string {
 	return s.price
 }
 
+// Value returns the price as a decimal
+func (s streetPricePair) Value() attr.Decimal {
+	return s.value
+}
+
+// Position returns the location of the price in the source
+func (s streetPricePair) Position() apiStreams.Position {
+	return s.pos
+}
+
+// Record returns the fields of the row the pair was read from, nil without WithRejects
+func (s streetPricePair) Record() []string {
+	return s.record
+}
+
+// Date returns the sale date, see attr.Dated
+func (s streetPricePair) Date() (attr.Date, bool) {
+	return s.date, !s.date.IsZero()
+}
+
+// Field returns the value of the named schema field, see apiParser.Record
+func (s streetPricePair) Field(name string) (any, bool) {
+	if s.schema == nil {
+		return nil, false
+	}
+	i, ok := s.schema.names[name]
+	if !ok || s.fields[i] == nil {
+		return nil, false
+	}
+	return s.fields[i], true
+}
+
 // EqualTo checks if two street price pairs are equal
 func (s streetPricePair) EqualTo(other attr.StreetAttribute) bool {
 	if other == nil {
@@ -50,6 +112,25 @@ type priceParser struct {
 	stream    apiStreams.CsvStream
 	streetIdx int
 	priceIdx  int
+	// locale tells how prices are written
+	locale priceLocale
+	// policy handles records which cannot be read, errs are the collected ones
+	policy ErrorPolicy
+	mu     sync.Mutex
+	errs   []*ParseError
+	// rejects receives the dropped records, nil without WithRejects
+	rejects apiRejects.Sink
+	// schema reads the other fields of the records of NewRecordParser, nil otherwise
+	schema *boundSchema
+	// dateIdx is the column of the sale dates, -1 without one; from and to bound them
+	dateIdx     int
+	dateLayouts []string
+	from, to    attr.Date
+	// batchSize and flushInterval configure ParseValueBatches
+	batchSize     int
+	flushInterval time.Duration
+	// records counts the records read, it numbers the rows of streams without positions
+	records int
 }
 
 // NewPriceParser creates a new price parser with the given CSV stream and column names
@@ -61,62 +142,325 @@ func NewPriceParser(stream apiStreams.CsvStream, opts ...PriceParserOption) (api
 		stream:    stream,
 		streetIdx: -1,
 		priceIdx:  -1,
+		locale:    priceLocales[0],
+		dateIdx:   -1,
 	}
 	for _, opt := range opts {
 		if err := opt(p); err != nil {
 			return nil, err
 		}
 	}
+	if p.dateIdx < 0 && (!p.from.IsZero() || !p.to.IsZero()) {
+		return nil, errDateRangeWithoutColumn
+	}
+	if len(p.dateLayouts) == 0 {
+		p.dateLayouts = []string{DefaultDateLayout}
+	}
 	return p, nil
 }
 
-// loadPrices reads the CSV stream and sends street name and price pairs to the provided channel
-// It processes the street name to lowercase and converts price strings to float64
-// The channel is closed when parsing is complete or an error occurs
-func (p *priceParser) loadPrices(ctx context.Context, out chan<- attr.StreetAttribute) error {
-	if p == nil || p.stream == nil {
-		close(out)
-		return errNilParserOrStream
+// loadPrices reads the CSV stream and passes street name and price pairs to emit
+// It processes the street name to lowercase and parses the price as a decimal.
+// It stops without an error when emit returns false.
+func (p *priceParser) loadPrices(ctx context.Context, emit func(streetPricePair) bool) error {
+	if raw, ok := p.stream.(apiStreams.RawCsvStream); ok {
+		return p.loadRawPrices(ctx, raw, emit)
 	}
 
-	defer close(out)
-
+	var (
+		field, buf []byte
+		scan       priceScan
+	)
 	for {
 		record, err := p.stream.ReadCsvRecord(ctx)
 		if err == io.EOF {
 			slog.InfoContext(ctx, "End of CSV stream")
 			return nil
 		}
+		p.records++
 		if err != nil {
-			return err
+			if record == nil || !errors.Is(err, csv.ErrFieldCount) {
+				return err
+			}
+			if err := p.drop(ctx, p.recordError(ReasonFieldCount, strings.Join(record, ","), csv.ErrFieldCount), record, nil); err != nil {
+				return err
+			}
+			continue
 		}
 
 		if len(record) <= p.streetIdx || len(record) <= p.priceIdx {
+			if err := p.drop(ctx, p.recordError(ReasonShortRow, strings.Join(record, ","), errShortRecord), record, nil); err != nil {
+				return err
+			}
 			continue
 		}
 
-		// drop everything that is not a digit, dot or minus in one pass
-		price := strings.Map(func(r rune) rune {
-			if unicode.IsDigit(r) || r == '.' || r == '-' {
-				return r
+		var (
+			pair streetPricePair
+			perr *ParseError
+		)
+		field = append(field[:0], record[p.priceIdx]...)
+		if buf, perr = p.readPrice(&pair, field, &scan, buf); perr != nil {
+			if err := p.drop(ctx, perr, record, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		if p.dateIdx >= 0 {
+			if perr = readDate(p, &pair, record); perr != nil {
+				if err := p.drop(ctx, perr, record, nil); err != nil {
+					return err
+				}
+				continue
 			}
-			return -1
-		}, record[p.priceIdx])
+		}
 
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			if len(price) != 0 {
-				out <- streetPricePair{
-					streetName: strings.ToLower(record[p.streetIdx]),
-					price:      price,
+			if len(pair.price) == 0 {
+				if err := p.reject(ctx, apiRejects.EmptyPrice, p.position(), record, nil); err != nil {
+					return err
 				}
+				continue
+			}
+			if !p.inRange(&pair) {
+				if err := p.reject(ctx, apiRejects.Filtered, p.position(), record, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			if p.schema != nil {
+				if buf, perr = readFields(p, &pair, record, &scan, buf); perr != nil {
+					if err := p.drop(ctx, perr, record, nil); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			pair.streetName = strings.ToLower(record[p.streetIdx])
+			if p.rejects != nil {
+				pair.record = slices.Clone(record)
+			}
+			if !emit(pair) {
+				return nil
 			}
 		}
 	}
 }
 
+// loadRawPrices is loadPrices for streams which return fields without copying them.
+// Only the street name and the price of a record are turned into strings, ASCII fields
+// are lowercased and normalized into a reused buffer so every string is allocated once.
+func (p *priceParser) loadRawPrices(ctx context.Context, stream apiStreams.RawCsvStream, emit func(streetPricePair) bool) error {
+	var (
+		buf  []byte
+		scan priceScan
+	)
+	for {
+		record, err := stream.ReadRawRecord(ctx)
+		if err == io.EOF {
+			slog.InfoContext(ctx, "End of CSV stream")
+			return nil
+		}
+		p.records++
+		if err != nil {
+			if record == nil || !errors.Is(err, csv.ErrFieldCount) {
+				return err
+			}
+			if err := p.drop(ctx, p.recordError(ReasonFieldCount, string(bytes.Join(record, []byte{','})), csv.ErrFieldCount), nil, record); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if len(record) <= p.streetIdx || len(record) <= p.priceIdx {
+			if err := p.drop(ctx, p.recordError(ReasonShortRow, string(bytes.Join(record, []byte{','})), errShortRecord), nil, record); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var (
+			pair streetPricePair
+			perr *ParseError
+		)
+		if buf, perr = p.readPrice(&pair, record[p.priceIdx], &scan, buf); perr != nil {
+			if err := p.drop(ctx, perr, nil, record); err != nil {
+				return err
+			}
+			continue
+		}
+		if p.dateIdx >= 0 {
+			if perr = readDate(p, &pair, record); perr != nil {
+				if err := p.drop(ctx, perr, nil, record); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+			if len(pair.price) == 0 {
+				if err := p.reject(ctx, apiRejects.EmptyPrice, p.position(), nil, record); err != nil {
+					return err
+				}
+				continue
+			}
+			if !p.inRange(&pair) {
+				if err := p.reject(ctx, apiRejects.Filtered, p.position(), nil, record); err != nil {
+					return err
+				}
+				continue
+			}
+			if p.schema != nil {
+				if buf, perr = readFields(p, &pair, record, &scan, buf); perr != nil {
+					if err := p.drop(ctx, perr, nil, record); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			pair.streetName, buf = lowerStreet(record[p.streetIdx], buf)
+			if p.rejects != nil {
+				pair.record = rawFields(record)
+			}
+			if !emit(pair) {
+				return nil
+			}
+		}
+	}
+}
+
+// readPrice normalizes the price field in the parser's locale and sets the price, its decimal
+// value and its position. A field without digits leaves the price empty.
+func (p *priceParser) readPrice(pair *streetPricePair, field []byte, scan *priceScan, buf []byte) ([]byte, *ParseError) {
+	var err error
+	pair.price, buf, err = p.locale.normalize(field, scan, buf)
+	if err == nil && pair.price == "" {
+		return buf, nil
+	}
+	if positioner, ok := p.stream.(apiStreams.FieldPositioner); ok {
+		pair.pos = positioner.FieldPosition(p.priceIdx)
+	}
+	if err == nil {
+		_, _, err = pair.value.SetString(pair.price)
+	}
+	if err != nil {
+		return buf, &ParseError{
+			Row:    p.row(pair.pos),
+			Column: p.column(p.priceIdx),
+			Value:  string(field),
+			Reason: ReasonInvalidPrice,
+			Pos:    pair.pos,
+			Err:    err,
+		}
+	}
+	return buf, nil
+}
+
+// recordError describes a record which cannot be read as a whole
+func (p *priceParser) recordError(reason Reason, value string, err error) *ParseError {
+	pos := p.position()
+	return &ParseError{Row: p.row(pos), Value: value, Reason: reason, Pos: pos, Err: err}
+}
+
+// row is the line of the position, or the number of the last record when the stream cannot tell it
+func (p *priceParser) row(pos apiStreams.Position) int {
+	if pos.Line > 0 {
+		return pos.Line
+	}
+	return p.records
+}
+
+// position returns the location of the last record when the stream can tell it
+func (p *priceParser) position() apiStreams.Position {
+	if positioner, ok := p.stream.(apiStreams.Positioner); ok {
+		return positioner.Position()
+	}
+	return apiStreams.Position{}
+}
+
+// column returns the header name of the column, empty when the stream has no header
+func (p *priceParser) column(idx int) string {
+	if header := p.stream.GetHeader(); idx < len(header) {
+		return header[idx]
+	}
+	return ""
+}
+
+// handle applies the error policy to a record which cannot be read.
+// It returns the error when parsing has to end.
+func (p *priceParser) handle(ctx context.Context, perr *ParseError) error {
+	switch p.policy {
+	case SkipErrors:
+		slog.DebugContext(ctx, "Skipping CSV record", "error", perr.Error(), "reason", string(perr.Reason))
+	case CollectErrors:
+		slog.DebugContext(ctx, "Skipping CSV record", "error", perr.Error(), "reason", string(perr.Reason))
+		p.mu.Lock()
+		p.errs = append(p.errs, perr)
+		p.mu.Unlock()
+	default:
+		return perr
+	}
+	return nil
+}
+
+// drop rejects a record which cannot be read and applies the error policy to its error.
+// The record is given as strings or, from a RawCsvStream, as raw fields.
+func (p *priceParser) drop(ctx context.Context, perr *ParseError, record []string, raw [][]byte) error {
+	if err := p.reject(ctx, perr.Reason, perr.Pos, record, raw); err != nil {
+		return err
+	}
+	return p.handle(ctx, perr)
+}
+
+// reject passes a dropped record to the rejects sink, if any
+func (p *priceParser) reject(ctx context.Context, reason Reason, pos apiStreams.Position, record []string, raw [][]byte) error {
+	if p.rejects == nil {
+		return nil
+	}
+	if record == nil {
+		record = rawFields(raw)
+	}
+	return p.rejects.Reject(ctx, apiRejects.Reject{Record: record, Pos: pos, Reason: reason})
+}
+
+// rawFields copies the raw fields of a record into strings
+func rawFields(raw [][]byte) []string {
+	record := make([]string, len(raw))
+	for i, field := range raw {
+		record[i] = string(field)
+	}
+	return record
+}
+
+// ParseErrors implements ErrorCollector.
+func (p *priceParser) ParseErrors() []*ParseError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.errs)
+}
+
+// lowerStreet is strings.ToLower for a raw field, using buf for ASCII input
+func lowerStreet(field, buf []byte) (string, []byte) {
+	buf = buf[:0]
+	for _, c := range field {
+		switch {
+		case c >= utf8.RuneSelf:
+			return strings.ToLower(string(field)), buf
+		case 'A' <= c && c <= 'Z':
+			c += 'a' - 'A'
+		}
+		buf = append(buf, c)
+	}
+	return string(buf), buf
+}
+
 // ParseAttributes reads the CSV stream and sends street attribute pairs to the provided channel
 // It implements the StreetAttributeParser interface method
 func (p *priceParser) ParseAttributes(ctx context.Context, out chan<- attr.StreetAttribute) error {
@@ -124,5 +468,98 @@ func (p *priceParser) ParseAttributes(ctx context.Context, out chan<- attr.Stree
 		close(out)
 		return errNilParserOrStream
 	}
-	return p.loadPrices(ctx, out)
+	defer close(out)
+	return p.loadPrices(ctx, func(pair streetPricePair) bool {
+		out <- pair
+		return true
+	})
+}
+
+// ParseValues implements StreetValueParser, the values are the prices as decimals
+func (p *priceParser) ParseValues(ctx context.Context, out chan<- attr.StreetValue[attr.Decimal]) error {
+	if p == nil || p.stream == nil {
+		close(out)
+		return errNilParserOrStream
+	}
+	defer close(out)
+	return p.loadPrices(ctx, func(pair streetPricePair) bool {
+		out <- pair
+		return true
+	})
+}
+
+// Attributes implements StreetAttributeIterator.
+func (p *priceParser) Attributes(ctx context.Context) iter.Seq2[attr.StreetAttribute, error] {
+	return func(yield func(attr.StreetAttribute, error) bool) {
+		if p == nil || p.stream == nil {
+			yield(nil, errNilParserOrStream)
+			return
+		}
+		if err := p.loadPrices(ctx, func(pair streetPricePair) bool { return yield(pair, nil) }); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// Values implements StreetValueIterator, the values are the prices as decimals
+func (p *priceParser) Values(ctx context.Context) iter.Seq2[attr.StreetValue[attr.Decimal], error] {
+	return func(yield func(attr.StreetValue[attr.Decimal], error) bool) {
+		if p == nil || p.stream == nil {
+			yield(nil, errNilParserOrStream)
+			return
+		}
+		if err := p.loadPrices(ctx, func(pair streetPricePair) bool { return yield(pair, nil) }); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// ParseRecords implements RecordParser, the records have the fields of the schema of NewRecordParser
+func (p *priceParser) ParseRecords(ctx context.Context, out chan<- apiParser.Record) error {
+	if p == nil || p.stream == nil {
+		close(out)
+		return errNilParserOrStream
+	}
+	defer close(out)
+	return p.loadPrices(ctx, func(pair streetPricePair) bool {
+		out <- pair
+		return true
+	})
+}
+
+// Records implements RecordParser.
+func (p *priceParser) Records(ctx context.Context) iter.Seq2[apiParser.Record, error] {
+	return func(yield func(apiParser.Record, error) bool) {
+		if p == nil || p.stream == nil {
+			yield(nil, errNilParserOrStream)
+			return
+		}
+		if err := p.loadPrices(ctx, func(pair streetPricePair) bool { return yield(pair, nil) }); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// ParseValueBatches implements StreetValueBatchParser, the values are the prices as decimals.
+// Batches hold WithBatchSize values, a partial batch is sent after the WithFlushInterval interval.
+func (p *priceParser) ParseValueBatches(ctx context.Context, out chan<- apiBatch.Batch[attr.StreetValue[attr.Decimal]]) error {
+	if p == nil || p.stream == nil {
+		close(out)
+		return errNilParserOrStream
+	}
+	defer close(out)
+	sender := batch.NewSender(ctx, out, batch.NewPool[attr.StreetValue[attr.Decimal]](p.batchSize), p.flushInterval)
+	var addErr error
+	err := p.loadPrices(ctx, func(pair streetPricePair) bool {
+		addErr = sender.Add(pair)
+		return addErr == nil
+	})
+	if err == nil {
+		// the values not passed on would make the averages partial
+		err = addErr
+	}
+	if closeErr := sender.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
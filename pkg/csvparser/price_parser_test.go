@@ -3,12 +3,20 @@ package csvparser
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"io"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"propertytreeanalyzer/internal/registertest"
 	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiBatch "propertytreeanalyzer/pkg/api/batch"
+	apiParser "propertytreeanalyzer/pkg/api/parsers"
 	apiStreams "propertytreeanalyzer/pkg/api/streams"
+	"propertytreeanalyzer/pkg/streams"
 )
 
 // MockCsvStream is a mock implementation of the CsvStream interface for testing
@@ -202,6 +210,7 @@ func TestParsePrices(t *testing.T) {
 		records       [][]string
 		streetColName string
 		priceColName  string
+		policy        ErrorPolicy
 		expectedPairs []streetPricePair
 		expectedError error
 	}{
@@ -250,12 +259,21 @@ func TestParsePrices(t *testing.T) {
 			},
 			expectedError: nil,
 		},
+		{
+			name:          "Missing field in record - fails",
+			header:        []string{"Date", "Address", "Street Name", "Price"},
+			records:       [][]string{{"01/01/2023", "123 Main St"}, {"02/01/2023", "456 Oak Ave", "oak avenue", "200,000.00"}},
+			streetColName: "Street Name",
+			priceColName:  "Price",
+			policy:        FailOnError,
+			expectedError: errShortRecord,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			stream := NewMockCsvStream(tt.header, tt.records)
-			parser, err := NewPriceParser(stream, WithColNames(tt.streetColName, tt.priceColName))
+			parser, err := NewPriceParser(stream, WithColNames(tt.streetColName, tt.priceColName), WithErrorPolicy(tt.policy))
 			if err != nil {
 				t.Fatalf("Failed to create parser: %v", err)
 			}
@@ -381,6 +399,159 @@ func TestStreamIntegration(t *testing.T) {
 	}
 }
 
+func TestParseAttributesPosition(t *testing.T) {
+	data := "Street Name,Price\nMain Street,100\n\"Oak\nAvenue\",200\n"
+	stream, err := streams.NewCsvStream(strings.NewReader(data), streams.WithName("prices.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(chan attr.StreetAttribute, 2)
+	if err := parser.ParseAttributes(t.Context(), out); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for pair := range out {
+		got = append(got, pair.(apiStreams.Positioner).Position().String())
+	}
+	want := []string{"prices.csv:2:2", "prices.csv:4:2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("price positions = %v, want %v", got, want)
+	}
+}
+
+func TestParseAttributesHeaderless(t *testing.T) {
+	data := "05/01/2024,Main Street,100\n11/02/2024,Oak Avenue,200\n"
+	tests := map[string]struct {
+		streamOpt streams.Option
+		parserOpt PriceParserOption
+	}{
+		"indexes":         {streams.WithSyntheticHeader(), WithColIndexes(1, 2)},
+		"synthetic names": {streams.WithSyntheticHeader(), WithColNames("col2", "col3")},
+		"given names":     {streams.WithHeader("date", "street", "price"), WithColNames("street", "price")},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			stream, err := streams.NewCsvStream(strings.NewReader(data), tt.streamOpt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser, err := NewPriceParser(stream, tt.parserOpt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := make(chan attr.StreetAttribute, 2)
+			if err := parser.ParseAttributes(t.Context(), out); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for pair := range out {
+				got = append(got, pair.StreetName()+"="+pair.AttributeValue())
+			}
+			if want := []string{"main street=100", "oak avenue=200"}; !slices.Equal(got, want) {
+				t.Errorf("pairs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseAttributesRaw(t *testing.T) {
+	data := "Street Name,Price\nMAIN Street,\"€1,000.50\"\nSráid MHUIRE,200\nOak Avenue,n/a\nOak Avenue,\"٣٠٠\"\n"
+	parse := func(stream apiStreams.CsvStream) []string {
+		t.Helper()
+		parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make(chan attr.StreetAttribute, 8)
+		if err := parser.ParseAttributes(t.Context(), out); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for pair := range out {
+			got = append(got, pair.StreetName()+"="+pair.AttributeValue())
+		}
+		return got
+	}
+
+	sequential, err := streams.NewCsvStream(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapped, err := streams.NewMappedCsvStream(strings.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mapped.(apiStreams.RawCsvStream); !ok {
+		t.Fatal("mapped stream is not a RawCsvStream")
+	}
+	// prices in other digits are read since the locale parser, "٣٠٠" is 300
+	want := []string{"main street=1000.50", "sráid mhuire=200", "oak avenue=300"}
+	if got := parse(sequential); !slices.Equal(got, want) {
+		t.Errorf("pairs of the CSV stream = %v, want %v", got, want)
+	}
+	if got := parse(mapped); !slices.Equal(got, want) {
+		t.Errorf("pairs of the mapped stream = %v, want %v", got, want)
+	}
+}
+
+// TestParseAttributesInvalidPrice checks that a price the parser cannot read stops the parser
+// with its error by default and is dropped when errors are skipped, on both stream kinds
+func TestParseAttributesInvalidPrice(t *testing.T) {
+	data := "Street Name,Price\nMain Street,100\nOak Avenue,\"1,50,000\"\nElm Road,\"1\u00a0300 €\"\n"
+	newStream := map[string]func() (apiStreams.CsvStream, error){
+		"csv-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewCsvStream(strings.NewReader(data))
+		},
+		"mapped-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewMappedCsvStream(strings.NewReader(data), int64(len(data)))
+		},
+	}
+	parse := func(t *testing.T, open func() (apiStreams.CsvStream, error), policy ErrorPolicy) ([]string, error) {
+		t.Helper()
+		stream, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithErrorPolicy(policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make(chan attr.StreetAttribute, 8)
+		err = parser.ParseAttributes(t.Context(), out)
+		var got []string
+		for pair := range out {
+			got = append(got, pair.StreetName()+"="+pair.AttributeValue())
+		}
+		return got, err
+	}
+
+	for name, open := range newStream {
+		t.Run(name, func(t *testing.T) {
+			got, err := parse(t, open, FailOnError)
+			var perr *ParseError
+			if !errors.As(err, &perr) || perr.Reason != ReasonInvalidPrice || perr.Value != "1,50,000" || perr.Row != 3 {
+				t.Fatalf("ParseAttributes error = %v, want an invalid price at row 3", err)
+			}
+			if want := []string{"main street=100"}; !slices.Equal(got, want) {
+				t.Errorf("pairs before the error = %v, want %v", got, want)
+			}
+
+			got, err = parse(t, open, SkipErrors)
+			if err != nil {
+				t.Fatalf("ParseAttributes skipping errors = %v", err)
+			}
+			if want := []string{"main street=100", "elm road=1300"}; !slices.Equal(got, want) {
+				t.Errorf("pairs skipping errors = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
 // testCsvStream implements a simple CsvStream for testing
 type testCsvStream struct {
 	reader  *strings.Reader
@@ -433,3 +604,222 @@ func (t *testCsvStream) ReadCsvRecord(_ context.Context) ([]string, error) {
 
 	return strings.Split(line, ","), nil
 }
+
+var benchRows = flag.Int("bench-rows", registertest.DefaultRows, "rows of the synthetic register file read by BenchmarkParseAttributes")
+
+// BenchmarkParseAttributes parses a synthetic register file through encoding/csv and through
+// the mapped tokenizer, -bench-rows sets the size of the file
+func BenchmarkParseAttributes(b *testing.B) {
+	file, size := registertest.RegisterFile(b, *benchRows)
+
+	for name, open := range map[string]func() (apiStreams.CsvStream, error){
+		"csv-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewCsvStream(io.NewSectionReader(file, 0, size))
+		},
+		"mapped-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewMappedCsvStream(file, size, streams.WithFields("Street Name", "Price (€)"))
+		},
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for b.Loop() {
+				stream, err := open()
+				if err != nil {
+					b.Fatal(err)
+				}
+				parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price (€)"))
+				if err != nil {
+					b.Fatal(err)
+				}
+				out := make(chan attr.StreetAttribute, 1024)
+				go func() {
+					for range out {
+					}
+				}()
+				if err := parser.ParseAttributes(context.Background(), out); err != nil {
+					b.Fatal(err)
+				}
+				if closer, ok := stream.(io.Closer); ok {
+					closer.Close()
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkParseValues compares sending the parsed prices one per channel operation
+// with sending them in batches
+func BenchmarkParseValues(b *testing.B) {
+	file, fileSize := registertest.RegisterFile(b, *benchRows)
+	parse := func(b *testing.B, opts ...PriceParserOption) (apiParser.StreetAttributeParser, io.Closer) {
+		stream, err := streams.NewMappedCsvStream(file, fileSize, streams.WithFields("Street Name", "Price (€)"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		parser, err := NewPriceParser(stream, append(opts, WithColNames("Street Name", "Price (€)"))...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return parser, stream.(io.Closer)
+	}
+
+	b.Run("per-record", func(b *testing.B) {
+		b.SetBytes(fileSize)
+		b.ReportAllocs()
+		for b.Loop() {
+			out := make(chan attr.StreetValue[attr.Decimal], 1024)
+			go func() {
+				for range out {
+				}
+			}()
+			parser, closer := parse(b)
+			if err := parser.(apiParser.StreetValueParser[attr.Decimal]).ParseValues(context.Background(), out); err != nil {
+				b.Fatal(err)
+			}
+			closer.Close()
+		}
+	})
+	for _, size := range []int{256, 1024, 4096} {
+		b.Run(fmt.Sprintf("batch-%d", size), func(b *testing.B) {
+			b.SetBytes(fileSize)
+			b.ReportAllocs()
+			for b.Loop() {
+				out := make(chan apiBatch.Batch[attr.StreetValue[attr.Decimal]], 16)
+				go func() {
+					for values := range out {
+						values.Release()
+					}
+				}()
+				parser, closer := parse(b, WithBatchSize(size))
+				if err := parser.(apiParser.StreetValueBatchParser[attr.Decimal]).ParseValueBatches(context.Background(), out); err != nil {
+					b.Fatal(err)
+				}
+				closer.Close()
+			}
+		})
+	}
+}
+
+func TestParseValueBatches(t *testing.T) {
+	stream := NewMockCsvStream([]string{"Street Name", "Price"}, [][]string{{"Main Street", "1"}, {"Oak Avenue", "2"}, {"Elm Road", "3"}})
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithBatchSize(2))
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+
+	out := make(chan apiBatch.Batch[attr.StreetValue[attr.Decimal]], 10)
+	if err := parser.(apiParser.StreetValueBatchParser[attr.Decimal]).ParseValueBatches(t.Context(), out); err != nil {
+		t.Fatalf("ParseValueBatches() error = %v", err)
+	}
+	var sizes []int
+	var got []string
+	for values := range out {
+		sizes = append(sizes, len(values.Items()))
+		for _, v := range values.Items() {
+			val := v.Value()
+			got = append(got, v.StreetName()+"="+val.String())
+		}
+		values.Release()
+	}
+	if want := []int{2, 1}; !slices.Equal(sizes, want) {
+		t.Errorf("batch sizes = %v, want %v", sizes, want)
+	}
+	if want := []string{"main street=1", "oak avenue=2", "elm road=3"}; !slices.Equal(got, want) {
+		t.Errorf("ParseValueBatches() = %v, want %v", got, want)
+	}
+	if _, err := NewPriceParser(stream, WithBatchSize(0)); !errors.Is(err, errBatchSizeNotPositive) {
+		t.Errorf("NewPriceParser(WithBatchSize(0)) error = %v, want %v", err, errBatchSizeNotPositive)
+	}
+}
+
+func TestParseValueBatchesCanceled(t *testing.T) {
+	stream := NewMockCsvStream([]string{"Street Name", "Price"}, [][]string{{"Main Street", "1"}, {"Oak Avenue", "2"}})
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithBatchSize(1))
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	// nobody receives, the first batch waits for the cancellation
+	out := make(chan apiBatch.Batch[attr.StreetValue[attr.Decimal]])
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := parser.(apiParser.StreetValueBatchParser[attr.Decimal]).ParseValueBatches(ctx, out); !errors.Is(err, context.Canceled) {
+		t.Errorf("ParseValueBatches() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	stream := NewMockCsvStream([]string{"Street Name", "Price"}, [][]string{{"Main Street", "€100,000.50"}, {"Oak Avenue", ""}, {"Elm Road", "250000"}})
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"))
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	values, ok := parser.(apiParser.StreetValueParser[attr.Decimal])
+	if !ok {
+		t.Fatal("price parser does not implement StreetValueParser")
+	}
+
+	out := make(chan attr.StreetValue[attr.Decimal], 10)
+	if err := values.ParseValues(t.Context(), out); err != nil {
+		t.Fatalf("ParseValues() error = %v", err)
+	}
+	var got []string
+	for v := range out {
+		val := v.Value()
+		got = append(got, v.StreetName()+"="+val.String())
+	}
+	want := []string{"main street=100000.50", "elm road=250000"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseValues() = %v, want %v", got, want)
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	stream := NewMockCsvStream([]string{"Street Name", "Price"}, [][]string{{"Main Street", "100"}, {"Oak Avenue", "200"}, {"Elm Road", "300"}})
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"))
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+	it, ok := parser.(apiParser.StreetAttributeIterator)
+	if !ok {
+		t.Fatal("price parser does not implement StreetAttributeIterator")
+	}
+
+	var got []string
+	for a, err := range it.Attributes(t.Context()) {
+		if err != nil {
+			t.Fatalf("Attributes() error = %v", err)
+		}
+		got = append(got, a.StreetName()+"="+a.AttributeValue())
+		if len(got) == 2 {
+			break
+		}
+	}
+	if want := []string{"main street=100", "oak avenue=200"}; !slices.Equal(got, want) {
+		t.Errorf("Attributes() = %v, want %v", got, want)
+	}
+}
+
+func TestValuesError(t *testing.T) {
+	stream := NewMockCsvStream([]string{"Street Name", "Price"}, [][]string{{"Main Street", "100"}, {"Oak Avenue", "1-2"}})
+	parser, err := NewPriceParser(stream, WithColNames("Street Name", "Price"), WithErrorPolicy(FailOnError))
+	if err != nil {
+		t.Fatalf("Failed to create parser: %v", err)
+	}
+
+	var values, errs int
+	for v, err := range parser.(apiParser.StreetValueIterator[attr.Decimal]).Values(t.Context()) {
+		if err != nil {
+			errs++
+			continue
+		}
+		if v.StreetName() != "main street" {
+			t.Errorf("street = %q, want main street", v.StreetName())
+		}
+		values++
+	}
+	if values != 1 || errs != 1 {
+		t.Errorf("Values() yielded %d values and %d errors, want 1 and 1", values, errs)
+	}
+}
@@ -0,0 +1,64 @@
+package csvparser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+)
+
+// parseDate reads the text with the first matching layout. time.Parse rejects dates
+// which do not exist, such as 31/02/2015 or 29/02/2023.
+func parseDate(layouts []string, text string) (attr.Date, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.Parse(layout, text); err == nil {
+			return attr.DateOf(t), nil
+		}
+	}
+	return attr.Date{}, fmt.Errorf("%w: %w", errFieldDate, err)
+}
+
+// readDate sets the sale date of the pair from the date column, an empty or missing field
+// leaves the date unset
+func readDate[F string | []byte](p *priceParser, pair *streetPricePair, record []F) *ParseError {
+	if p.dateIdx >= len(record) {
+		return nil
+	}
+	text := strings.TrimSpace(string(record[p.dateIdx]))
+	if text == "" {
+		return nil
+	}
+	date, err := parseDate(p.dateLayouts, text)
+	if err != nil {
+		pos := p.position()
+		if positioner, ok := p.stream.(apiStreams.FieldPositioner); ok {
+			pos = positioner.FieldPosition(p.dateIdx)
+		}
+		return &ParseError{
+			Row:    p.row(pos),
+			Column: p.column(p.dateIdx),
+			Value:  text,
+			Reason: ReasonInvalidDate,
+			Pos:    pos,
+			Err:    err,
+		}
+	}
+	pair.date = date
+	return nil
+}
+
+// inRange reports whether the sale date of the pair is within the WithDateRange bounds.
+// Without a range every pair is, with one pairs without a date are not.
+func (p *priceParser) inRange(pair *streetPricePair) bool {
+	if p.from.IsZero() && p.to.IsZero() {
+		return true
+	}
+	if pair.date.IsZero() {
+		return false
+	}
+	return (p.from.IsZero() || pair.date.Compare(p.from) >= 0) && (p.to.IsZero() || pair.date.Compare(p.to) <= 0)
+}
@@ -0,0 +1,115 @@
+package csvparser
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiParser "propertytreeanalyzer/pkg/api/parsers"
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
+	"propertytreeanalyzer/pkg/streams"
+)
+
+const datedData = `Date of Sale (dd/mm/yyyy),Street Name,Price
+31/12/2018,Main Street,100
+01/01/2019,Main Street,200
+29/02/2020,Oak Avenue,300
+29/02/2023,Oak Avenue,400
+,Elm Road,500
+31/12/2023,Elm Road,600
+01/01/2024,Elm Road,700
+`
+
+func TestWithDateRange(t *testing.T) {
+	stream, err := streams.NewCsvStream(strings.NewReader(datedData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &rejectSink{}
+	parser, err := NewPriceParser(stream,
+		WithColNames("Street Name", "Price"),
+		WithDateColumn("Date of Sale (dd/mm/yyyy)"),
+		WithDateRange(attr.Date{Year: 2019, Month: time.January, Day: 1}, attr.Date{Year: 2023, Month: time.December, Day: 31}),
+		WithErrorPolicy(CollectErrors),
+		WithRejects(sink),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for value, err := range parser.(apiParser.StreetValueIterator[attr.Decimal]).Values(t.Context()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		date, ok := value.(attr.Dated).Date()
+		if !ok {
+			t.Errorf("%s has no date", value.StreetName())
+		}
+		got = append(got, date.String()+" "+value.StreetName())
+	}
+	if want := []string{"2019-01-01 main street", "2020-02-29 oak avenue", "2023-12-31 elm road"}; !slices.Equal(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+
+	errs := parser.(ErrorCollector).ParseErrors()
+	if len(errs) != 1 || errs[0].Reason != ReasonInvalidDate || errs[0].Value != "29/02/2023" {
+		t.Errorf("errors = %v, want the invalid date 29/02/2023", errs)
+	}
+	var filtered []string
+	for _, r := range sink.rejects {
+		if r.Reason == apiRejects.Filtered {
+			filtered = append(filtered, r.Record[0]+" "+r.Record[2])
+		}
+	}
+	if want := []string{"31/12/2018 100", " 500", "01/01/2024 700"}; !slices.Equal(filtered, want) {
+		t.Errorf("filtered = %q, want %q", filtered, want)
+	}
+}
+
+func TestWithDateLayouts(t *testing.T) {
+	const data = "sold,street,price\n2019-05-03,Main Street,100\n03/05/2019,Oak Avenue,200\n"
+	stream, err := streams.NewCsvStream(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewPriceParser(stream, WithColNames("street", "price"), WithDateColumn("sold"), WithDateLayouts("2006-01-02", DefaultDateLayout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := attr.Date{Year: 2019, Month: time.May, Day: 3}
+	for value, err := range parser.(apiParser.StreetValueIterator[attr.Decimal]).Values(t.Context()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if date, _ := value.(attr.Dated).Date(); date != want {
+			t.Errorf("date of %s = %s, want %s", value.StreetName(), date, want)
+		}
+	}
+}
+
+func TestDateOptionErrors(t *testing.T) {
+	from, to := attr.Date{Year: 2023, Month: time.January, Day: 1}, attr.Date{Year: 2019, Month: time.January, Day: 1}
+	tests := []struct {
+		name string
+		opts []PriceParserOption
+		want error
+	}{
+		{"range without column", []PriceParserOption{WithDateRange(to, from)}, errDateRangeWithoutColumn},
+		{"empty range", []PriceParserOption{WithDateColumn("sold"), WithDateRange(from, to)}, errDateRangeEmpty},
+		{"missing column", []PriceParserOption{WithDateColumn("date")}, errDateColumnMissing},
+		{"empty layout", []PriceParserOption{WithDateColumn("sold"), WithDateLayouts("")}, errDateLayoutEmpty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := NewMockCsvStream([]string{"sold", "street", "price"}, nil)
+			_, err := NewPriceParser(stream, append([]PriceParserOption{WithColNames("street", "price")}, tt.opts...)...)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("NewPriceParser() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package csvparser
+
+import (
+	"fmt"
+	"strings"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiParser "propertytreeanalyzer/pkg/api/parsers"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+)
+
+// FieldType is the type of the values of a schema field
+type FieldType int
+
+const (
+	// TextField values are strings, trimmed of surrounding spaces
+	TextField FieldType = iota
+	// DecimalField values are Decimals, written like prices in the parser's locale
+	DecimalField
+	// DateField values are Dates, see Field.Layouts
+	DateField
+	// BoolField values are bools written yes/no or true/false
+	BoolField
+	// EnumField values are the names of the accepted texts, see Field.Values
+	EnumField
+)
+
+// DefaultDateLayout is the layout of DateFields without layouts, dd/mm/yyyy
+const DefaultDateLayout = "02/01/2006"
+
+// Field maps a column to a typed record field
+type Field struct {
+	// Name names the field in Record.Field
+	Name string
+	// Columns are the header names of the column, the first one found in the header is read
+	Columns []string
+	Type    FieldType
+	// Layouts are the time.Parse layouts of a DateField tried in order, DefaultDateLayout when empty
+	Layouts []string
+	// Values maps the accepted texts of an EnumField, compared case-insensitively, to their names
+	Values map[string]string
+	// Required fields must be in the header, the others are left empty without their column
+	Required bool
+}
+
+// Schema describes the fields of the records read by NewRecordParser
+type Schema struct {
+	// Street and Price name the text field with the street name and the decimal field with
+	// the price of the records, the StreetAttribute of a record
+	Street string
+	Price  string
+	// Date names the date field with the sale date, optional. It is read like WithDateColumn,
+	// so WithDateLayouts and WithDateRange apply to it.
+	Date   string
+	Fields []Field
+}
+
+// field names of the PropertyPriceRegister schema
+const (
+	FieldDate               = "date"
+	FieldAddress            = "address"
+	FieldStreet             = "street"
+	FieldCounty             = "county"
+	FieldEircode            = "eircode"
+	FieldPrice              = "price"
+	FieldNotFullMarketPrice = "not_full_market_price"
+	FieldVATExclusive       = "vat_exclusive"
+	FieldDescription        = "description"
+	FieldSize               = "size"
+)
+
+// enum names of the description and size fields of the PropertyPriceRegister schema
+const (
+	DescriptionNew        = "new"
+	DescriptionSecondHand = "second-hand"
+	SizeUnder38           = "under 38 sq m"
+	Size38To125           = "38 to 125 sq m"
+	Size125OrMore         = "125 sq m or more"
+)
+
+// PropertyPriceRegister returns the schema of the Residential Property Price Register files,
+// with the "Street Name" column of the analysed files. Descriptions and sizes are read in
+// English and Irish.
+func PropertyPriceRegister() Schema {
+	return Schema{
+		Street: FieldStreet,
+		Price:  FieldPrice,
+		Date:   FieldDate,
+		Fields: []Field{
+			{Name: FieldDate, Columns: []string{"Date of Sale (dd/mm/yyyy)", "Date of Sale"}, Type: DateField, Layouts: []string{DefaultDateLayout}},
+			{Name: FieldAddress, Columns: []string{"Address"}, Type: TextField},
+			{Name: FieldStreet, Columns: []string{"Street Name"}, Type: TextField, Required: true},
+			{Name: FieldCounty, Columns: []string{"County"}, Type: TextField},
+			{Name: FieldEircode, Columns: []string{"Eircode"}, Type: TextField},
+			{Name: FieldPrice, Columns: []string{"Price", "Price (€)"}, Type: DecimalField, Required: true},
+			{Name: FieldNotFullMarketPrice, Columns: []string{"Not Full Market Price"}, Type: BoolField},
+			{Name: FieldVATExclusive, Columns: []string{"VAT Exclusive"}, Type: BoolField},
+			{Name: FieldDescription, Columns: []string{"Description of Property"}, Type: EnumField, Values: map[string]string{
+				"New Dwelling house /Apartment":         DescriptionNew,
+				"Second-Hand Dwelling house /Apartment": DescriptionSecondHand,
+				"Teach/Árasán Cónaithe Nua":             DescriptionNew,
+				"Teach/Árasán Cónaithe Atháimhe":        DescriptionSecondHand,
+			}},
+			{Name: FieldSize, Columns: []string{"Property Size Description"}, Type: EnumField, Values: map[string]string{
+				"less than 38 sq metres": SizeUnder38,
+				"greater than or equal to 38 sq metres and less than 125 sq metres":               Size38To125,
+				"greater than or equal to 125 sq metres":                                          Size125OrMore,
+				"níos lú ná 38 méadar cearnach":                                                   SizeUnder38,
+				"níos mó ná nó cothrom le 38 méadar cearnach agus níos lú ná 125 méadar cearnach": Size38To125,
+				"níos mó ná nó cothrom le 125 méadar cearnach":                                    Size125OrMore,
+			}},
+		},
+	}
+}
+
+// schemaField is a field bound to its column
+type schemaField struct {
+	Field
+	idx int
+	// enum holds the Values with lowercased texts
+	enum map[string]string
+}
+
+// boundSchema is a schema bound to the header of a stream
+type boundSchema struct {
+	fields []schemaField
+	names  map[string]int
+	street int
+	price  int
+	date   int
+}
+
+// bindSchema checks the schema and finds the columns of its fields in the header
+func bindSchema(s Schema, header []string) (*boundSchema, error) {
+	if len(header) == 0 {
+		return nil, errNoHeader
+	}
+	b := &boundSchema{names: make(map[string]int, len(s.Fields)), street: -1, price: -1, date: -1}
+	for _, f := range s.Fields {
+		if f.Name == "" {
+			return nil, errSchemaFieldName
+		}
+		if _, ok := b.names[f.Name]; ok {
+			return nil, fmt.Errorf("%w %q", errSchemaFieldRepeated, f.Name)
+		}
+		if f.Type == EnumField && len(f.Values) == 0 {
+			return nil, fmt.Errorf("%w: %q", errSchemaEnumValues, f.Name)
+		}
+		sf := schemaField{Field: f, idx: columnIndex(header, f.Columns)}
+		if sf.idx < 0 && f.Required {
+			return nil, fmt.Errorf("%w: %q needs one of %q", errSchemaColumnMissing, f.Name, f.Columns)
+		}
+		if f.Type == EnumField {
+			sf.enum = make(map[string]string, len(f.Values))
+			for text, name := range f.Values {
+				sf.enum[strings.ToLower(text)] = name
+			}
+		}
+		if f.Type == DateField && len(f.Layouts) == 0 {
+			sf.Layouts = []string{DefaultDateLayout}
+		}
+		switch f.Name {
+		case s.Street:
+			b.street = len(b.fields)
+		case s.Price:
+			b.price = len(b.fields)
+		case s.Date:
+			b.date = len(b.fields)
+		}
+		b.names[f.Name] = len(b.fields)
+		b.fields = append(b.fields, sf)
+	}
+	if b.street < 0 || b.fields[b.street].Type != TextField || b.fields[b.street].idx < 0 {
+		return nil, fmt.Errorf("%w: street field %q", errSchemaStreetPrice, s.Street)
+	}
+	if b.price < 0 || b.fields[b.price].Type != DecimalField || b.fields[b.price].idx < 0 {
+		return nil, fmt.Errorf("%w: price field %q", errSchemaStreetPrice, s.Price)
+	}
+	if s.Date != "" && (b.date < 0 || b.fields[b.date].Type != DateField) {
+		return nil, fmt.Errorf("%w: %q", errSchemaDate, s.Date)
+	}
+	return b, nil
+}
+
+// columnIndex returns the index of the first of the names in the header, -1 when none is there
+func columnIndex(header []string, names []string) int {
+	for _, name := range names {
+		for i, col := range header {
+			if strings.EqualFold(strings.TrimSpace(col), strings.TrimSpace(name)) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// withSchema binds the schema to the header of the stream, its street and price fields
+// select the columns of the parser
+func withSchema(s Schema) PriceParserOption {
+	return func(p *priceParser) error {
+		b, err := bindSchema(s, p.stream.GetHeader())
+		if err != nil {
+			return err
+		}
+		p.schema = b
+		p.streetIdx, p.priceIdx = b.fields[b.street].idx, b.fields[b.price].idx
+		if b.date >= 0 {
+			p.dateIdx, p.dateLayouts = b.fields[b.date].idx, b.fields[b.date].Layouts
+		}
+		return nil
+	}
+}
+
+// NewRecordParser creates a parser reading the fields of the schema into records, see
+// apiParser.RecordParser. The records are the street and price pairs of NewPriceParser as well,
+// the schema selects their columns instead of WithColNames and WithColIndexes.
+// A value which does not match the type of its field is handled by the error policy.
+func NewRecordParser(stream apiStreams.CsvStream, schema Schema, opts ...PriceParserOption) (apiParser.StreetAttributeParser, error) {
+	if stream == nil {
+		return nil, errNilCsvStream
+	}
+	return NewPriceParser(stream, append([]PriceParserOption{withSchema(schema)}, opts...)...)
+}
+
+// readFields reads the schema fields of a record, the street and the price are already in the pair.
+// Empty fields and fields without a column are left nil.
+func readFields[F string | []byte](p *priceParser, pair *streetPricePair, record []F, scan *priceScan, buf []byte) ([]byte, *ParseError) {
+	values := make([]any, len(p.schema.fields))
+	for i := range p.schema.fields {
+		f := &p.schema.fields[i]
+		if f.idx < 0 || f.idx >= len(record) {
+			continue
+		}
+		switch i {
+		case p.schema.price:
+			values[i] = pair.value
+			continue
+		case p.schema.date:
+			if !pair.date.IsZero() {
+				values[i] = pair.date
+			}
+			continue
+		}
+		text := strings.TrimSpace(string(record[f.idx]))
+		if text == "" {
+			continue
+		}
+		var err error
+		if values[i], buf, err = p.readField(f, text, scan, buf); err != nil {
+			pos := p.position()
+			if positioner, ok := p.stream.(apiStreams.FieldPositioner); ok {
+				pos = positioner.FieldPosition(f.idx)
+			}
+			return buf, &ParseError{
+				Row:    p.row(pos),
+				Column: p.column(f.idx),
+				Value:  text,
+				Reason: ReasonInvalidField,
+				Pos:    pos,
+				Err:    err,
+			}
+		}
+	}
+	pair.fields, pair.schema = values, p.schema
+	return buf, nil
+}
+
+// readField reads the non-empty text of a field as its type
+func (p *priceParser) readField(f *schemaField, text string, scan *priceScan, buf []byte) (any, []byte, error) {
+	switch f.Type {
+	case DecimalField:
+		var (
+			normalized string
+			err        error
+			d          attr.Decimal
+		)
+		if normalized, buf, err = p.locale.normalize([]byte(text), scan, buf); err != nil {
+			return nil, buf, err
+		}
+		if normalized == "" {
+			return nil, buf, fmt.Errorf("%w: no digits", errFieldDecimal)
+		}
+		if _, _, err = d.SetString(normalized); err != nil {
+			return nil, buf, err
+		}
+		return d, buf, nil
+	case DateField:
+		date, err := parseDate(f.Layouts, text)
+		if err != nil {
+			return nil, buf, err
+		}
+		return date, buf, nil
+	case BoolField:
+		switch strings.ToLower(text) {
+		case "yes", "y", "true":
+			return true, buf, nil
+		case "no", "n", "false":
+			return false, buf, nil
+		}
+		return nil, buf, errFieldBool
+	case EnumField:
+		if name, ok := f.enum[strings.ToLower(text)]; ok {
+			return name, buf, nil
+		}
+		return nil, buf, errFieldEnum
+	}
+	return text, buf, nil
+}
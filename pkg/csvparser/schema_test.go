@@ -0,0 +1,175 @@
+package csvparser
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	attr "propertytreeanalyzer/pkg/api/attribute"
+	apiParser "propertytreeanalyzer/pkg/api/parsers"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+	"propertytreeanalyzer/pkg/streams"
+)
+
+const registerData = `Date of Sale (dd/mm/yyyy),Address,Street Name,County,Eircode,Price (€),Not Full Market Price,VAT Exclusive,Description of Property,Property Size Description
+01/01/2015,"APT 274, THE PARKLANDS, NORTHWOOD",The Park,Dublin,,"€79,500.00",No,No,Second-Hand Dwelling house /Apartment,
+05/01/2015,"61 CHARLEMONT, GRIFFITH AVE",Charlemont,Dublin,D09 X2Y3,"€557,000.00",Yes,No,New Dwelling house /Apartment,greater than or equal to 38 sq metres and less than 125 sq metres
+31/02/2015,"1 MAIN ST",Main Street,Dublin,,"€100,000.00",No,No,Second-Hand Dwelling house /Apartment,
+06/01/2015,"2 MAIN ST",Main Street,Dublin,,"€200,000.00",Maybe,No,Second-Hand Dwelling house /Apartment,
+07/01/2015,"3 MAIN ST",Main Street,Dublin,,"€300,000.00",No,No,Teach/Árasán Cónaithe Atháimhe,níos lú ná 38 méadar cearnach
+`
+
+func TestRecordParser(t *testing.T) {
+	stream, err := streams.NewCsvStream(strings.NewReader(registerData), streams.WithName("ppr.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewRecordParser(stream, PropertyPriceRegister(), WithErrorPolicy(CollectErrors))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []apiParser.Record
+	for r, err := range parser.(apiParser.RecordParser).Records(t.Context()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+
+	second := records[1]
+	if got := second.StreetName(); got != "charlemont" {
+		t.Errorf("StreetName() = %q, want charlemont", got)
+	}
+	if got := second.AttributeValue(); got != "557000.00" {
+		t.Errorf("AttributeValue() = %q, want 557000.00", got)
+	}
+	if got, ok := apiParser.FieldOf[attr.Date](second, FieldDate); !ok || got != (attr.Date{Year: 2015, Month: time.January, Day: 5}) {
+		t.Errorf("date = %v, %v", got, ok)
+	}
+	if got, ok := apiParser.FieldOf[string](second, FieldAddress); !ok || got != "61 CHARLEMONT, GRIFFITH AVE" {
+		t.Errorf("address = %q, %v", got, ok)
+	}
+	if got, ok := apiParser.FieldOf[string](second, FieldStreet); !ok || got != "Charlemont" {
+		t.Errorf("street = %q, %v", got, ok)
+	}
+	if got, ok := apiParser.FieldOf[string](second, FieldEircode); !ok || got != "D09 X2Y3" {
+		t.Errorf("eircode = %q, %v", got, ok)
+	}
+	if got, ok := apiParser.FieldOf[attr.Decimal](second, FieldPrice); !ok || got.String() != "557000.00" {
+		t.Errorf("price = %s, %v", got.String(), ok)
+	}
+	if got, ok := apiParser.FieldOf[bool](second, FieldNotFullMarketPrice); !ok || !got {
+		t.Errorf("not full market price = %v, %v", got, ok)
+	}
+	if got, ok := apiParser.FieldOf[bool](second, FieldVATExclusive); !ok || got {
+		t.Errorf("VAT exclusive = %v, %v", got, ok)
+	}
+	if got, ok := apiParser.FieldOf[string](second, FieldDescription); !ok || got != DescriptionNew {
+		t.Errorf("description = %q, %v", got, ok)
+	}
+	if got, ok := apiParser.FieldOf[string](second, FieldSize); !ok || got != Size38To125 {
+		t.Errorf("size = %q, %v", got, ok)
+	}
+
+	if _, ok := records[0].Field(FieldEircode); ok {
+		t.Error("empty eircode is set")
+	}
+	if _, ok := records[0].Field("postcode"); ok {
+		t.Error("field not in the schema is set")
+	}
+	if got, _ := apiParser.FieldOf[string](records[2], FieldDescription); got != DescriptionSecondHand {
+		t.Errorf("Irish description = %q, want %q", got, DescriptionSecondHand)
+	}
+	if got, _ := apiParser.FieldOf[string](records[2], FieldSize); got != SizeUnder38 {
+		t.Errorf("Irish size = %q, want %q", got, SizeUnder38)
+	}
+
+	errs := parser.(ErrorCollector).ParseErrors()
+	if len(errs) != 2 {
+		t.Fatalf("collected %d errors, want 2: %v", len(errs), errs)
+	}
+	if e := errs[0]; e.Row != 4 || e.Reason != ReasonInvalidDate || e.Column != "Date of Sale (dd/mm/yyyy)" || !errors.Is(e, errFieldDate) {
+		t.Errorf("first error = %v, want the impossible date of row 4", e)
+	} else if want := `ppr.csv:4:1: invalid date "31/02/2015": not a date: parsing time "31/02/2015": day out of range`; e.Error() != want {
+		t.Errorf("first error = %q, want %q", e.Error(), want)
+	}
+	if e := errs[1]; e.Row != 5 || e.Value != "Maybe" || !errors.Is(e, errFieldBool) {
+		t.Errorf("second error = %v, want the bool of row 5", e)
+	}
+}
+
+func TestRecordParserAttributes(t *testing.T) {
+	constructors := map[string]func() (apiStreams.CsvStream, error){
+		"csv-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewCsvStream(strings.NewReader(registerData))
+		},
+		"mapped-stream": func() (apiStreams.CsvStream, error) {
+			return streams.NewMappedCsvStream(strings.NewReader(registerData), int64(len(registerData)))
+		},
+	}
+	for name, newStream := range constructors {
+		t.Run(name, func(t *testing.T) {
+			stream, err := newStream()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parser, err := NewRecordParser(stream, PropertyPriceRegister(), WithErrorPolicy(SkipErrors))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parsePairs(t, parser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"the park=79500.00", "charlemont=557000.00", "main street=300000.00"}; !slices.Equal(got, want) {
+				t.Errorf("pairs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBindSchema(t *testing.T) {
+	header := []string{"Street Name", "Price", "Sold"}
+	tests := []struct {
+		name   string
+		schema Schema
+		want   error
+	}{
+		{"register without optional columns", PropertyPriceRegister(), nil},
+		{"missing required column", Schema{Street: "street", Price: "price", Fields: []Field{
+			{Name: "street", Columns: []string{"Street Name"}},
+			{Name: "price", Columns: []string{"Price"}, Type: DecimalField},
+			{Name: "county", Columns: []string{"County"}, Required: true},
+		}}, errSchemaColumnMissing},
+		{"repeated name", Schema{Street: "street", Price: "price", Fields: []Field{
+			{Name: "street", Columns: []string{"Street Name"}},
+			{Name: "street", Columns: []string{"Price"}, Type: DecimalField},
+		}}, errSchemaFieldRepeated},
+		{"enum without values", Schema{Street: "street", Price: "price", Fields: []Field{
+			{Name: "street", Columns: []string{"Street Name"}},
+			{Name: "price", Columns: []string{"Price"}, Type: DecimalField},
+			{Name: "sold", Columns: []string{"Sold"}, Type: EnumField},
+		}}, errSchemaEnumValues},
+		{"price is not decimal", Schema{Street: "street", Price: "price", Fields: []Field{
+			{Name: "street", Columns: []string{"Street Name"}},
+			{Name: "price", Columns: []string{"Price"}},
+		}}, errSchemaStreetPrice},
+		{"no street field", Schema{Price: "price", Fields: []Field{
+			{Name: "price", Columns: []string{"Price"}, Type: DecimalField},
+		}}, errSchemaStreetPrice},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bindSchema(tt.schema, header)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("bindSchema() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
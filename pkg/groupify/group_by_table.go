@@ -0,0 +1,107 @@
+package groupify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"log/slog"
+	"strings"
+
+	apiGroupify "propertytreeanalyzer/pkg/api/groupify"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+)
+
+var (
+	errNilTableStream       = errors.New("table stream cannot be nil")
+	errTableStreetMissing   = errors.New("street column not found in table header")
+	errTableTreeSizeMissing = errors.New("tree size column not found in table header")
+)
+
+// tableTreesGrouper groups streets listed in a table, e.g. a tree survey spreadsheet,
+// with one street per row and its tree size ("short" or "tall") in another column
+type tableTreesGrouper struct {
+	source    apiStreams.CsvStream
+	streetIdx int
+	sizeIdx   int
+}
+
+var (
+	_ apiGroupify.StreetGroups                                   = (*tableTreesGrouper)(nil)
+	_ apiGroupify.KeyedStreetGroups[apiGroupify.TreeSize]        = (*tableTreesGrouper)(nil)
+	_ apiGroupify.StreetGroupIterator                            = (*tableTreesGrouper)(nil)
+	_ apiGroupify.KeyedStreetGroupIterator[apiGroupify.TreeSize] = (*tableTreesGrouper)(nil)
+)
+
+// NewTableTreesGrouper initializes a grouper reading the street and tree size columns of a table.
+// Columns are looked up in the header case-insensitively.
+func NewTableTreesGrouper(stream apiStreams.CsvStream, streetColName, sizeColName string) (apiGroupify.StreetGroups, chan apiGroupify.StreetGroupItem, error) {
+	if stream == nil {
+		return nil, nil, errNilTableStream
+	}
+	t := &tableTreesGrouper{source: stream, streetIdx: -1, sizeIdx: -1}
+	for i, col := range stream.GetHeader() {
+		col = strings.TrimSpace(col)
+		if strings.EqualFold(col, strings.TrimSpace(streetColName)) {
+			t.streetIdx = i
+		}
+		if strings.EqualFold(col, strings.TrimSpace(sizeColName)) {
+			t.sizeIdx = i
+		}
+	}
+	if t.streetIdx == -1 {
+		return nil, nil, errTableStreetMissing
+	}
+	if t.sizeIdx == -1 {
+		return nil, nil, errTableTreeSizeMissing
+	}
+	return t, make(chan apiGroupify.StreetGroupItem, 1000), nil
+}
+
+// GroupStreets implements StreetsGrouper.
+// Rows without a street name or with an unknown tree size are skipped.
+func (t *tableTreesGrouper) GroupStreets(ctx context.Context, dst chan<- apiGroupify.StreetGroupItem) error {
+	defer close(dst)
+	return t.group(ctx, sendTo(ctx, dst))
+}
+
+// GroupKeyedStreets implements KeyedStreetGroups, streets are keyed by their tree size.
+func (t *tableTreesGrouper) GroupKeyedStreets(ctx context.Context, dst chan<- apiGroupify.GroupItem[apiGroupify.TreeSize]) error {
+	defer close(dst)
+	return t.group(ctx, sendTo(ctx, dst))
+}
+
+// Items implements StreetGroupIterator.
+func (t *tableTreesGrouper) Items(ctx context.Context) iter.Seq2[apiGroupify.StreetGroupItem, error] {
+	return groupSeq[apiGroupify.StreetGroupItem](func(emit emitFunc) error { return t.group(ctx, emit) })
+}
+
+// KeyedItems implements KeyedStreetGroupIterator, streets are keyed by their tree size.
+func (t *tableTreesGrouper) KeyedItems(ctx context.Context) iter.Seq2[apiGroupify.GroupItem[apiGroupify.TreeSize], error] {
+	return groupSeq[apiGroupify.GroupItem[apiGroupify.TreeSize]](func(emit emitFunc) error { return t.group(ctx, emit) })
+}
+
+func (t *tableTreesGrouper) group(ctx context.Context, emit emitFunc) error {
+	for {
+		record, err := t.source.ReadCsvRecord(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Error reading table record", "error", err)
+			return err
+		}
+		if len(record) <= t.streetIdx || len(record) <= t.sizeIdx {
+			continue
+		}
+
+		size := apiGroupify.ParseTreeSize(strings.TrimSpace(record[t.sizeIdx]))
+		street := apiGroupify.ParseStreetName(record[t.streetIdx])
+		if size == apiGroupify.TreeSizeNone || street == "" {
+			continue
+		}
+		if err := emit(&streetsGroupsByTreeSize{groupKey: size, street: street}); err != nil {
+			return err
+		}
+	}
+}
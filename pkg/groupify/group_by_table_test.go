@@ -0,0 +1,78 @@
+package groupify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	api "propertytreeanalyzer/pkg/api/groupify"
+)
+
+type mockTableStream struct {
+	header  []string
+	records [][]string
+}
+
+func (m *mockTableStream) GetHeader() []string { return m.header }
+
+func (m *mockTableStream) ReadCsvRecord(_ context.Context) ([]string, error) {
+	if len(m.records) == 0 {
+		return nil, io.EOF
+	}
+	record := m.records[0]
+	m.records = m.records[1:]
+	return record, nil
+}
+
+func TestTableTreesGrouper(t *testing.T) {
+	stream := &mockTableStream{
+		header: []string{"Survey Id", "STREET NAME", "Tree Size"},
+		records: [][]string{
+			{"1", "Abbey  Drive", "short"},
+			{"2", "Temple Gardens", "Tall"},
+			{"3", "Nowhere Road", "medium"},
+			{"4"},
+			{"5", "", "tall"},
+		},
+	}
+
+	grouper, itemChan, err := NewTableTreesGrouper(stream, "Street Name", "tree size")
+	if err != nil {
+		t.Fatalf("NewTableTreesGrouper() error = %v", err)
+	}
+
+	var items []api.StreetGroupItem
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for item := range itemChan {
+			items = append(items, item)
+		}
+	}()
+
+	err = grouper.GroupStreets(context.Background(), itemChan)
+	<-done
+	if err != nil {
+		t.Fatalf("GroupStreets returned error: %v", err)
+	}
+
+	expected := map[api.TreeSize][]string{
+		api.TreeSizeShort: {"abbey drive"},
+		api.TreeSizeTall:  {"temple gardens"},
+	}
+	if result := collectGroupItems(items); !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
+
+func TestTableTreesGrouperMissingColumns(t *testing.T) {
+	stream := &mockTableStream{header: []string{"Street Name", "Height"}}
+	if _, _, err := NewTableTreesGrouper(stream, "Street Name", "Tree Size"); !errors.Is(err, errTableTreeSizeMissing) {
+		t.Errorf("expected %v, got %v", errTableTreeSizeMissing, err)
+	}
+	if _, _, err := NewTableTreesGrouper(stream, "Street", "Height"); !errors.Is(err, errTableStreetMissing) {
+		t.Errorf("expected %v, got %v", errTableStreetMissing, err)
+	}
+}
@@ -3,7 +3,9 @@ package groupify
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"iter"
 	"log/slog"
 
 	attr "propertytreeanalyzer/pkg/api/attribute"
@@ -25,15 +27,62 @@ type streetsGroupsByTreeSize struct {
 }
 
 var (
-	_ apiGroupify.StreetGroups    = (*treesGrouper)(nil)
-	_ apiGroupify.StreetGroupItem = (*streetsGroupsByTreeSize)(nil)
+	_ apiGroupify.StreetGroups                                   = (*treesGrouper)(nil)
+	_ apiGroupify.KeyedStreetGroups[apiGroupify.TreeSize]        = (*treesGrouper)(nil)
+	_ apiGroupify.StreetGroupItem                                = (*streetsGroupsByTreeSize)(nil)
+	_ apiGroupify.GroupItem[apiGroupify.TreeSize]                = (*streetsGroupsByTreeSize)(nil)
+	_ apiGroupify.StreetGroupIterator                            = (*treesGrouper)(nil)
+	_ apiGroupify.KeyedStreetGroupIterator[apiGroupify.TreeSize] = (*treesGrouper)(nil)
+
+	// errStopped ends grouping when the consumer of an iterator stops ranging
+	errStopped = errors.New("iteration stopped")
 )
 
+// emitFunc passes a grouped street on to the consumer
+type emitFunc func(item *streetsGroupsByTreeSize) error
+
+// sendTo returns the emitFunc sending to dst until the context is done
+func sendTo[I any](ctx context.Context, dst chan<- I) emitFunc {
+	return func(item *streetsGroupsByTreeSize) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case dst <- any(item).(I):
+			return nil
+		}
+	}
+}
+
+// yieldTo returns the emitFunc passing items to the yield function of an iterator
+func yieldTo[I any](yield func(I, error) bool) emitFunc {
+	return func(item *streetsGroupsByTreeSize) error {
+		if !yield(any(item).(I), nil) {
+			return errStopped
+		}
+		return nil
+	}
+}
+
+// groupSeq returns the iterator running group with the items passed to yield
+func groupSeq[I any](group func(emitFunc) error) iter.Seq2[I, error] {
+	return func(yield func(I, error) bool) {
+		if err := group(yieldTo(yield)); err != nil && !errors.Is(err, errStopped) {
+			var zero I
+			yield(zero, err)
+		}
+	}
+}
+
 // Key implements StreetGroupItem.
 func (s *streetsGroupsByTreeSize) Key() attr.BaseAttribute {
 	return s.groupKey
 }
 
+// GroupKey implements GroupItem.
+func (s *streetsGroupsByTreeSize) GroupKey() apiGroupify.TreeSize {
+	return s.groupKey
+}
+
 // StreetName implements StreetGroupItem.
 func (s *streetsGroupsByTreeSize) StreetName() apiGroupify.StreetName {
 	return s.street
@@ -44,7 +93,7 @@ func NewTreesGrouper(stream apiStreams.JsonStream) (apiGroupify.StreetGroups, ch
 	return &treesGrouper{source: stream}, make(chan apiGroupify.StreetGroupItem, 1000)
 }
 
-func (t *treesGrouper) processJson(ctx context.Context, dst chan<- apiGroupify.StreetGroupItem) (bool, error) {
+func (t *treesGrouper) processJson(ctx context.Context, emit emitFunc) (bool, error) {
 	tok, err := t.source.ReadJsonToken(ctx)
 	if err == io.EOF {
 		return true, nil
@@ -81,11 +130,14 @@ func (t *treesGrouper) processJson(ctx context.Context, dst chan<- apiGroupify.S
 		if t.lastKey != "" {
 			// We found a key followed by a number.
 			// Add the key to the correct list based on the current section.
-			dst <- &streetsGroupsByTreeSize{
+			item := &streetsGroupsByTreeSize{
 				groupKey: t.currentGroup,
 				street:   apiGroupify.ParseStreetName(t.lastKey),
 			}
 			t.lastKey = ""
+			if err := emit(item); err != nil {
+				return false, err
+			}
 		}
 
 	default:
@@ -97,13 +149,33 @@ func (t *treesGrouper) processJson(ctx context.Context, dst chan<- apiGroupify.S
 // GroupStreets implements StreetsGrouper.
 func (t *treesGrouper) GroupStreets(ctx context.Context, dst chan<- apiGroupify.StreetGroupItem) error {
 	defer close(dst)
+	return t.group(ctx, sendTo(ctx, dst))
+}
+
+// GroupKeyedStreets implements KeyedStreetGroups, streets are keyed by their tree size.
+func (t *treesGrouper) GroupKeyedStreets(ctx context.Context, dst chan<- apiGroupify.GroupItem[apiGroupify.TreeSize]) error {
+	defer close(dst)
+	return t.group(ctx, sendTo(ctx, dst))
+}
+
+// Items implements StreetGroupIterator.
+func (t *treesGrouper) Items(ctx context.Context) iter.Seq2[apiGroupify.StreetGroupItem, error] {
+	return groupSeq[apiGroupify.StreetGroupItem](func(emit emitFunc) error { return t.group(ctx, emit) })
+}
+
+// KeyedItems implements KeyedStreetGroupIterator, streets are keyed by their tree size.
+func (t *treesGrouper) KeyedItems(ctx context.Context) iter.Seq2[apiGroupify.GroupItem[apiGroupify.TreeSize], error] {
+	return groupSeq[apiGroupify.GroupItem[apiGroupify.TreeSize]](func(emit emitFunc) error { return t.group(ctx, emit) })
+}
+
+func (t *treesGrouper) group(ctx context.Context, emit emitFunc) error {
 	done := ctx.Done()
 	for {
 		select {
 		case <-done:
 			return ctx.Err()
 		default:
-			done, err := t.processJson(ctx, dst)
+			done, err := t.processJson(ctx, emit)
 			if done {
 				return nil
 			}
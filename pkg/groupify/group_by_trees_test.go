@@ -269,3 +269,67 @@ func TestCancelledContext(t *testing.T) {
 		t.Errorf("Expected context.Canceled error, got %v", err)
 	}
 }
+
+func TestTreesGrouperGroupKeyedStreets(t *testing.T) {
+	grouper, _ := NewTreesGrouper(createMockTreeStream())
+	keyed, ok := grouper.(api.KeyedStreetGroups[api.TreeSize])
+	if !ok {
+		t.Fatal("trees grouper does not implement KeyedStreetGroups")
+	}
+
+	items := make(chan api.GroupItem[api.TreeSize], 10)
+	if err := keyed.GroupKeyedStreets(t.Context(), items); err != nil {
+		t.Fatalf("GroupKeyedStreets returned error: %v", err)
+	}
+	result := make(map[api.TreeSize][]string)
+	for item := range items {
+		result[item.GroupKey()] = append(result[item.GroupKey()], item.StreetName().String())
+	}
+	expected := map[api.TreeSize][]string{
+		api.TreeSizeShort: {"main", "oak"},
+		api.TreeSizeTall:  {"elm"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("GroupKeyedStreets = %v, want %v", result, expected)
+	}
+}
+
+func TestTreesGrouperItems(t *testing.T) {
+	grouper, _ := NewTreesGrouper(createMockTreeStream())
+	it, ok := grouper.(api.StreetGroupIterator)
+	if !ok {
+		t.Fatal("trees grouper does not implement StreetGroupIterator")
+	}
+
+	var items []api.StreetGroupItem
+	for item, err := range it.Items(t.Context()) {
+		if err != nil {
+			t.Fatalf("Items yielded error: %v", err)
+		}
+		items = append(items, item)
+	}
+	expected := map[api.TreeSize][]string{
+		api.TreeSizeShort: {"main", "oak"},
+		api.TreeSizeTall:  {"elm"},
+	}
+	if result := collectGroupItems(items); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Items = %v, want %v", result, expected)
+	}
+}
+
+func TestTreesGrouperKeyedItemsStop(t *testing.T) {
+	grouper, _ := NewTreesGrouper(createMockTreeStream())
+	keyed := grouper.(api.KeyedStreetGroupIterator[api.TreeSize])
+
+	var streets []string
+	for item, err := range keyed.KeyedItems(t.Context()) {
+		if err != nil {
+			t.Fatalf("KeyedItems yielded error: %v", err)
+		}
+		streets = append(streets, item.StreetName().String())
+		break
+	}
+	if !reflect.DeepEqual(streets, []string{"main"}) {
+		t.Errorf("streets = %v, want [main]", streets)
+	}
+}
@@ -0,0 +1,75 @@
+package rejects
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
+)
+
+// Writer writes rejected records as CSV rows: the fields of the input row, re-encoded as CSV
+// in the order of the stream header, followed by its source, its line number and the reason code.
+// Rows are padded or cut to the width of the header, so the trailing columns stay aligned.
+// It implements the api Sink.
+type Writer struct {
+	mu sync.Mutex
+	w  *csv.Writer
+	// width is the number of header columns, zero without a header
+	width int
+	row   []string
+	count int
+}
+
+var _ apiRejects.Sink = (*Writer)(nil)
+
+// NewWriter creates a Writer and writes the header of the input rows followed by "source", "line"
+// and "reason", no header row is written for an empty header
+func NewWriter(w io.Writer, header []string) (*Writer, error) {
+	r := &Writer{w: csv.NewWriter(w), width: len(header)}
+	if len(header) == 0 {
+		return r, nil
+	}
+	if err := r.w.Write(append(append([]string(nil), header...), "source", "line", "reason")); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reject implements Sink. The source and the line are empty when the position of the row is unknown.
+func (r *Writer) Reject(_ context.Context, reject apiRejects.Reject) error {
+	line := ""
+	if reject.Pos.Line > 0 {
+		line = strconv.Itoa(reject.Pos.Line)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.row = append(r.row[:0], reject.Record...)
+	if r.width > 0 {
+		// short rows miss fields, rows of a field count error have extra ones
+		for len(r.row) < r.width {
+			r.row = append(r.row, "")
+		}
+		r.row = r.row[:r.width]
+	}
+	r.row = append(r.row, reject.Pos.Source, line, string(reject.Reason))
+	r.count++
+	return r.w.Write(r.row)
+}
+
+// Count returns the number of rejected records written so far
+func (r *Writer) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count
+}
+
+// Flush writes the buffered rows to the underlying writer
+func (r *Writer) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Flush()
+	return r.w.Error()
+}
@@ -0,0 +1,63 @@
+package rejects
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	apiRejects "propertytreeanalyzer/pkg/api/rejects"
+	apiStreams "propertytreeanalyzer/pkg/api/streams"
+)
+
+func TestWriter(t *testing.T) {
+	var out strings.Builder
+	w, err := NewWriter(&out, []string{"Street Name", "Price"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	rejects := []apiRejects.Reject{
+		{Record: []string{"Main Street", "12 apples"}, Pos: apiStreams.Position{Source: "2024.csv", Line: 3}, Reason: apiRejects.InvalidPrice},
+		{Record: []string{"Oak, Avenue", "100"}, Pos: apiStreams.Position{Source: "2025.csv", Line: 4}, Reason: apiRejects.UnmatchedStreet},
+		{Record: []string{"elm road"}, Reason: apiRejects.UnmatchedStreet},
+		{Record: []string{"Ash Lane", "1", "2"}, Pos: apiStreams.Position{Source: "2025.csv", Line: 7}, Reason: apiRejects.FieldCount},
+	}
+	for _, r := range rejects {
+		if err := w.Reject(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "Street Name,Price,source,line,reason\n" +
+		"Main Street,12 apples,2024.csv,3,invalid_price\n" +
+		"\"Oak, Avenue\",100,2025.csv,4,unmatched_street\n" +
+		"elm road,,,,unmatched_street\n" +
+		"Ash Lane,1,2025.csv,7,field_count\n"
+	if got := out.String(); got != want {
+		t.Errorf("rejects file =\n%s\nwant\n%s", got, want)
+	}
+	if got := w.Count(); got != len(rejects) {
+		t.Errorf("Count() = %d, want %d", got, len(rejects))
+	}
+}
+
+func TestWriterWithoutHeader(t *testing.T) {
+	var out strings.Builder
+	w, err := NewWriter(&out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reject(context.Background(), apiRejects.Reject{Record: []string{"elm road"}, Reason: apiRejects.ShortRow}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// without a header there is no width to align to
+	if got, want := out.String(), "elm road,,,short_row\n"; got != want {
+		t.Errorf("rejects file = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package sources
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// cacheMeta holds the validators of a cached download
+type cacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// cacheEntry is the cached copy of one URL: a data file and its metadata
+type cacheEntry struct {
+	dir      string
+	dataPath string
+	metaPath string
+	meta     cacheMeta
+}
+
+// newCacheEntry locates the cache files of the URL and loads its validators if there are any
+func newCacheEntry(dir, rawURL string) *cacheEntry {
+	key := sha256.Sum256([]byte(rawURL))
+	name := hex.EncodeToString(key[:16])
+	e := &cacheEntry{
+		dir:      dir,
+		dataPath: filepath.Join(dir, name+".data"),
+		metaPath: filepath.Join(dir, name+".json"),
+		meta:     cacheMeta{URL: rawURL},
+	}
+
+	raw, err := os.ReadFile(e.metaPath)
+	if err != nil {
+		return e
+	}
+	var meta cacheMeta
+	if json.Unmarshal(raw, &meta) != nil || meta.URL != rawURL {
+		return e
+	}
+	if _, err := os.Stat(e.dataPath); err == nil {
+		e.meta = meta
+	}
+	return e
+}
+
+// conditional returns the request headers revalidating the cached copy
+func (e *cacheEntry) conditional() http.Header {
+	header := http.Header{}
+	if e.meta.ETag != "" {
+		header.Set("If-None-Match", e.meta.ETag)
+	}
+	if e.meta.LastModified != "" {
+		header.Set("If-Modified-Since", e.meta.LastModified)
+	}
+	return header
+}
+
+// open opens the cached copy, it is verified first when the URL is pinned
+func (e *cacheEntry) open(pin []byte) (*os.File, error) {
+	file, err := os.Open(e.dataPath)
+	if err != nil {
+		return nil, err
+	}
+	if pin == nil {
+		return file, nil
+	}
+	if err := verify(file, pin); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// create returns a temporary file in the cache directory for a new download
+func (e *cacheEntry) create() (*os.File, error) {
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(e.dir, "download-*")
+}
+
+// commit moves a complete download into place with the validators of its response
+func (e *cacheEntry) commit(tmp *os.File, header http.Header) error {
+	e.meta.ETag = header.Get("ETag")
+	e.meta.LastModified = header.Get("Last-Modified")
+	raw, err := json.Marshal(e.meta)
+	if err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), e.dataPath); err != nil {
+		return err
+	}
+	return os.WriteFile(e.metaPath, raw, 0o644)
+}
+
+// remove drops the cached copy
+func (e *cacheEntry) remove() {
+	os.Remove(e.metaPath)
+	os.Remove(e.dataPath)
+	e.meta = cacheMeta{URL: e.meta.URL}
+}
+
+// cachingReader copies a download into the cache while it is read.
+// The copy replaces the cached one when the download completes, a failing cache
+// only loses the copy, never the download.
+type cachingReader struct {
+	body   io.ReadCloser
+	entry  *cacheEntry
+	header http.Header
+	tmp    *os.File
+}
+
+var _ io.ReadCloser = (*cachingReader)(nil)
+
+func (e *cacheEntry) tee(body io.ReadCloser, header http.Header) io.ReadCloser {
+	tmp, err := e.create()
+	if err != nil {
+		slog.Warn("Download is not cached", "url", e.meta.URL, "error", err)
+		return body
+	}
+	return &cachingReader{body: body, entry: e, header: header, tmp: tmp}
+}
+
+// Read implements io.Reader.
+func (c *cachingReader) Read(p []byte) (int, error) {
+	n, err := c.body.Read(p)
+	if c.tmp == nil {
+		return n, err
+	}
+	if _, writeErr := c.tmp.Write(p[:n]); writeErr != nil {
+		slog.Warn("Download is not cached", "url", c.entry.meta.URL, "error", writeErr)
+		c.discard()
+		return n, err
+	}
+	if errors.Is(err, io.EOF) {
+		tmp := c.tmp
+		c.tmp = nil
+		if commitErr := c.entry.commit(tmp, c.header); commitErr != nil {
+			slog.Warn("Download is not cached", "url", c.entry.meta.URL, "error", commitErr)
+			os.Remove(tmp.Name())
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer. An incomplete copy is dropped.
+func (c *cachingReader) Close() error {
+	c.discard()
+	return c.body.Close()
+}
+
+func (c *cachingReader) discard() {
+	if c.tmp != nil {
+		c.tmp.Close()
+		os.Remove(c.tmp.Name())
+		c.tmp = nil
+	}
+}
+
+// verify compares the SHA-256 checksum of the rest of the reader with the pin
+func verify(reader io.Reader, pin []byte) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return err
+	}
+	if sum := h.Sum(nil); !bytes.Equal(sum, pin) {
+		return fmt.Errorf("%w: got %x, want %x", errChecksumMismatch, sum, pin)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package sources
+
+import "errors"
+
+var (
+	// Error definitions
+	errUnsupportedScheme = errors.New("unsupported URL scheme")
+	errInvalidRetries    = errors.New("number of retries cannot be negative")
+	errInvalidBackoff    = errors.New("retry backoff cannot be negative")
+	errInvalidPin        = errors.New("sha256 pin must be 64 hex digits")
+	errChecksumMismatch  = errors.New("sha256 checksum mismatch")
+	errNotResumable      = errors.New("server cannot resume the download")
+	errInvalidEndpoint   = errors.New("s3 endpoint must be an http or https URL")
+	errInvalidS3URL      = errors.New("s3 URL must be s3://bucket/key")
+)
@@ -0,0 +1,237 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = 500 * time.Millisecond
+	// maxRetryAfter caps the delay a server may ask for
+	maxRetryAfter = time.Minute
+)
+
+// HttpFetcher opens http and https URLs as streams for the streams package.
+// Failed requests and interrupted downloads are retried, downloads can be cached
+// and pinned to a SHA-256 checksum.
+type HttpFetcher struct {
+	client   *http.Client
+	cacheDir string
+	retries  int
+	backoff  time.Duration
+	pins     map[string][]byte
+}
+
+// NewHttpFetcher creates a fetcher without a cache which retries 3 times
+func NewHttpFetcher(opts ...FetchOption) (*HttpFetcher, error) {
+	f := &HttpFetcher{
+		client:  http.DefaultClient,
+		retries: defaultRetries,
+		backoff: defaultBackoff,
+		pins:    make(map[string][]byte),
+	}
+	for _, opt := range opts {
+		if err := opt(f); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// IsURL reports whether the path is an http or https URL
+func IsURL(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Open starts the download of the URL. The response body is streamed and resumed with a range
+// request when the connection drops. A cached copy is revalidated with If-None-Match and
+// If-Modified-Since and served from disk when it is still current, a new download replaces it
+// once it has been read to the end. Pinned URLs are downloaded completely and verified first.
+func (f *HttpFetcher) Open(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	if !IsURL(rawURL) {
+		return nil, fmt.Errorf("%w: %q", errUnsupportedScheme, rawURL)
+	}
+	pin := f.pins[rawURL]
+
+	var entry *cacheEntry
+	var header http.Header
+	if f.cacheDir != "" {
+		entry = newCacheEntry(f.cacheDir, rawURL)
+		header = entry.conditional()
+	}
+	resp, err := f.get(ctx, rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		file, err := entry.open(pin)
+		if err == nil {
+			slog.DebugContext(ctx, "Using cached download", "url", rawURL)
+			return file, nil
+		}
+		slog.WarnContext(ctx, "Discarding cached download", "url", rawURL, "error", err)
+		entry.remove()
+		if resp, err = f.get(ctx, rawURL, nil); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	body := &resumeReader{
+		ctx:     ctx,
+		open:    f.rangeOpener(rawURL, resp.Header),
+		body:    resp.Body,
+		retries: f.retries,
+		backoff: f.backoff,
+	}
+	switch {
+	case pin != nil:
+		return f.downloadPinned(rawURL, body, pin, entry, resp.Header)
+	case entry != nil:
+		return entry.tee(body, resp.Header), nil
+	default:
+		return body, nil
+	}
+}
+
+// get sends a GET request, network errors and transient statuses are retried.
+// The response of the last attempt is returned whatever its status.
+func (f *HttpFetcher) get(ctx context.Context, rawURL string, header http.Header) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+
+		resp, err := f.client.Do(req)
+		if err == nil && !retryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= f.retries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay := backoffDelay(f.backoff, attempt)
+		if err == nil {
+			if after := retryAfter(resp.Header); after > 0 {
+				delay = after
+			}
+			resp.Body.Close()
+			slog.DebugContext(ctx, "Retrying download", "url", rawURL, "status", resp.Status, "delay", delay)
+		} else {
+			slog.DebugContext(ctx, "Retrying download", "url", rawURL, "error", err, "delay", delay)
+		}
+		if err := wait(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rangeOpener resumes the download with a range request. If-Range makes sure
+// the rest comes from the same version, so a strong ETag or Last-Modified is required.
+func (f *HttpFetcher) rangeOpener(rawURL string, header http.Header) rangeOpener {
+	validator := header.Get("ETag")
+	if strings.HasPrefix(validator, "W/") {
+		validator = ""
+	}
+	if validator == "" {
+		validator = header.Get("Last-Modified")
+	}
+	return func(ctx context.Context, offset int64) (io.ReadCloser, error) {
+		if validator == "" {
+			return nil, fmt.Errorf("%w: no validator", errNotResumable)
+		}
+		resp, err := f.get(ctx, rawURL, http.Header{
+			"Range":    {"bytes=" + strconv.FormatInt(offset, 10) + "-"},
+			"If-Range": {validator},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: %s", errNotResumable, resp.Status)
+		}
+		return resp.Body, nil
+	}
+}
+
+// downloadPinned downloads the whole body and verifies its checksum before handing out the copy
+func (f *HttpFetcher) downloadPinned(rawURL string, body io.ReadCloser, pin []byte, entry *cacheEntry, header http.Header) (io.ReadCloser, error) {
+	defer body.Close()
+
+	var tmp *os.File
+	var err error
+	if entry != nil {
+		tmp, err = entry.create()
+	} else {
+		tmp, err = os.CreateTemp("", "download-*")
+	}
+	if err != nil {
+		return nil, err
+	}
+	fail := func(err error) (io.ReadCloser, error) {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("%s: %w", rawURL, err)
+	}
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		return fail(err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	if err := verify(tmp, pin); err != nil {
+		return fail(err)
+	}
+
+	if entry != nil {
+		if err := entry.commit(tmp, header); err != nil {
+			return fail(err)
+		}
+		return os.Open(entry.dataPath)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fail(err)
+	}
+	// the open file stays readable, its space is released when it is closed
+	os.Remove(tmp.Name())
+	return tmp, nil
+}
+
+// retryableStatus reports the statuses worth another attempt
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return min(time.Duration(seconds)*time.Second, maxRetryAfter)
+}
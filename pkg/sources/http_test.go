@@ -0,0 +1,164 @@
+package sources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testBody = "Street Name,Price\nAbbey Drive,100\nTemple Gardens,200\n"
+
+// testServer serves testBody with an ETag, it fails the first requests as configured
+type testServer struct {
+	*httptest.Server
+	requests    atomic.Int32
+	notModified atomic.Int32
+	ranges      atomic.Int32
+	// failures is the number of requests answered with 503
+	failures atomic.Int32
+	// truncate is the number of responses cut after half of the body
+	truncate atomic.Int32
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.failures.Add(-1) >= 0 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			s.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Header.Get("Range") != "" {
+			s.ranges.Add(1)
+		}
+		if r.Header.Get("Range") == "" && s.truncate.Add(-1) >= 0 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(testBody)))
+			w.WriteHeader(http.StatusOK)
+			io.WriteString(w, testBody[:len(testBody)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "prices.csv", time.Time{}, strings.NewReader(testBody))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func fetch(t *testing.T, f *HttpFetcher, url string) (string, error) {
+	t.Helper()
+	body, err := f.Open(context.Background(), url)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
+
+func TestHttpFetcherCache(t *testing.T) {
+	server := newTestServer(t)
+	f, err := NewHttpFetcher(WithCacheDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		got, err := fetch(t, f, server.URL+"/prices.csv")
+		if err != nil {
+			t.Fatalf("fetch error = %v", err)
+		}
+		if got != testBody {
+			t.Errorf("fetch = %q, want %q", got, testBody)
+		}
+	}
+	if n := server.notModified.Load(); n != 1 {
+		t.Errorf("second fetch was revalidated %d times, want 1", n)
+	}
+}
+
+func TestHttpFetcherRetries(t *testing.T) {
+	server := newTestServer(t)
+	server.failures.Store(2)
+	f, err := NewHttpFetcher(WithBackoff(time.Millisecond), WithRetries(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fetch(t, f, server.URL); err != nil || got != testBody {
+		t.Errorf("fetch = %q, %v; want the body after two retries", got, err)
+	}
+
+	server.failures.Store(3)
+	if _, err := fetch(t, f, server.URL); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("fetch error = %v, want the 503 status after the retries run out", err)
+	}
+}
+
+func TestHttpFetcherResume(t *testing.T) {
+	server := newTestServer(t)
+	server.truncate.Store(1)
+	f, err := NewHttpFetcher(WithBackoff(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fetch(t, f, server.URL); err != nil || got != testBody {
+		t.Errorf("fetch = %q, %v; want the whole body", got, err)
+	}
+	if n := server.ranges.Load(); n != 1 {
+		t.Errorf("download was resumed %d times, want 1", n)
+	}
+}
+
+func TestHttpFetcherPin(t *testing.T) {
+	server := newTestServer(t)
+	sum := sha256.Sum256([]byte(testBody))
+	dir := t.TempDir()
+
+	f, err := NewHttpFetcher(WithCacheDir(dir), WithPin(server.URL, hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if got, err := fetch(t, f, server.URL); err != nil || got != testBody {
+			t.Errorf("fetch = %q, %v; want the pinned body", got, err)
+		}
+	}
+
+	wrong := sha256.Sum256([]byte("something else"))
+	f, err = NewHttpFetcher(WithPin(server.URL, hex.EncodeToString(wrong[:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetch(t, f, server.URL); !errors.Is(err, errChecksumMismatch) {
+		t.Errorf("fetch error = %v, want %v", err, errChecksumMismatch)
+	}
+
+	if _, err := NewHttpFetcher(WithPin(server.URL, "abc")); !errors.Is(err, errInvalidPin) {
+		t.Errorf("NewHttpFetcher error = %v, want %v", err, errInvalidPin)
+	}
+}
+
+func TestHttpFetcherScheme(t *testing.T) {
+	f, err := NewHttpFetcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Open(context.Background(), "ftp://example.com/prices.csv"); !errors.Is(err, errUnsupportedScheme) {
+		t.Errorf("Open error = %v, want %v", err, errUnsupportedScheme)
+	}
+}
@@ -0,0 +1,63 @@
+package sources
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FetchOption configures an HttpFetcher
+type FetchOption func(*HttpFetcher) error
+
+// WithHttpClient replaces http.DefaultClient
+func WithHttpClient(client *http.Client) FetchOption {
+	return func(f *HttpFetcher) error {
+		f.client = client
+		return nil
+	}
+}
+
+// WithCacheDir keeps downloads in the directory and revalidates them with ETag and Last-Modified.
+// An empty directory disables the cache.
+func WithCacheDir(dir string) FetchOption {
+	return func(f *HttpFetcher) error {
+		f.cacheDir = dir
+		return nil
+	}
+}
+
+// WithRetries sets how many times a failed request or an interrupted download is retried, 3 by default
+func WithRetries(retries int) FetchOption {
+	return func(f *HttpFetcher) error {
+		if retries < 0 {
+			return errInvalidRetries
+		}
+		f.retries = retries
+		return nil
+	}
+}
+
+// WithBackoff sets the delay before the first retry, it doubles with every further retry
+func WithBackoff(backoff time.Duration) FetchOption {
+	return func(f *HttpFetcher) error {
+		if backoff < 0 {
+			return errInvalidBackoff
+		}
+		f.backoff = backoff
+		return nil
+	}
+}
+
+// WithPin requires the content of the URL to have the SHA-256 checksum given in hex.
+// Pinned downloads are verified before any byte is handed out.
+func WithPin(url, sha256Hex string) FetchOption {
+	return func(f *HttpFetcher) error {
+		sum, err := hex.DecodeString(strings.TrimSpace(sha256Hex))
+		if err != nil || len(sum) != 32 {
+			return errInvalidPin
+		}
+		f.pins[url] = sum
+		return nil
+	}
+}
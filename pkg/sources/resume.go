@@ -0,0 +1,86 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// rangeOpener starts the transfer again at the byte offset
+type rangeOpener func(ctx context.Context, offset int64) (io.ReadCloser, error)
+
+// resumeReader continues an interrupted transfer from the first byte not received yet
+type resumeReader struct {
+	ctx     context.Context
+	open    rangeOpener
+	body    io.ReadCloser
+	offset  int64
+	retries int
+	backoff time.Duration
+	// failures counts resumes without progress, it is reset by every received byte
+	failures int
+	err      error
+}
+
+var _ io.ReadCloser = (*resumeReader)(nil)
+
+// Read implements io.Reader.
+func (r *resumeReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	for {
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if n > 0 {
+			r.failures = 0
+		}
+		if err == nil || errors.Is(err, io.EOF) {
+			return n, err
+		}
+		if r.failures >= r.retries || r.ctx.Err() != nil {
+			r.err = err
+			return n, err
+		}
+
+		r.body.Close()
+		if waitErr := wait(r.ctx, backoffDelay(r.backoff, r.failures)); waitErr != nil {
+			r.err = waitErr
+			return n, waitErr
+		}
+		r.failures++
+		body, openErr := r.open(r.ctx, r.offset)
+		if openErr != nil {
+			r.err = fmt.Errorf("resume at byte %d after %v: %w", r.offset, err, openErr)
+			return n, r.err
+		}
+		r.body = body
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close implements io.Closer.
+func (r *resumeReader) Close() error {
+	return r.body.Close()
+}
+
+// backoffDelay doubles the delay with every retry
+func backoffDelay(backoff time.Duration, retry int) time.Duration {
+	return backoff << min(retry, 16)
+}
+
+// wait sleeps for the delay unless the context is done first
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,200 @@
+package sources
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// s3ErrorLimit caps the part of an error response read for its code
+	s3ErrorLimit = 4 << 10
+)
+
+// S3Fetcher opens s3://bucket/key URLs of AWS S3 or a compatible object store like MinIO.
+// Requests are signed with AWS Signature Version 4 using credentials from the environment.
+type S3Fetcher struct {
+	fetcher *HttpFetcher
+	// endpoint is the custom object store, objects are addressed path-style below it.
+	// Without it objects are read from AWS with virtual-hosted addressing.
+	endpoint *url.URL
+	signer   sigV4
+}
+
+// S3Option configures an S3Fetcher
+type S3Option func(*S3Fetcher) error
+
+// NewS3Fetcher creates a fetcher reading the credentials, region and endpoint from the standard AWS
+// environment variables: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION
+// (or AWS_DEFAULT_REGION, us-east-1 by default) and AWS_ENDPOINT_URL_S3 (or AWS_ENDPOINT_URL).
+// Requests go through the HTTP client and retry settings of the fetcher, nil uses the defaults.
+// Without credentials the requests are sent unsigned, for public buckets.
+func NewS3Fetcher(fetcher *HttpFetcher, opts ...S3Option) (*S3Fetcher, error) {
+	if fetcher == nil {
+		var err error
+		if fetcher, err = NewHttpFetcher(); err != nil {
+			return nil, err
+		}
+	}
+	s := &S3Fetcher{
+		fetcher: fetcher,
+		signer: sigV4{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		},
+	}
+	if s.signer.region == "" {
+		s.signer.region = defaultS3Region
+	}
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		opts = append([]S3Option{WithS3Endpoint(endpoint)}, opts...)
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// WithS3Endpoint reads objects from an S3 compatible store, e.g. "http://localhost:9000" for MinIO
+func WithS3Endpoint(endpoint string) S3Option {
+	return func(s *S3Fetcher) error {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %q", errInvalidEndpoint, endpoint)
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+		s.endpoint = u
+		return nil
+	}
+}
+
+// WithS3Region sets the region requests are signed for
+func WithS3Region(region string) S3Option {
+	return func(s *S3Fetcher) error {
+		s.signer.region = region
+		return nil
+	}
+}
+
+// WithS3Credentials replaces the credentials from the environment, the session token is optional
+func WithS3Credentials(accessKey, secretKey, sessionToken string) S3Option {
+	return func(s *S3Fetcher) error {
+		s.signer.accessKey = accessKey
+		s.signer.secretKey = secretKey
+		s.signer.sessionToken = sessionToken
+		return nil
+	}
+}
+
+// IsS3URL reports whether the path is an s3://bucket/key URL
+func IsS3URL(path string) bool {
+	return strings.HasPrefix(strings.ToLower(path), "s3://")
+}
+
+// Open starts reading the object. The body is streamed, a dropped connection is resumed
+// with a range request from the first byte not received yet, guarded by the object's ETag.
+func (s *S3Fetcher) Open(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	object, err := s.objectURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.get(ctx, object, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(rawURL, resp)
+	}
+
+	etag := resp.Header.Get("ETag")
+	return &resumeReader{
+		ctx: ctx,
+		open: func(ctx context.Context, offset int64) (io.ReadCloser, error) {
+			header := http.Header{"Range": {"bytes=" + strconv.FormatInt(offset, 10) + "-"}}
+			if etag != "" {
+				header.Set("If-Match", etag)
+			}
+			resp, err := s.get(ctx, object, header)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode != http.StatusPartialContent {
+				return nil, fmt.Errorf("%w: %w", errNotResumable, s3Error(rawURL, resp))
+			}
+			return resp.Body, nil
+		},
+		body:    resp.Body,
+		retries: s.fetcher.retries,
+		backoff: s.fetcher.backoff,
+	}, nil
+}
+
+// objectURL maps s3://bucket/key to the HTTP URL of the object
+func (s *S3Fetcher) objectURL(rawURL string) (*url.URL, error) {
+	if !IsS3URL(rawURL) {
+		return nil, fmt.Errorf("%w: %q", errUnsupportedScheme, rawURL)
+	}
+	bucket, key, _ := strings.Cut(rawURL[len("s3://"):], "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("%w: %q", errInvalidS3URL, rawURL)
+	}
+
+	var u url.URL
+	switch {
+	case s.endpoint != nil:
+		u = *s.endpoint
+		u.Path += "/" + bucket + "/" + key
+	case strings.Contains(bucket, "."):
+		// dotted bucket names do not match the wildcard certificate of virtual hosts
+		u = url.URL{Scheme: "https", Host: "s3." + s.signer.region + ".amazonaws.com", Path: "/" + bucket + "/" + key}
+	default:
+		u = url.URL{Scheme: "https", Host: bucket + ".s3." + s.signer.region + ".amazonaws.com", Path: "/" + key}
+	}
+	// the path is sent exactly as it is signed
+	u.RawPath = uriEncode(u.Path, false)
+	return &u, nil
+}
+
+// get signs and sends a GET request through the retrying fetcher
+func (s *S3Fetcher) get(ctx context.Context, object *url.URL, header http.Header) (*http.Response, error) {
+	if header == nil {
+		header = http.Header{}
+	}
+	s.signer.sign(header, object, time.Now())
+	return s.fetcher.get(ctx, object.String(), header)
+}
+
+// s3Error describes a failed request with the error code from the S3 error document
+func s3Error(rawURL string, resp *http.Response) error {
+	defer resp.Body.Close()
+	var doc struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.NewDecoder(io.LimitReader(resp.Body, s3ErrorLimit)).Decode(&doc) == nil && doc.Code != "" {
+		return fmt.Errorf("GET %s: %s: %s %s", rawURL, resp.Status, doc.Code, doc.Message)
+	}
+	return fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
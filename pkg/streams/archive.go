@@ -0,0 +1,264 @@
+package streams
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	errNotArchive           = errors.New("input is not a zip or tar archive")
+	errArchiveMemberMissing = errors.New("archive member not found")
+	errArchiveNoMatch       = errors.New("no archive member matches the pattern")
+)
+
+// ArchiveSeparator separates the archive from the member in paths like "ppr.zip!/PPR-2024-Dublin.csv"
+const ArchiveSeparator = "!/"
+
+// zipMagics start a zip archive with entries and an empty zip archive
+var zipMagics = [][]byte{[]byte("PK\x03\x04"), []byte("PK\x05\x06")}
+
+// SplitArchivePath splits "ppr.zip!/PPR-2024-Dublin.csv" into the archive and the member path.
+// ok is false when the path does not name an archive member.
+func SplitArchivePath(p string) (archive, member string, ok bool) {
+	archive, member, ok = strings.Cut(p, ArchiveSeparator)
+	return archive, member, ok && archive != "" && member != ""
+}
+
+// Archive reads the members of a zip or tar archive without extracting them.
+// Tar archives may be gzip, bzip2, zstd or xz compressed.
+type Archive struct {
+	reader io.ReaderAt
+	size   int64
+	// zip is the central directory of a zip archive, nil for tar archives
+	zip *zip.Reader
+	// tar indexes the regular files of a tar archive, it is built by the first scan
+	tarOnce sync.Once
+	tar     []tarEntry
+	tarErr  error
+	// compressed tar archives are read from the start to reach a member
+	tarCompressed bool
+}
+
+// tarEntry locates the content of a tar member in the decompressed archive
+type tarEntry struct {
+	name   string
+	offset int64
+	size   int64
+}
+
+// OpenArchive opens the zip or tar archive, the format is detected by its content
+func OpenArchive(reader io.ReaderAt, size int64) (*Archive, error) {
+	a := &Archive{reader: reader, size: size}
+
+	head := make([]byte, 4)
+	n, err := reader.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	for _, magic := range zipMagics {
+		if bytes.Equal(head[:n], magic) {
+			if a.zip, err = zip.NewReader(reader, size); err != nil {
+				return nil, err
+			}
+			return a, nil
+		}
+	}
+
+	// a tar archive has no directory, its first header tells whether it is one
+	tr, closer, err := a.tarReader()
+	if err != nil {
+		return nil, err
+	}
+	defer closeQuietly(closer)
+	if _, err := tr.Next(); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %w", errNotArchive, err)
+	}
+	return a, nil
+}
+
+// Members lists the regular files of the archive in archive order
+func (a *Archive) Members() ([]string, error) {
+	var members []string
+	if a.zip != nil {
+		for _, f := range a.zip.File {
+			if f.Mode().IsRegular() {
+				members = append(members, memberName(f.Name))
+			}
+		}
+		return members, nil
+	}
+
+	entries, err := a.tarEntries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		members = append(members, e.name)
+	}
+	return members, nil
+}
+
+// Glob returns the sorted members matching the pattern in path.Match syntax, e.g. "PPR-*-Dublin.csv".
+// A pattern matching no member is an error.
+func (a *Archive) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	members, err := a.Members()
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, member := range members {
+		if ok, _ := path.Match(pattern, member); ok {
+			matches = append(matches, member)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w: %q", errArchiveNoMatch, pattern)
+	}
+	slices.Sort(matches)
+	return matches, nil
+}
+
+// Open returns the content of the member, decompressed while it is read.
+// Members of uncompressed tar archives are read in place, compressed ones are
+// decompressed from the start up to the member.
+func (a *Archive) Open(member string) (io.ReadCloser, error) {
+	member = memberName(member)
+	if a.zip != nil {
+		for _, f := range a.zip.File {
+			if f.Mode().IsRegular() && memberName(f.Name) == member {
+				return f.Open()
+			}
+		}
+		return nil, fmt.Errorf("%w: %q", errArchiveMemberMissing, member)
+	}
+
+	entries, err := a.tarEntries()
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(entries, func(e tarEntry) bool { return e.name == member })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %q", errArchiveMemberMissing, member)
+	}
+	entry := entries[i]
+	if !a.tarCompressed {
+		return io.NopCloser(io.NewSectionReader(a.reader, entry.offset, entry.size)), nil
+	}
+
+	reader, err := a.decompressed()
+	if err != nil {
+		return nil, err
+	}
+	closer, _ := reader.(io.Closer)
+	if _, err := io.CopyN(io.Discard, reader, entry.offset); err != nil {
+		closeQuietly(closer)
+		return nil, err
+	}
+	return &tarMember{Reader: io.LimitReader(reader, entry.size), closer: closer}, nil
+}
+
+// tarEntries scans the tar archive once for its regular files
+func (a *Archive) tarEntries() ([]tarEntry, error) {
+	a.tarOnce.Do(func() {
+		a.tar, a.tarErr = a.scanTar()
+	})
+	return a.tar, a.tarErr
+}
+
+// scanTar reads the headers of the tar archive, the offsets of the members are counted
+// in the decompressed archive
+func (a *Archive) scanTar() ([]tarEntry, error) {
+	compression, err := DetectCompression(bufio.NewReader(io.NewSectionReader(a.reader, 0, a.size)))
+	if err != nil {
+		return nil, err
+	}
+	a.tarCompressed = compression != CompressionNone
+	reader, err := a.decompressed()
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	counter := &countingReader{reader: reader}
+	tr := tar.NewReader(counter)
+	var entries []tarEntry
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			// the tar reader reads whole blocks without read-ahead, the content starts here
+			entries = append(entries, tarEntry{name: memberName(hdr.Name), offset: counter.read, size: hdr.Size})
+		}
+	}
+}
+
+// tarReader reads the tar archive from the start, closer releases the decompressor if there is one
+func (a *Archive) tarReader() (*tar.Reader, io.Closer, error) {
+	reader, err := a.decompressed()
+	if err != nil {
+		return nil, nil, err
+	}
+	closer, _ := reader.(io.Closer)
+	return tar.NewReader(reader), closer, nil
+}
+
+// decompressed reads the archive from the start, see Decompress
+func (a *Archive) decompressed() (io.Reader, error) {
+	return Decompress(io.NewSectionReader(a.reader, 0, a.size))
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	read   int64
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// tarMember is an open member of a tar archive
+type tarMember struct {
+	io.Reader
+	closer io.Closer
+}
+
+// Close implements io.Closer.
+func (m *tarMember) Close() error {
+	if m.closer == nil {
+		return nil
+	}
+	return m.closer.Close()
+}
+
+// memberName normalizes member paths, "./data/prices.csv" and "data/prices.csv" name the same member
+func memberName(name string) string {
+	return path.Clean(strings.TrimPrefix(name, "./"))
+}
+
+func closeQuietly(closer io.Closer) {
+	if closer != nil {
+		closer.Close()
+	}
+}
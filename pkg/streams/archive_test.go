@@ -0,0 +1,195 @@
+package streams
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+var archiveFiles = []struct{ name, body string }{
+	{"PPR-2024-Dublin.csv", "Street Name,Price\nAbbey Drive,100\n"},
+	{"./PPR-2024-Cork.csv", "Street Name,Price\nGrand Parade,200\n"},
+	{"trees/dublin-trees.json", `{"short":{}}`},
+}
+
+func zipArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("trees/"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archiveFiles {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, f.body)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: "trees/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archiveFiles {
+		hdr := &tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(f.body))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(tw, f.body)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write(tarArchive(t))
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchive(t *testing.T) {
+	for name, data := range map[string][]byte{"zip": zipArchive(t), "tar": tarArchive(t), "tar.gz": tarGzArchive(t)} {
+		t.Run(name, func(t *testing.T) {
+			a, err := OpenArchive(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("OpenArchive error = %v", err)
+			}
+
+			members, err := a.Members()
+			want := []string{"PPR-2024-Dublin.csv", "PPR-2024-Cork.csv", "trees/dublin-trees.json"}
+			if err != nil || !slices.Equal(members, want) {
+				t.Errorf("Members = %q, %v; want %q", members, err, want)
+			}
+
+			matches, err := a.Glob("PPR-*.csv")
+			want = []string{"PPR-2024-Cork.csv", "PPR-2024-Dublin.csv"}
+			if err != nil || !slices.Equal(matches, want) {
+				t.Errorf("Glob = %q, %v; want %q", matches, err, want)
+			}
+			if _, err := a.Glob("*.parquet"); !errors.Is(err, errArchiveNoMatch) {
+				t.Errorf("Glob error = %v, want %v", err, errArchiveNoMatch)
+			}
+
+			member, err := a.Open("trees/dublin-trees.json")
+			if err != nil {
+				t.Fatalf("Open error = %v", err)
+			}
+			body, err := io.ReadAll(member)
+			member.Close()
+			if err != nil || string(body) != archiveFiles[2].body {
+				t.Errorf("member = %q, %v; want %q", body, err, archiveFiles[2].body)
+			}
+
+			if _, err := a.Open("trees"); !errors.Is(err, errArchiveMemberMissing) {
+				t.Errorf("Open directory error = %v, want %v", err, errArchiveMemberMissing)
+			}
+		})
+	}
+}
+
+func TestArchiveMemberStream(t *testing.T) {
+	data := zipArchive(t)
+	a, err := OpenArchive(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, err := a.Open("./PPR-2024-Cork.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer member.Close()
+
+	s, err := NewCsvStream(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := drainStream(t, s); !slices.Equal(got[0], []string{"Grand Parade", "200"}) {
+		t.Errorf("records = %q", got)
+	}
+}
+
+// readAtCounter counts the bytes read from the archive
+type readAtCounter struct {
+	reader io.ReaderAt
+	read   int64
+}
+
+func (r *readAtCounter) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.reader.ReadAt(p, off)
+	r.read += int64(n)
+	return n, err
+}
+
+func TestArchiveTarOpenInPlace(t *testing.T) {
+	data := tarArchive(t)
+	counter := &readAtCounter{reader: bytes.NewReader(data)}
+	a, err := OpenArchive(counter, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Members(); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range archiveFiles {
+		counter.read = 0
+		member, err := a.Open(f.name)
+		if err != nil {
+			t.Fatalf("Open(%q) error = %v", f.name, err)
+		}
+		body, err := io.ReadAll(member)
+		member.Close()
+		if err != nil || string(body) != f.body {
+			t.Errorf("member %q = %q, %v; want %q", f.name, body, err, f.body)
+		}
+		// only the content is read, the headers are not scanned again
+		if counter.read != int64(len(f.body)) {
+			t.Errorf("member %q read %d archive bytes, want %d", f.name, counter.read, len(f.body))
+		}
+	}
+}
+
+func TestOpenArchiveNotArchive(t *testing.T) {
+	data := []byte("Street Name,Price\nAbbey Drive,100\n")
+	if _, err := OpenArchive(bytes.NewReader(data), int64(len(data))); !errors.Is(err, errNotArchive) {
+		t.Errorf("OpenArchive error = %v, want %v", err, errNotArchive)
+	}
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		path, archive, member string
+		ok                    bool
+	}{
+		{"ppr.zip!/PPR-2024-Dublin.csv", "ppr.zip", "PPR-2024-Dublin.csv", true},
+		{"data/ppr.tar.gz!/2024/*.csv", "data/ppr.tar.gz", "2024/*.csv", true},
+		{"prices.csv", "prices.csv", "", false},
+		{"ppr.zip!/", "ppr.zip", "", false},
+	}
+	for _, tt := range tests {
+		archive, member, ok := SplitArchivePath(tt.path)
+		if ok != tt.ok || (ok && (archive != tt.archive || member != tt.member)) {
+			t.Errorf("SplitArchivePath(%q) = %q, %q, %v; want %q, %q, %v", tt.path, archive, member, ok, tt.archive, tt.member, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// sniffSize is the amount of input inspected when the dialect is guessed
+	sniffSize = 16 * 1024
+	// sniffLines is the maximum number of records used to score a dialect
+	sniffLines = 50
+)
+
+var (
+	errInvalidQuote   = errors.New("csv quote must be a single-byte character other than CR and LF")
+	errInvalidDialect = errors.New("csv delimiter, quote and comment characters must differ")
+
+	utf8BOM           = []byte{0xef, 0xbb, 0xbf}
+	sniffDelimiters   = []rune{',', ';', '\t', '|'}
+	sniffQuotes       = []rune{'"', '\''}
+	defaultCsvDialect = CsvDialect{Delimiter: ',', Quote: '"'}
+
+	_ io.Reader = (*quoteSwapReader)(nil)
+)
+
+// CsvDialect describes the layout of a delimited text file
+type CsvDialect struct {
+	// Delimiter separates fields, ',' by default
+	Delimiter rune
+	// Quote encloses fields containing delimiters or line breaks, '"' by default
+	Quote rune
+	// Comment starts a line which is ignored, zero disables comments
+	Comment rune
+	// LazyQuotes allows quotes in unquoted fields and unescaped quotes in quoted fields
+	LazyQuotes bool
+	// TrimLeadingSpace ignores leading white space in a field
+	TrimLeadingSpace bool
+}
+
+// validate checks the characters which encoding/csv does not check itself
+func (d CsvDialect) validate() error {
+	if d.Quote >= utf8.RuneSelf || d.Quote == '\r' || d.Quote == '\n' {
+		return errInvalidQuote
+	}
+	if d.Quote == d.Delimiter || (d.Comment != 0 && (d.Comment == d.Delimiter || d.Comment == d.Quote)) {
+		return errInvalidDialect
+	}
+	return nil
+}
+
+// skipBOM drops a leading UTF-8 byte order mark, otherwise it ends up in the first header name
+func skipBOM(reader *bufio.Reader) error {
+	head, err := reader.Peek(len(utf8BOM))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if bytes.Equal(head, utf8BOM) {
+		_, err = reader.Discard(len(utf8BOM))
+		return err
+	}
+	return nil
+}
+
+// sniffDialect guesses the delimiter and the quote character from the beginning of the reader.
+// Nothing is consumed from the reader. The dialect with the largest number of records sharing
+// the same field count wins, ties are resolved by the wider record and then by candidate order.
+func sniffDialect(reader *bufio.Reader, dialect CsvDialect) (CsvDialect, error) {
+	sample, err := reader.Peek(sniffSize)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return dialect, err
+	}
+	// the last line is likely cut in the middle
+	if i := bytes.LastIndexByte(sample, '\n'); i > 0 && len(sample) == sniffSize {
+		sample = sample[:i+1]
+	}
+
+	bestScore, bestWidth := 0, 0
+	best := dialect
+	for _, quote := range sniffQuotes {
+		for _, delimiter := range sniffDelimiters {
+			score, width := scoreDialect(sample, delimiter, quote, dialect.Comment)
+			if width < 2 {
+				continue
+			}
+			if score > bestScore || (score == bestScore && width > bestWidth) {
+				bestScore, bestWidth = score, width
+				best.Delimiter, best.Quote = delimiter, quote
+			}
+		}
+	}
+	return best, nil
+}
+
+// scoreDialect splits the sample into records and returns how many records have
+// the most common field count and that field count
+func scoreDialect(sample []byte, delimiter, quote, comment rune) (int, int) {
+	counts := make(map[int]int)
+	fields, records := 1, 0
+	inQuotes, lineStart := false, true
+	skipLine := false
+
+	for _, r := range string(sample) {
+		if lineStart && comment != 0 && r == comment {
+			skipLine = true
+		}
+		lineStart = false
+		if skipLine {
+			if r == '\n' {
+				skipLine, lineStart = false, true
+			}
+			continue
+		}
+		switch {
+		case r == quote:
+			inQuotes = !inQuotes
+		case inQuotes:
+		case r == delimiter:
+			fields++
+		case r == '\n':
+			counts[fields]++
+			records++
+			fields, lineStart = 1, true
+			if records == sniffLines {
+				return modalCount(counts)
+			}
+		}
+	}
+	return modalCount(counts)
+}
+
+func modalCount(counts map[int]int) (int, int) {
+	score, width := 0, 0
+	for w, n := range counts {
+		if n > score || (n == score && w > width) {
+			score, width = n, w
+		}
+	}
+	return score, width
+}
+
+// quoteSwapReader exchanges the configured quote byte with '"' so encoding/csv,
+// which only knows double quotes, can parse the input. Fields are swapped back after parsing.
+type quoteSwapReader struct {
+	reader io.Reader
+	quote  byte
+}
+
+// Read implements io.Reader.
+func (q *quoteSwapReader) Read(p []byte) (int, error) {
+	n, err := q.reader.Read(p)
+	swapQuotes(p[:n], q.quote)
+	return n, err
+}
+
+func swapQuotes(p []byte, quote byte) {
+	for i, b := range p {
+		switch b {
+		case quote:
+			p[i] = '"'
+		case '"':
+			p[i] = quote
+		}
+	}
+}
+
+// swapRecordQuotes restores the original quote characters in parsed fields
+func swapRecordQuotes(record []string, quote byte) {
+	for i, field := range record {
+		if strings.IndexByte(field, quote) < 0 && strings.IndexByte(field, '"') < 0 {
+			continue
+		}
+		b := []byte(field)
+		swapQuotes(b, quote)
+		record[i] = string(b)
+	}
+}
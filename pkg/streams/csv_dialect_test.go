@@ -0,0 +1,142 @@
+package streams
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readAllRecords(t *testing.T, data string, opts ...Option) [][]string {
+	t.Helper()
+	s, err := NewCsvStream(strings.NewReader(data), opts...)
+	if err != nil {
+		t.Fatalf("NewCsvStream() error = %v", err)
+	}
+	records := [][]string{s.GetHeader()}
+	for {
+		rec, err := s.ReadCsvRecord(context.Background())
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("ReadCsvRecord() error = %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestCsvDialects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		opts []Option
+		want [][]string
+	}{
+		{
+			name: "BOM is stripped from the first header",
+			data: "\ufeffStreet Name,Price\nmain street,100\n",
+			want: [][]string{{"Street Name", "Price"}, {"main street", "100"}},
+		},
+		{
+			name: "semicolon delimiter",
+			data: "street;price\nmain street;\"1.234,00\"\n",
+			opts: []Option{WithDelimiter(';')},
+			want: [][]string{{"street", "price"}, {"main street", "1.234,00"}},
+		},
+		{
+			name: "TSV",
+			data: "street\tprice\nmain street\t100\n",
+			opts: []Option{WithTSV()},
+			want: [][]string{{"street", "price"}, {"main street", "100"}},
+		},
+		{
+			name: "comment lines",
+			data: "# exported 2024-01-01\nstreet,price\n# totals follow\nmain street,100\n",
+			opts: []Option{WithComment('#')},
+			want: [][]string{{"street", "price"}, {"main street", "100"}},
+		},
+		{
+			name: "lazy quotes",
+			data: "street,price\nthe \"old\" road,100\n",
+			opts: []Option{WithLazyQuotes()},
+			want: [][]string{{"street", "price"}, {"the \"old\" road", "100"}},
+		},
+		{
+			name: "single quote character",
+			data: "street,price\n'o''connell street, upper',\"100\"\n",
+			opts: []Option{WithQuote('\'')},
+			want: [][]string{{"street", "price"}, {"o'connell street, upper", "\"100\""}},
+		},
+		{
+			name: "sniff semicolon with quoted commas",
+			data: "street;address;price\nmain street;\"1, main street\";\"1,00\"\noak road;\"2, oak road\";\"2,00\"\n",
+			opts: []Option{WithSniff()},
+			want: [][]string{{"street", "address", "price"}, {"main street", "1, main street", "1,00"}, {"oak road", "2, oak road", "2,00"}},
+		},
+		{
+			name: "sniff tabs",
+			data: "street\tprice\nmain, street\t100\n",
+			opts: []Option{WithSniff()},
+			want: [][]string{{"street", "price"}, {"main, street", "100"}},
+		},
+		{
+			name: "sniff single quotes",
+			data: "street,price\n'main, street','1,000'\n'oak road',200\n",
+			opts: []Option{WithSniff()},
+			want: [][]string{{"street", "price"}, {"main, street", "1,000"}, {"oak road", "200"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readAllRecords(t, tt.data, tt.opts...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCsvDialectInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want error
+	}{
+		{name: "multi-byte quote", opts: []Option{WithQuote('€')}, want: errInvalidQuote},
+		{name: "quote equals delimiter", opts: []Option{WithQuote(';'), WithDelimiter(';')}, want: errInvalidDialect},
+		{name: "comment equals delimiter", opts: []Option{WithComment(',')}, want: errInvalidDialect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCsvStream(strings.NewReader("a,b\n"), tt.opts...)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("NewCsvStream() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffDialectKeepsDefaultsForSingleColumn(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader("street\nmain street\n"), sniffSize)
+	got, err := sniffDialect(reader, defaultCsvDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != defaultCsvDialect {
+		t.Errorf("sniffDialect() = %+v, want defaults", got)
+	}
+}
+
+func TestCsvStreamShorterThanBOM(t *testing.T) {
+	for _, data := range []string{"a\n", "a", "\ufeffa\n"} {
+		got := readAllRecords(t, data)
+		if want := [][]string{{"a"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("records of %q = %q, want %q", data, got, want)
+		}
+	}
+}
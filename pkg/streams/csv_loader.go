@@ -3,7 +3,9 @@ package streams
 import (
 	"context"
 	"encoding/csv"
+	"errors"
 	"io"
+	"strconv"
 
 	iface "propertytreeanalyzer/pkg/api/streams"
 )
@@ -11,25 +13,138 @@ import (
 type csvReader struct {
 	reader *csv.Reader
 	header []string
+	// quote is the original quote byte when it was swapped with '"', zero otherwise
+	quote byte
+	// name, offset and fields describe the source and the last record for diagnostics
+	name   string
+	offset int64
+	fields int
+	// pending is the first data row of input without a header row, read to size the synthetic header
+	pending []string
 }
 
-var _ iface.CsvStream = (*csvReader)(nil)
+var (
+	_ iface.CsvStream       = (*csvReader)(nil)
+	_ iface.Positioner      = (*csvReader)(nil)
+	_ iface.FieldPositioner = (*csvReader)(nil)
+)
 
 // NewCsvStream creates a new CSV stream from an io.Reader.
-// It reads the header row immediately.
-func NewCsvStream(reader io.Reader) (iface.CsvStream, error) {
-	csvR := csv.NewReader(reader)
+// Gzip, bzip2, zstd and xz compressed input is detected and decompressed on the fly,
+// the text is transcoded to UTF-8 and a leading byte order mark is dropped.
+// It reads the header row immediately, unless WithHeader names the columns.
+func NewCsvStream(reader io.Reader, opts ...Option) (iface.CsvStream, error) {
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered, err := newTextReader(reader, cfg.encoding)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.sniff {
+		dialect, err := sniffDialect(buffered, cfg.dialect)
+		if err != nil {
+			return nil, err
+		}
+		cfg.dialect = dialect
+	}
+	if err := cfg.dialect.validate(); err != nil {
+		return nil, err
+	}
 
+	c := &csvReader{name: cfg.name}
+	c.reader, c.quote = newDialectReader(buffered, cfg.dialect)
+
+	if cfg.header != nil {
+		c.header = cfg.header
+		return c, nil
+	}
 	// Read header row
-	header, err := csvR.Read()
+	header, err := c.read()
 	if err != nil {
 		return nil, err
 	}
+	if cfg.syntheticHeader {
+		c.header, c.pending = syntheticHeader(len(header)), header
+		return c, nil
+	}
+	c.header = header
+	return c, nil
+}
 
-	return &csvReader{
-		reader: csvR,
-		header: header,
-	}, nil
+// syntheticHeader names n columns "col1" to "colN"
+func syntheticHeader(n int) []string {
+	header := make([]string, n)
+	for i := range header {
+		header[i] = "col" + strconv.Itoa(i+1)
+	}
+	return header
+}
+
+// newDialectReader configures encoding/csv for a validated dialect.
+// It returns the quote byte swapped with '"' in the input, zero when the quote is '"'.
+func newDialectReader(reader io.Reader, dialect CsvDialect) (*csv.Reader, byte) {
+	var quote byte
+	if dialect.Quote != '"' {
+		quote = byte(dialect.Quote)
+		reader = &quoteSwapReader{reader: reader, quote: quote}
+	}
+
+	csvR := csv.NewReader(reader)
+	csvR.Comma = swapRune(dialect.Delimiter, quote)
+	csvR.Comment = swapRune(dialect.Comment, quote)
+	csvR.LazyQuotes = dialect.LazyQuotes
+	csvR.TrimLeadingSpace = dialect.TrimLeadingSpace
+	return csvR, quote
+}
+
+// swapRune maps a dialect character into the swapped input seen by encoding/csv
+func swapRune(r rune, quote byte) rune {
+	switch {
+	case quote == 0:
+		return r
+	case r == rune(quote):
+		return '"'
+	case r == '"':
+		return rune(quote)
+	default:
+		return r
+	}
+}
+
+func (c *csvReader) read() ([]string, error) {
+	offset := c.reader.InputOffset()
+	record, err := c.reader.Read()
+	if record != nil {
+		c.offset, c.fields = offset, len(record)
+		if c.quote != 0 {
+			swapRecordQuotes(record, c.quote)
+		}
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		err = positionError(iface.Position{Source: c.name, Line: parseErr.Line, Offset: offset}, err)
+	}
+	return record, err
+}
+
+// Position implements Positioner.
+func (c *csvReader) Position() iface.Position {
+	return c.FieldPosition(0)
+}
+
+// FieldPosition implements FieldPositioner. The column is the 1-based field number.
+func (c *csvReader) FieldPosition(field int) iface.Position {
+	pos := iface.Position{Source: c.name, Column: field + 1, Offset: c.offset}
+	if c.fields == 0 {
+		return pos
+	}
+	// encoding/csv panics for fields out of range, the record line is still known
+	line, _ := c.reader.FieldPos(min(max(field, 0), c.fields-1))
+	pos.Line = line
+	return pos
 }
 
 // ReadCsvRecord implements CsvStream.
@@ -41,8 +156,12 @@ func (c *csvReader) ReadCsvRecord(ctx context.Context) ([]string, error) {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
+		if record := c.pending; record != nil {
+			c.pending = nil
+			return record, nil
+		}
 		// Continue reading CSV records
-		return c.reader.Read()
+		return c.read()
 	}
 }
 
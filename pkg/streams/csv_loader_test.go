@@ -0,0 +1,88 @@
+package streams
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+func TestCsvStreamHeaderless(t *testing.T) {
+	const data = "2024-01-05,1 Abbey Drive,Abbey Drive,100\n2024-02-11,7 Temple Gardens,Temple Gardens,200\n"
+	wantRecords := [][]string{
+		{"2024-01-05", "1 Abbey Drive", "Abbey Drive", "100"},
+		{"2024-02-11", "7 Temple Gardens", "Temple Gardens", "200"},
+	}
+	constructors := map[string]func(opt Option) (iface.CsvStream, error){
+		"sequential": func(opt Option) (iface.CsvStream, error) {
+			return NewCsvStream(strings.NewReader(data), opt)
+		},
+		"parallel": func(opt Option) (iface.CsvStream, error) {
+			return NewParallelCsvStream(strings.NewReader(data), int64(len(data)), opt, WithChunkSize(16), WithOrdered())
+		},
+		"mapped": func(opt Option) (iface.CsvStream, error) {
+			return NewMappedCsvStream(strings.NewReader(data), int64(len(data)), opt, WithChunkSize(16))
+		},
+	}
+	headers := map[string]struct {
+		opt  Option
+		want []string
+	}{
+		"explicit":  {WithHeader("date", "address", "street", "price"), []string{"date", "address", "street", "price"}},
+		"synthetic": {WithSyntheticHeader(), []string{"col1", "col2", "col3", "col4"}},
+	}
+
+	for streamName, newStream := range constructors {
+		for headerName, h := range headers {
+			t.Run(streamName+"/"+headerName, func(t *testing.T) {
+				s, err := newStream(h.opt)
+				if err != nil {
+					t.Fatalf("new stream error = %v", err)
+				}
+				if header := s.GetHeader(); !slices.Equal(header, h.want) {
+					t.Errorf("GetHeader = %q, want %q", header, h.want)
+				}
+				records, lines := drainStream(t, s)
+				if !slices.EqualFunc(records, wantRecords, slices.Equal) {
+					t.Errorf("records = %q, want %q", records, wantRecords)
+				}
+				if !slices.Equal(lines, []int{1, 2}) {
+					t.Errorf("lines = %v, want [1 2]", lines)
+				}
+			})
+		}
+	}
+}
+
+func TestCsvStreamEmptyHeader(t *testing.T) {
+	if _, err := NewCsvStream(strings.NewReader("a,b\n"), WithHeader()); !errors.Is(err, errEmptyHeader) {
+		t.Errorf("NewCsvStream error = %v, want %v", err, errEmptyHeader)
+	}
+}
+
+func TestCsvStreamRecords(t *testing.T) {
+	const data = "street,price\nAbbey Drive,100\nTemple Gardens,200\nElm Road,300\n"
+	s, err := NewCsvStream(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewCsvStream error = %v", err)
+	}
+	var streets []string
+	for record, err := range iface.Records(t.Context(), s) {
+		if err != nil {
+			t.Fatalf("Records error = %v", err)
+		}
+		streets = append(streets, record[0])
+		if len(streets) == 2 {
+			break
+		}
+	}
+	if want := []string{"Abbey Drive", "Temple Gardens"}; !slices.Equal(streets, want) {
+		t.Errorf("streets = %q, want %q", streets, want)
+	}
+	// breaking out leaves the remaining records in the stream
+	if record, err := s.ReadCsvRecord(t.Context()); err != nil || record[0] != "Elm Road" {
+		t.Errorf("ReadCsvRecord after break = %q, %v, want Elm Road", record, err)
+	}
+}
@@ -0,0 +1,102 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression identifies the compression format of an input stream
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionBzip2
+	CompressionZstd
+	CompressionXz
+)
+
+// magicPeekSize is the number of bytes needed to recognise every supported format
+const magicPeekSize = 6
+
+var compressionMagics = []struct {
+	compression Compression
+	magic       []byte
+}{
+	{CompressionGzip, []byte{0x1f, 0x8b, 0x08}},
+	{CompressionBzip2, []byte("BZh")},
+	{CompressionZstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{CompressionXz, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+}
+
+// String returns the conventional name of the compression format
+func (c Compression) String() string {
+	switch c {
+	case CompressionGzip:
+		return "gzip"
+	case CompressionBzip2:
+		return "bzip2"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionXz:
+		return "xz"
+	default:
+		return "none"
+	}
+}
+
+// DetectCompression peeks at the first bytes of the reader and reports the compression
+// format they belong to. Nothing is consumed from the reader.
+func DetectCompression(reader *bufio.Reader) (Compression, error) {
+	head, err := reader.Peek(magicPeekSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return CompressionNone, err
+	}
+	for _, m := range compressionMagics {
+		if !bytes.HasPrefix(head, m.magic) {
+			continue
+		}
+		// bzip2 magic is followed by the block size digit, this makes "BZh" text unlikely to match
+		if m.compression == CompressionBzip2 && (len(head) < 4 || head[3] < '1' || head[3] > '9') {
+			continue
+		}
+		return m.compression, nil
+	}
+	return CompressionNone, nil
+}
+
+// Decompress detects the compression format of the reader by its magic bytes and
+// returns a reader producing the decompressed data.
+// Uncompressed input is returned as is (buffered).
+func Decompress(reader io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(reader)
+	compression, err := DetectCompression(buffered)
+	if err != nil {
+		return nil, err
+	}
+
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(buffered)
+	case CompressionBzip2:
+		return bzip2.NewReader(buffered), nil
+	case CompressionZstd:
+		// single goroutine decoder, so nothing has to be closed when the stream is abandoned
+		decoder, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressionXz:
+		return xz.NewReader(buffered)
+	default:
+		return buffered, nil
+	}
+}
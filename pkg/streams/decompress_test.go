@@ -0,0 +1,123 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+const compressedCsv = "col1,col2\nval1,val2\n"
+
+// bzip2 -9 output for compressedCsv, the standard library has no bzip2 writer
+var bzip2Csv = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x0c, 0x80,
+	0x24, 0x0d, 0x00, 0x00, 0x05, 0x59, 0x80, 0x00, 0x10, 0x00, 0x04, 0x30,
+	0x00, 0x28, 0x04, 0x81, 0x00, 0x20, 0x00, 0x21, 0x28, 0x34, 0x34, 0x20,
+	0xc9, 0x88, 0xb2, 0xcd, 0x06, 0x2c, 0x7c, 0x4b, 0x12, 0xf1, 0x77, 0x24,
+	0x53, 0x85, 0x09, 0x00, 0xc8, 0x02, 0x40, 0xd0,
+}
+
+func compressWith(t *testing.T, data string, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newWriter(&buf)
+	if err != nil {
+		t.Fatalf("create writer: %v", err)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func gzipWriter(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }
+func zstdWriter(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }
+func xzWriter(w io.Writer) (io.WriteCloser, error)   { return xz.NewWriter(w) }
+
+func TestDecompressCsvStream(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		compression Compression
+	}{
+		{name: "plain", data: []byte(compressedCsv), compression: CompressionNone},
+		{name: "gzip", data: compressWith(t, compressedCsv, gzipWriter), compression: CompressionGzip},
+		{name: "bzip2", data: bzip2Csv, compression: CompressionBzip2},
+		{name: "zstd", data: compressWith(t, compressedCsv, zstdWriter), compression: CompressionZstd},
+		{name: "xz", data: compressWith(t, compressedCsv, xzWriter), compression: CompressionXz},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectCompression(bufio.NewReader(bytes.NewReader(tt.data)))
+			if err != nil {
+				t.Fatalf("DetectCompression() error = %v", err)
+			}
+			if got != tt.compression {
+				t.Errorf("DetectCompression() = %v, want %v", got, tt.compression)
+			}
+
+			s, err := NewCsvStream(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("NewCsvStream() error = %v", err)
+			}
+			if !reflect.DeepEqual(s.GetHeader(), []string{"col1", "col2"}) {
+				t.Errorf("header = %v", s.GetHeader())
+			}
+			rec, err := s.ReadCsvRecord(context.Background())
+			if err != nil {
+				t.Fatalf("ReadCsvRecord() error = %v", err)
+			}
+			if !reflect.DeepEqual(rec, []string{"val1", "val2"}) {
+				t.Errorf("record = %v", rec)
+			}
+			if _, err := s.ReadCsvRecord(context.Background()); err != io.EOF {
+				t.Errorf("expected io.EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDecompressJsonStream(t *testing.T) {
+	data := compressWith(t, `{"short":{"abbey drive":0}}`, gzipWriter)
+	s := NewJsonStream(bytes.NewReader(data))
+
+	var keys []string
+	for {
+		tok, err := s.ReadJsonToken(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadJsonToken() error = %v", err)
+		}
+		if key, ok := tok.(string); ok {
+			keys = append(keys, key)
+		}
+		if _, ok := tok.(json.Number); ok {
+			keys = append(keys, "#")
+		}
+	}
+	if got := strings.Join(keys, ","); got != "short,abbey drive,#" {
+		t.Errorf("tokens = %s", got)
+	}
+}
+
+func TestDecompressCorruptInput(t *testing.T) {
+	// valid gzip magic with a truncated header
+	if _, err := NewCsvStream(bytes.NewReader([]byte{0x1f, 0x8b, 0x08, 0x00})); err == nil {
+		t.Error("expected error for truncated gzip input")
+	}
+}
@@ -0,0 +1,146 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// AutoEncoding is the encoding name which enables detection
+const AutoEncoding = "auto"
+
+var (
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+
+	_ transform.Transformer = utf8Fallback{}
+)
+
+// LookupEncoding returns the encoding registered under the WHATWG name or label,
+// e.g. "utf-8", "windows-1252", "latin1", "iso-8859-15" or "utf-16le".
+// AutoEncoding and the empty name return nil which stands for detection.
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, AutoEncoding) {
+		return nil, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown encoding %q: %w", name, err)
+	}
+	return enc, nil
+}
+
+// newTextReader turns raw input into UTF-8 text: it decompresses the input,
+// transcodes it from the configured or detected encoding and drops a byte order mark
+func newTextReader(reader io.Reader, enc encoding.Encoding) (*bufio.Reader, error) {
+	decompressed, err := Decompress(reader)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewReaderSize(decompressed, sniffSize)
+
+	var decoder *encoding.Decoder
+	if enc != nil {
+		decoder = enc.NewDecoder()
+	} else {
+		detected, err := detectEncoding(buffered)
+		if err != nil {
+			return nil, err
+		}
+		decoder = detected.NewDecoder()
+	}
+
+	text := bufio.NewReaderSize(transform.NewReader(buffered, decoder), sniffSize)
+	if err := skipBOM(text); err != nil {
+		return nil, err
+	}
+	return text, nil
+}
+
+// detectEncoding picks UTF-16 when the input starts with its byte order mark,
+// otherwise UTF-8 with a per-byte Windows-1252 fallback for invalid sequences.
+// The fallback handles legacy exports which are mostly ASCII and only
+// occasionally contain a euro sign or an accented letter.
+func detectEncoding(reader *bufio.Reader) (encoding.Encoding, error) {
+	head, err := reader.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	switch {
+	case bytes.Equal(head, utf16LEBOM):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), nil
+	case bytes.Equal(head, utf16BEBOM):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), nil
+	default:
+		return utf8WithFallback, nil
+	}
+}
+
+// utf8WithFallback is the encoding used when the input has no byte order mark
+var utf8WithFallback encoding.Encoding = fallbackEncoding{}
+
+type fallbackEncoding struct{}
+
+// NewDecoder implements encoding.Encoding.
+func (fallbackEncoding) NewDecoder() *encoding.Decoder {
+	return &encoding.Decoder{Transformer: utf8Fallback{}}
+}
+
+// NewEncoder implements encoding.Encoding.
+func (fallbackEncoding) NewEncoder() *encoding.Encoder {
+	return unicode.UTF8.NewEncoder()
+}
+
+// utf8Fallback copies valid UTF-8 and decodes every byte which is not part of
+// a valid UTF-8 sequence as Windows-1252
+type utf8Fallback struct {
+	transform.NopResetter
+}
+
+// Transform implements transform.Transformer.
+func (utf8Fallback) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for nSrc < len(src) {
+		if b := src[nSrc]; b < utf8.RuneSelf {
+			if nDst >= len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			dst[nDst] = b
+			nDst++
+			nSrc++
+			continue
+		}
+
+		r, size := utf8.DecodeRune(src[nSrc:])
+		if r != utf8.RuneError || size > 1 {
+			if nDst+size > len(dst) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			nDst += copy(dst[nDst:], src[nSrc:nSrc+size])
+			nSrc += size
+			continue
+		}
+		if !atEOF && !utf8.FullRune(src[nSrc:]) {
+			// the sequence may continue in the next chunk
+			return nDst, nSrc, transform.ErrShortSrc
+		}
+
+		r = charmap.Windows1252.DecodeByte(src[nSrc])
+		if nDst+utf8.RuneLen(r) > len(dst) {
+			return nDst, nSrc, transform.ErrShortDst
+		}
+		nDst += utf8.EncodeRune(dst[nDst:], r)
+		nSrc++
+	}
+	return nDst, nSrc, nil
+}
@@ -0,0 +1,116 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+)
+
+func TestCsvStreamEncodings(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		opts []Option
+		want []string
+	}{
+		{
+			name: "utf-8 is kept",
+			data: []byte("street,price\nsráid mhór,€100\n"),
+			want: []string{"sráid mhór", "€100"},
+		},
+		{
+			name: "windows-1252 is detected",
+			data: []byte("street,price\nsr\xe1id mh\xf3r,\x80100\n"),
+			want: []string{"sráid mhór", "€100"},
+		},
+		{
+			name: "mixed utf-8 and windows-1252",
+			data: []byte("street,price\nsráid mh\xf3r,\x80100\n"),
+			want: []string{"sráid mhór", "€100"},
+		},
+		{
+			name: "explicit windows-1252",
+			data: []byte("street,price\nsr\xe1id mh\xf3r,\x80100\n"),
+			opts: []Option{WithEncoding("windows-1252")},
+			want: []string{"sráid mhór", "€100"},
+		},
+		{
+			name: "latin1 label",
+			data: []byte("street,price\nsr\xe1id,100\n"),
+			opts: []Option{WithEncoding("latin1")},
+			want: []string{"sráid", "100"},
+		},
+		{
+			name: "utf-16le with BOM",
+			data: []byte("\xff\xfes\x00,\x00p\x00\n\x00\xe1\x00,\x00\xac\x20\n\x00"),
+			want: []string{"á", "€"},
+		},
+		{
+			name: "explicit utf-8 replaces invalid bytes",
+			data: []byte("street,price\nsr\xe1id,100\n"),
+			opts: []Option{WithEncoding("utf-8")},
+			want: []string{"sr�id", "100"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// one byte at a time makes multi-byte sequences cross read boundaries
+			s, err := NewCsvStream(iotest.OneByteReader(bytes.NewReader(tt.data)), tt.opts...)
+			if err != nil {
+				t.Fatalf("NewCsvStream() error = %v", err)
+			}
+			rec, err := s.ReadCsvRecord(context.Background())
+			if err != nil {
+				t.Fatalf("ReadCsvRecord() error = %v", err)
+			}
+			if !reflect.DeepEqual(rec, tt.want) {
+				t.Errorf("record = %q, want %q", rec, tt.want)
+			}
+			for _, field := range rec {
+				if !utf8.ValidString(field) {
+					t.Errorf("field %q is not valid UTF-8", field)
+				}
+			}
+		})
+	}
+}
+
+func TestJsonStreamEncoding(t *testing.T) {
+	s := NewJsonStream(bytes.NewReader([]byte("\xef\xbb\xbf{\"sr\xe1id\":1}")))
+	var keys []string
+	for {
+		tok, err := s.ReadJsonToken(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadJsonToken() error = %v", err)
+		}
+		if key, ok := tok.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	if !reflect.DeepEqual(keys, []string{"sráid"}) {
+		t.Errorf("keys = %q", keys)
+	}
+}
+
+func TestUnknownEncoding(t *testing.T) {
+	if _, err := NewCsvStream(bytes.NewReader([]byte("a,b\n")), WithEncoding("klingon")); err == nil {
+		t.Error("expected error for unknown encoding")
+	}
+}
+
+func TestJsonStreamOpenError(t *testing.T) {
+	want := errors.New("disk on fire")
+	s := NewJsonStream(iotest.ErrReader(want))
+	if _, err := s.ReadJsonToken(context.Background()); !errors.Is(err, want) {
+		t.Errorf("ReadJsonToken() error = %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,112 @@
+package streams
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	errLayoutNoColumns    = errors.New("fixed-width layout has no columns")
+	errLayoutColumn       = errors.New("fixed-width column needs a name, a start from 1 and a positive width")
+	errLayoutDuplicate    = errors.New("fixed-width column name is repeated")
+	errLayoutNegativeSkip = errors.New("fixed-width header and footer line counts cannot be negative")
+)
+
+// FixedWidthColumn is a column of a fixed-width layout
+type FixedWidthColumn struct {
+	Name string `yaml:"name"`
+	// Start is the 1-based character position of the first character of the column
+	Start int `yaml:"start"`
+	// Width is the number of characters of the column including its padding
+	Width int `yaml:"width"`
+}
+
+// FixedWidthLayout describes the columns of a fixed-width text file
+type FixedWidthLayout struct {
+	Columns []FixedWidthColumn `yaml:"columns"`
+	// Header is the number of lines skipped at the start, e.g. a title or column captions
+	Header int `yaml:"header"`
+	// Footer is the number of lines dropped at the end, e.g. a record count trailer
+	Footer int `yaml:"footer"`
+}
+
+// ParseFixedWidthLayout parses an inline layout of comma separated "name:start:width" columns,
+// e.g. "Street Name:1:40,Price:41:12". Names may contain spaces but no commas.
+func ParseFixedWidthLayout(spec string) (FixedWidthLayout, error) {
+	var layout FixedWidthLayout
+	for _, part := range strings.Split(spec, ",") {
+		rest, widthText, ok := cutLast(part, ":")
+		name, startText, ok2 := cutLast(rest, ":")
+		if !ok || !ok2 {
+			return FixedWidthLayout{}, fmt.Errorf("%w: %q", errLayoutColumn, part)
+		}
+		start, err := strconv.Atoi(strings.TrimSpace(startText))
+		if err != nil {
+			return FixedWidthLayout{}, fmt.Errorf("%w: %q", errLayoutColumn, part)
+		}
+		width, err := strconv.Atoi(strings.TrimSpace(widthText))
+		if err != nil {
+			return FixedWidthLayout{}, fmt.Errorf("%w: %q", errLayoutColumn, part)
+		}
+		layout.Columns = append(layout.Columns, FixedWidthColumn{Name: strings.TrimSpace(name), Start: start, Width: width})
+	}
+	return layout, layout.validate()
+}
+
+// LoadFixedWidthLayout reads a layout file in JSON or YAML, e.g.
+//
+//	header: 1
+//	columns:
+//	  - {name: Street Name, start: 1, width: 40}
+//	  - {name: Price, start: 41, width: 12}
+func LoadFixedWidthLayout(path string) (FixedWidthLayout, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return FixedWidthLayout{}, err
+	}
+	// JSON documents are YAML as well
+	decoder := yaml.NewDecoder(bytes.NewReader(raw))
+	decoder.KnownFields(true)
+	var layout FixedWidthLayout
+	if err := decoder.Decode(&layout); err != nil {
+		return FixedWidthLayout{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := layout.validate(); err != nil {
+		return FixedWidthLayout{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return layout, nil
+}
+
+func (l FixedWidthLayout) validate() error {
+	if len(l.Columns) == 0 {
+		return errLayoutNoColumns
+	}
+	if l.Header < 0 || l.Footer < 0 {
+		return errLayoutNegativeSkip
+	}
+	seen := make(map[string]bool, len(l.Columns))
+	for _, c := range l.Columns {
+		if c.Name == "" || c.Start < 1 || c.Width < 1 {
+			return fmt.Errorf("%w: %+v", errLayoutColumn, c)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("%w: %q", errLayoutDuplicate, c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,167 @@
+package streams
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+// fixedWidthLine is a data line read ahead of the footer check
+type fixedWidthLine struct {
+	text   string
+	line   int
+	offset int64
+}
+
+type fixedWidthReader struct {
+	reader *bufio.Reader
+	layout FixedWidthLayout
+	header []string
+	// ahead holds the lines read but not returned yet, the last Footer of them may be the footer
+	ahead []fixedWidthLine
+	eof   bool
+	// line and next locate the next line of the input
+	line int
+	next int64
+	// name and last describe the source and the last record for diagnostics
+	name string
+	last fixedWidthLine
+}
+
+var (
+	_ iface.CsvStream       = (*fixedWidthReader)(nil)
+	_ iface.Positioner      = (*fixedWidthReader)(nil)
+	_ iface.FieldPositioner = (*fixedWidthReader)(nil)
+)
+
+// NewFixedWidthStream creates a CSV stream reading a fixed-width text file with the layout.
+// The header is the list of layout column names. Fields are cut by character position after the
+// input is decompressed and transcoded to UTF-8, padding spaces are trimmed, and columns beyond
+// the end of a short line are empty. Blank lines are skipped.
+func NewFixedWidthStream(reader io.Reader, layout FixedWidthLayout, opts ...Option) (iface.CsvStream, error) {
+	if err := layout.validate(); err != nil {
+		return nil, err
+	}
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	text, err := newTextReader(reader, cfg.encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &fixedWidthReader{reader: text, layout: layout, name: cfg.name}
+	for _, c := range layout.Columns {
+		f.header = append(f.header, c.Name)
+	}
+	for range layout.Header {
+		if _, err := f.readLine(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// readLine returns the next line without its line break
+func (f *fixedWidthReader) readLine() (fixedWidthLine, error) {
+	text, err := f.reader.ReadString('\n')
+	if text == "" && err != nil {
+		return fixedWidthLine{}, err
+	}
+	l := fixedWidthLine{text: strings.TrimRight(text, "\r\n"), line: f.line + 1, offset: f.next}
+	f.line++
+	f.next += int64(len(text))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return l, err
+	}
+	return l, nil
+}
+
+// ReadCsvRecord implements CsvStream.
+func (f *fixedWidthReader) ReadCsvRecord(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// keep Footer lines ahead, they are dropped at the end of input
+	for !f.eof && len(f.ahead) <= f.layout.Footer {
+		l, err := f.readLine()
+		if errors.Is(err, io.EOF) {
+			f.eof = true
+			break
+		}
+		if err != nil {
+			return nil, positionError(f.positionOf(l, 0), err)
+		}
+		if strings.TrimSpace(l.text) != "" {
+			f.ahead = append(f.ahead, l)
+		}
+	}
+	if len(f.ahead) <= f.layout.Footer {
+		f.ahead = nil
+		return nil, io.EOF
+	}
+
+	f.last = f.ahead[0]
+	f.ahead = f.ahead[1:]
+	return f.split(f.last.text), nil
+}
+
+// split cuts the line into the layout columns
+func (f *fixedWidthReader) split(text string) []string {
+	ascii := isASCII(text)
+	var runes []rune
+	if !ascii {
+		runes = []rune(text)
+	}
+	record := make([]string, len(f.layout.Columns))
+	for i, c := range f.layout.Columns {
+		start, end := c.Start-1, c.Start-1+c.Width
+		var field string
+		if ascii {
+			if start < len(text) {
+				field = text[start:min(end, len(text))]
+			}
+		} else if start < len(runes) {
+			field = string(runes[start:min(end, len(runes))])
+		}
+		record[i] = strings.TrimSpace(field)
+	}
+	return record
+}
+
+// Position implements Positioner.
+func (f *fixedWidthReader) Position() iface.Position {
+	return f.FieldPosition(0)
+}
+
+// FieldPosition implements FieldPositioner. The column is the 1-based field number.
+func (f *fixedWidthReader) FieldPosition(field int) iface.Position {
+	return f.positionOf(f.last, field)
+}
+
+func (f *fixedWidthReader) positionOf(l fixedWidthLine, field int) iface.Position {
+	return iface.Position{Source: f.name, Line: l.line, Column: field + 1, Offset: l.offset}
+}
+
+// GetHeader implements CsvStream.
+func (f *fixedWidthReader) GetHeader() []string {
+	return f.header
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
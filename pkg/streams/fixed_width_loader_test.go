@@ -0,0 +1,122 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const fixedWidthData = "VALUATION OFFICE EXTRACT 2009\n" +
+	"Abbey Drive          100000   \r\n" +
+	"\n" +
+	"Sráid Mhuire         250000\n" +
+	"Short\n" +
+	"TOTAL 3\n"
+
+var fixedWidthTestLayout = FixedWidthLayout{
+	Columns: []FixedWidthColumn{{Name: "Street Name", Start: 1, Width: 21}, {Name: "Price", Start: 22, Width: 9}},
+	Header:  1,
+	Footer:  1,
+}
+
+func TestFixedWidthStream(t *testing.T) {
+	s, err := NewFixedWidthStream(strings.NewReader(fixedWidthData), fixedWidthTestLayout, WithName("extract.txt"))
+	if err != nil {
+		t.Fatalf("NewFixedWidthStream error = %v", err)
+	}
+	if header := s.GetHeader(); !slices.Equal(header, []string{"Street Name", "Price"}) {
+		t.Errorf("GetHeader = %q", header)
+	}
+
+	want := []struct {
+		record []string
+		pos    string
+	}{
+		{[]string{"Abbey Drive", "100000"}, "extract.txt:2:2"},
+		{[]string{"Sráid Mhuire", "250000"}, "extract.txt:4:2"},
+		{[]string{"Short", ""}, "extract.txt:5:2"},
+	}
+	for _, w := range want {
+		record, err := s.ReadCsvRecord(context.Background())
+		if err != nil {
+			t.Fatalf("ReadCsvRecord error = %v", err)
+		}
+		if !slices.Equal(record, w.record) {
+			t.Errorf("ReadCsvRecord = %q, want %q", record, w.record)
+		}
+		if pos := s.(*fixedWidthReader).FieldPosition(1).String(); pos != w.pos {
+			t.Errorf("FieldPosition = %s, want %s", pos, w.pos)
+		}
+	}
+	if _, err := s.ReadCsvRecord(context.Background()); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadCsvRecord after the footer error = %v, want EOF", err)
+	}
+}
+
+func TestFixedWidthStreamWindows1252(t *testing.T) {
+	// "é" is a single byte in Windows-1252, so columns stay aligned after decoding
+	data := "Caf\xe9 Street  100\n"
+	layout := FixedWidthLayout{Columns: []FixedWidthColumn{{Name: "Street Name", Start: 1, Width: 13}, {Name: "Price", Start: 14, Width: 3}}}
+	s, err := NewFixedWidthStream(strings.NewReader(data), layout, WithEncoding("windows-1252"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := s.ReadCsvRecord(context.Background())
+	if err != nil || !slices.Equal(record, []string{"Café Street", "100"}) {
+		t.Errorf("ReadCsvRecord = %q, %v", record, err)
+	}
+}
+
+func TestParseFixedWidthLayout(t *testing.T) {
+	layout, err := ParseFixedWidthLayout("Street Name:1:21, Price:22:9")
+	if err != nil {
+		t.Fatalf("ParseFixedWidthLayout error = %v", err)
+	}
+	if !slices.Equal(layout.Columns, fixedWidthTestLayout.Columns) {
+		t.Errorf("columns = %+v, want %+v", layout.Columns, fixedWidthTestLayout.Columns)
+	}
+
+	for spec, want := range map[string]error{
+		"Street Name:1":             errLayoutColumn,
+		"Street Name:0:10":          errLayoutColumn,
+		"Price:1:10,Price:11:5":     errLayoutDuplicate,
+		"Street Name:1:x,Price:1:1": errLayoutColumn,
+	} {
+		if _, err := ParseFixedWidthLayout(spec); !errors.Is(err, want) {
+			t.Errorf("ParseFixedWidthLayout(%q) error = %v, want %v", spec, err, want)
+		}
+	}
+}
+
+func TestLoadFixedWidthLayout(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"layout.yaml": "header: 1\nfooter: 1\ncolumns:\n  - {name: Street Name, start: 1, width: 21}\n  - name: Price\n    start: 22\n    width: 9\n",
+		"layout.json": `{"header": 1, "footer": 1, "columns": [{"name": "Street Name", "start": 1, "width": 21}, {"name": "Price", "start": 22, "width": 9}]}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		layout, err := LoadFixedWidthLayout(path)
+		if err != nil {
+			t.Errorf("LoadFixedWidthLayout(%s) error = %v", name, err)
+			continue
+		}
+		if !slices.Equal(layout.Columns, fixedWidthTestLayout.Columns) || layout.Header != 1 || layout.Footer != 1 {
+			t.Errorf("LoadFixedWidthLayout(%s) = %+v, want %+v", name, layout, fixedWidthTestLayout)
+		}
+	}
+
+	path := filepath.Join(dir, "typo.yaml")
+	os.WriteFile(path, []byte("colums: []\n"), 0o644)
+	if _, err := LoadFixedWidthLayout(path); err == nil {
+		t.Errorf("LoadFixedWidthLayout with an unknown field succeeded")
+	}
+}
@@ -0,0 +1,129 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// FollowReader reads a file which is appended to, like "tail -F". At the end of the file it waits
+// for more data instead of returning io.EOF. A file truncated below the read offset is read again
+// from the start, and when the path is renamed or removed and created again (log rotation)
+// the rest of the old file is read before the new one is opened.
+type FollowReader struct {
+	ctx  context.Context
+	path string
+	poll time.Duration
+	file *os.File
+	info os.FileInfo
+	// offset is the read offset in the current file
+	offset int64
+	// skipHeader drops the first line of a truncated or rotated file, it repeats the header
+	skipHeader bool
+	skipping   bool
+}
+
+var (
+	_ io.Reader = (*FollowReader)(nil)
+	_ io.Closer = (*FollowReader)(nil)
+)
+
+// Follow opens the file at path for following, it is checked for new data every poll interval.
+// With skipHeader the first line of every truncated or replaced file is dropped, so a header
+// row written again is not read as a record. Read returns io.EOF once the context is done,
+// so the streams reading the file finish normally.
+func Follow(ctx context.Context, path string, poll time.Duration, skipHeader bool) (*FollowReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &FollowReader{ctx: ctx, path: path, poll: poll, file: file, info: info, skipHeader: skipHeader}, nil
+}
+
+// Read implements io.Reader.
+func (f *FollowReader) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		f.offset += int64(n)
+		if f.skipping && n > 0 {
+			n = f.skipLine(p[:n])
+		}
+		if n > 0 {
+			return n, nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		if err := f.wait(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// skipLine drops the bytes of the skipped line from the data read and returns the length of the rest
+func (f *FollowReader) skipLine(data []byte) int {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		return 0
+	}
+	f.skipping = false
+	return copy(data, data[i+1:])
+}
+
+// wait sleeps until the file may have changed and handles truncation and rotation.
+// It returns io.EOF when the context is done.
+func (f *FollowReader) wait() error {
+	timer := time.NewTimer(f.poll)
+	defer timer.Stop()
+	select {
+	case <-f.ctx.Done():
+		return io.EOF
+	case <-timer.C:
+	}
+
+	if info, err := os.Stat(f.path); err == nil && !os.SameFile(info, f.info) {
+		// the rest of the old file was read before the check, data written to it since is read first
+		if rest, err := f.file.Seek(0, io.SeekEnd); err == nil && rest > f.offset {
+			f.file.Seek(f.offset, io.SeekStart)
+			return nil
+		}
+		file, err := os.Open(f.path)
+		if err != nil {
+			// the new file is not there yet, the next poll tries again
+			return nil
+		}
+		slog.InfoContext(f.ctx, "Following the replaced file", "path", f.path)
+		f.file.Close()
+		f.file, f.info, f.offset = file, info, 0
+		f.skipping = f.skipHeader
+		return nil
+	}
+
+	info, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < f.offset {
+		slog.InfoContext(f.ctx, "Followed file was truncated, reading it from the start", "path", f.path)
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		f.offset = 0
+		f.skipping = f.skipHeader
+	}
+	return nil
+}
+
+// Close implements io.Closer.
+func (f *FollowReader) Close() error {
+	return f.file.Close()
+}
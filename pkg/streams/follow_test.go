@@ -0,0 +1,83 @@
+package streams
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFollowReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.csv")
+	write := func(flag int, data string) {
+		t.Helper()
+		file, err := os.OpenFile(path, flag|os.O_WRONLY|os.O_CREATE, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+	}
+	write(os.O_TRUNC, "Street Name,Price\nAbbey Drive,100\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	f, err := Follow(ctx, path, time.Millisecond, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	expect := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case got := <-lines:
+				if got != w {
+					t.Fatalf("line = %q, want %q", got, w)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no line, want %q", w)
+			}
+		}
+	}
+
+	expect("Street Name,Price", "Abbey Drive,100")
+
+	write(os.O_APPEND, "Temple Gardens,200\n")
+	expect("Temple Gardens,200")
+
+	// truncated and written again with its header
+	write(os.O_TRUNC, "Street Name,Price\nOak Avenue,300\n")
+	expect("Oak Avenue,300")
+
+	// rotated: the old file gets a last line, then a new file takes its path
+	write(os.O_APPEND, "Main Street,400\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	write(os.O_TRUNC, "Street Name,Price\nHigh Street,500\n")
+	expect("Main Street,400", "High Street,500")
+
+	cancel()
+	select {
+	case _, ok := <-lines:
+		if ok {
+			t.Fatal("line after the context was canceled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reader did not end after the context was canceled")
+	}
+}
@@ -3,6 +3,7 @@ package streams
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 
 	iface "propertytreeanalyzer/pkg/api/streams"
@@ -10,14 +11,45 @@ import (
 
 type jsonReader struct {
 	decoder *json.Decoder
+	lines   *lineCounter
+	unread  *unreadTail
+	name    string
+	pos     iface.Position
 }
 
-var _ iface.JsonStream = (*jsonReader)(nil)
+var (
+	_ iface.JsonStream = (*jsonReader)(nil)
+	_ iface.Positioner = (*jsonReader)(nil)
+)
 
+// NewJsonStream creates a new JSON token stream from an io.Reader.
+// Gzip, bzip2, zstd and xz compressed input is detected and decompressed on the fly,
+// the text is transcoded to UTF-8 and a leading byte order mark is dropped.
+// An error detecting the input is returned by ReadJsonToken.
 func NewJsonStream(reader io.Reader) iface.JsonStream {
-	decoder := json.NewDecoder(reader)
+	s, err := NewJsonStreamWithOptions(reader)
+	if err != nil {
+		return failedJsonStream{err: err}
+	}
+	return s
+}
+
+// NewJsonStreamWithOptions is NewJsonStream configured with options, e.g. WithName and WithEncoding
+func NewJsonStreamWithOptions(reader io.Reader, opts ...Option) (iface.JsonStream, error) {
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	text, err := newTextReader(reader, cfg.encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := &lineCounter{reader: text}
+	unread := &unreadTail{reader: lines}
+	decoder := json.NewDecoder(unread)
 	decoder.UseNumber()
-	return &jsonReader{decoder: decoder}
+	return &jsonReader{decoder: decoder, lines: lines, unread: unread, name: cfg.name}, nil
 }
 
 // ReadJsonToken implements JsonStream.
@@ -26,6 +58,69 @@ func (j *jsonReader) ReadJsonToken(ctx context.Context) (json.Token, error) {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
-		return j.decoder.Token()
 	}
+
+	last := j.decoder.InputOffset()
+	j.unread.discard(last)
+	tok, err := j.decoder.Token()
+	offset := j.unread.tokenStart(last)
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case err != nil:
+		offset = j.decoder.InputOffset()
+	}
+	j.pos = j.lines.position(j.name, offset)
+	return tok, positionError(j.pos, err)
+}
+
+// Position implements Positioner. It locates the first byte of the last token.
+func (j *jsonReader) Position() iface.Position {
+	return j.pos
+}
+
+// unreadTail keeps the bytes read by the decoder after the end of its last token,
+// they locate the beginning of the next token
+type unreadTail struct {
+	reader io.Reader
+	buf    []byte
+	// start is the input offset of buf[0]
+	start int64
+}
+
+// Read implements io.Reader.
+func (u *unreadTail) Read(p []byte) (int, error) {
+	n, err := u.reader.Read(p)
+	u.buf = append(u.buf, p[:n]...)
+	return n, err
+}
+
+// discard forgets the bytes before the offset
+func (u *unreadTail) discard(offset int64) {
+	u.buf = u.buf[offset-u.start:]
+	u.start = offset
+}
+
+// tokenStart returns the offset of the first token byte from the offset on,
+// skipping white space and the separators the decoder consumes with the token
+func (u *unreadTail) tokenStart(offset int64) int64 {
+	for i, b := range u.buf[offset-u.start:] {
+		switch b {
+		case ' ', '\t', '\r', '\n', ',', ':':
+		default:
+			return offset + int64(i)
+		}
+	}
+	return offset
+}
+
+// failedJsonStream is a JSON stream which could not be opened, every read returns the error
+type failedJsonStream struct {
+	err error
+}
+
+// ReadJsonToken implements JsonStream.
+func (f failedJsonStream) ReadJsonToken(context.Context) (json.Token, error) {
+	return nil, f.err
 }
@@ -0,0 +1,602 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+// mappedCtxInterval is the number of records read between two context checks
+const mappedCtxInterval = 1024
+
+var (
+	errMmapUnsupported    = errors.New("memory mapping is not supported")
+	errMappedCompressed   = errors.New("mapped CSV reading needs uncompressed input")
+	errMappedEncoding     = errors.New("mapped CSV reading needs an ASCII compatible encoding")
+	errMappedDialect      = errors.New("mapped CSV reading needs ASCII delimiter and comment characters")
+	errMappedFieldMissing = errors.New("field not found in CSV header")
+	// errNeedMore is returned by the tokenizer when a record may continue past the buffered data
+	errNeedMore = errors.New("record continues past the buffered data")
+)
+
+// mappedCsvReader tokenizes a CSV file in place. Fields are slices of the memory-mapped file,
+// or of a reused buffer window when the input cannot be mapped; only fields with escaped quotes,
+// CRLF line breaks inside quotes or bytes needing transcoding are copied, into a reused scratch buffer.
+type mappedCsvReader struct {
+	source io.ReaderAt
+	size   int64
+	// data is the whole mapped file, or the buffer window holding the input from the offset base
+	data  []byte
+	buf   []byte
+	base  int64
+	pos   int
+	final bool
+	unmap func() error
+
+	delimiter  byte
+	quote      byte
+	comment    byte
+	lazyQuotes bool
+	trimSpace  bool
+	// decoder transcodes fields to UTF-8, decodeValid also applies it to valid UTF-8 with non-ASCII bytes
+	decoder     transform.Transformer
+	decodeValid bool
+
+	header []string
+	// columns holds the file column of every returned field and last the largest of them,
+	// nil returns all columns
+	columns []int
+	last    int
+	// values are the fields of the record up to the last projected column, fields the returned ones
+	values  [][]byte
+	fields  [][]byte
+	scratch []byte
+	// count is the number of fields every record must have, zero until the first record
+	count int
+
+	// name, recordLine and offset describe the source and the last record for diagnostics,
+	// line is the number of line feeds consumed
+	name       string
+	line       int
+	recordLine int
+	offset     int64
+	records    int
+}
+
+var (
+	_ iface.CsvStream       = (*mappedCsvReader)(nil)
+	_ iface.RawCsvStream    = (*mappedCsvReader)(nil)
+	_ iface.Positioner      = (*mappedCsvReader)(nil)
+	_ iface.FieldPositioner = (*mappedCsvReader)(nil)
+	_ io.Closer             = (*mappedCsvReader)(nil)
+)
+
+// NewMappedCsvStream creates a CSV stream which tokenizes the file in place instead of copying
+// every record. Regular files are memory-mapped, other readers are read through a reused buffer of
+// WithChunkSize bytes which grows to hold the longest record. ReadRawRecord returns the fields as
+// slices of the mapping or buffer without allocating; ReadCsvRecord copies them into strings.
+//
+// WithFields projects the records onto the named header columns: fields after the last of them
+// are only counted, so reading a wide file for two columns skips most of every line. The header
+// of the stream is then the projected column names. WithOptionalFields adds the named columns
+// the header has.
+//
+// The input must be uncompressed and in UTF-8 or an ASCII compatible encoding; fields are
+// transcoded only when they contain bytes outside ASCII. The delimiter and comment characters
+// must be ASCII. The stream implements io.Closer to unmap the file, which must not be truncated
+// while it is mapped.
+func NewMappedCsvStream(reader io.ReaderAt, size int64, opts ...Option) (iface.CsvStream, error) {
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	head := bufio.NewReaderSize(io.NewSectionReader(reader, 0, size), sniffSize)
+	compression, err := DetectCompression(head)
+	if err != nil {
+		return nil, err
+	}
+	if compression != CompressionNone {
+		return nil, fmt.Errorf("%w: %s detected", errMappedCompressed, compression)
+	}
+
+	m := &mappedCsvReader{source: reader, size: size, name: cfg.name}
+	switch enc := cfg.encoding; {
+	case enc == nil:
+		if prefix, _ := head.Peek(len(utf16LEBOM)); bytes.Equal(prefix, utf16LEBOM) || bytes.Equal(prefix, utf16BEBOM) {
+			return nil, fmt.Errorf("%w: UTF-16 byte order mark found", errMappedEncoding)
+		}
+		m.decoder = utf8WithFallback.NewDecoder()
+	case !asciiCompatible(enc):
+		return nil, errMappedEncoding
+	default:
+		m.decoder, m.decodeValid = enc.NewDecoder(), enc != unicode.UTF8
+	}
+	var start int
+	if prefix, _ := head.Peek(len(utf8BOM)); bytes.Equal(prefix, utf8BOM) {
+		start = len(utf8BOM)
+		head.Discard(len(utf8BOM))
+	}
+
+	if cfg.sniff {
+		if cfg.dialect, err = sniffDialect(head, cfg.dialect); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.dialect.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.dialect.Delimiter >= utf8.RuneSelf || cfg.dialect.Comment >= utf8.RuneSelf {
+		return nil, errMappedDialect
+	}
+	m.delimiter, m.quote, m.comment = byte(cfg.dialect.Delimiter), byte(cfg.dialect.Quote), byte(cfg.dialect.Comment)
+	m.lazyQuotes, m.trimSpace = cfg.dialect.LazyQuotes, cfg.dialect.TrimLeadingSpace
+
+	if err := m.mapInput(cfg.chunkSize, start); err != nil {
+		return nil, err
+	}
+	if err := m.readHeader(cfg); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// mapInput maps a regular file or sets up the buffer window, reading starts after skip bytes
+func (m *mappedCsvReader) mapInput(chunkSize, skip int) error {
+	file, ok := m.source.(*os.File)
+	if spooled, isSpooled := m.source.(*SpooledReader); isSpooled {
+		file, ok = spooled.reader.(*os.File)
+	}
+	if ok {
+		if data, unmap, err := mmapFile(file, m.size); err == nil {
+			m.data, m.unmap, m.final, m.pos = data, unmap, true, skip
+			return nil
+		}
+	}
+
+	m.buf = make([]byte, min(int64(chunkSize), m.size))
+	m.data, m.base, m.final = m.buf[:0], int64(skip), int64(skip) == m.size
+	if m.final {
+		return nil
+	}
+	return m.fill()
+}
+
+// readHeader reads the header row or takes the configured header and sets up the projection
+func (m *mappedCsvReader) readHeader(cfg *streamConfig) error {
+	header := cfg.header
+	if header == nil {
+		fields, err := m.next(context.Background())
+		if err != nil {
+			return err
+		}
+		header = make([]string, len(fields))
+		for i, f := range fields {
+			header[i] = string(f)
+		}
+		if cfg.syntheticHeader {
+			// the first row is data, it is read again
+			header = syntheticHeader(len(header))
+			m.pos, m.line = int(m.offset-m.base), m.recordLine-1
+		}
+	}
+	m.header = header
+	if len(cfg.fields) == 0 {
+		return nil
+	}
+
+	m.columns, m.header = make([]int, 0, len(cfg.fields)+len(cfg.optional)), make([]string, 0, len(cfg.fields)+len(cfg.optional))
+	for i, field := range slices.Concat(cfg.fields, cfg.optional) {
+		j := slices.IndexFunc(header, func(col string) bool {
+			return strings.EqualFold(strings.TrimSpace(col), strings.TrimSpace(field))
+		})
+		if j < 0 {
+			if i < len(cfg.fields) {
+				return fmt.Errorf("%w: %q", errMappedFieldMissing, field)
+			}
+			continue
+		}
+		m.columns, m.header = append(m.columns, j), append(m.header, header[j])
+		m.last = max(m.last, j)
+	}
+	return nil
+}
+
+// fill moves the unread data to the front of the buffer and reads more input after it.
+// The buffer grows when it is full of a single unfinished record.
+func (m *mappedCsvReader) fill() error {
+	if m.final {
+		return io.ErrUnexpectedEOF
+	}
+	rest := len(m.data) - m.pos
+	if rest == len(m.buf) {
+		m.buf = make([]byte, max(2*len(m.buf), 4096))
+	}
+	copy(m.buf, m.data[m.pos:])
+	m.base += int64(m.pos)
+	m.pos = 0
+
+	from := m.base + int64(rest)
+	want := int(min(int64(len(m.buf)-rest), m.size-from))
+	n, err := m.source.ReadAt(m.buf[rest:rest+want], from)
+	m.data = m.buf[:rest+n]
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if n < want {
+		return io.ErrUnexpectedEOF
+	}
+	m.final = from+int64(n) == m.size
+	return nil
+}
+
+// next reads the next record and returns its fields, or the projected ones
+func (m *mappedCsvReader) next(ctx context.Context) ([][]byte, error) {
+	if m.records%mappedCtxInterval == 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	m.records++
+
+	for {
+		rest := m.data[m.pos:]
+		if len(rest) == 0 || (len(rest) == 1 && rest[0] == '\r') {
+			if m.final {
+				// a carriage return before the end of input is dropped like encoding/csv does
+				m.pos = len(m.data)
+				return nil, io.EOF
+			}
+			if err := m.fill(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// empty and comment lines are skipped
+		switch {
+		case rest[0] == '\n':
+			m.pos++
+			m.line++
+			continue
+		case rest[0] == '\r' && rest[1] == '\n':
+			m.pos += 2
+			m.line++
+			continue
+		case m.comment != 0 && rest[0] == m.comment:
+			i := bytes.IndexByte(rest, '\n')
+			if i < 0 && !m.final {
+				if err := m.fill(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if i < 0 {
+				m.pos = len(m.data)
+				continue
+			}
+			m.pos += i + 1
+			m.line++
+			continue
+		}
+
+		n, lines, count, err := m.parseRecord(rest)
+		if errors.Is(err, errNeedMore) {
+			if err := m.fill(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		m.recordLine, m.offset = m.line+1, m.base+int64(m.pos)
+		if err != nil {
+			// the line the record starts on is skipped, so reading can go on after the error
+			m.pos += min(lineEnd(rest, 0)+1, len(rest))
+			m.line++
+			return nil, m.parseError(err)
+		}
+		m.pos += n
+		m.line += lines + 1
+
+		fields, err := m.output()
+		if err != nil {
+			return nil, positionError(m.Position(), err)
+		}
+		if m.count == 0 {
+			m.count = count
+		} else if count != m.count {
+			return fields, m.parseError(&csv.ParseError{StartLine: m.recordLine, Line: m.recordLine, Column: 1, Err: csv.ErrFieldCount})
+		}
+		return fields, nil
+	}
+}
+
+// parseRecord splits the record at the start of data into values. It returns the number of bytes
+// and line feeds consumed and the number of fields, or errNeedMore when the record may continue
+// past the data. Values after the last projected column are not kept.
+func (m *mappedCsvReader) parseRecord(data []byte) (n, lines, count int, err error) {
+	m.values, m.scratch = m.values[:0], m.scratch[:0]
+	pos, end := 0, lineEnd(data, 0)
+	for {
+		if m.columns != nil && count > m.last && bytes.IndexByte(data[pos:end], m.quote) < 0 {
+			// no quotes in the rest of the line, its fields are only counted
+			count += 1 + bytes.Count(data[pos:end], []byte{m.delimiter})
+			pos = end
+			break
+		}
+		if m.trimSpace {
+			for pos < end && (data[pos] == ' ' || data[pos] == '\t') {
+				pos++
+			}
+		}
+
+		var value []byte
+		if pos < len(data) && data[pos] == m.quote {
+			if value, pos, lines, err = m.quotedField(data, pos, lines); err != nil {
+				return 0, 0, 0, err
+			}
+			end = lineEnd(data, pos)
+		} else {
+			stop := end
+			if i := bytes.IndexByte(data[pos:end], m.delimiter); i >= 0 {
+				stop = pos + i
+			}
+			value = data[pos:stop]
+			if stop == end {
+				if end == len(data) && !m.final {
+					return 0, 0, 0, errNeedMore
+				}
+				value = bytes.TrimSuffix(value, []byte{'\r'})
+			}
+			if !m.lazyQuotes && bytes.IndexByte(value, m.quote) >= 0 {
+				return 0, 0, 0, m.syntaxError(lines, column(data, pos+bytes.IndexByte(value, m.quote)), csv.ErrBareQuote)
+			}
+			pos = stop
+		}
+		if m.columns == nil || count <= m.last {
+			m.values = append(m.values, value)
+		}
+		count++
+		if pos < len(data) && data[pos] == m.delimiter {
+			pos++
+			continue
+		}
+		break
+	}
+
+	// pos is at the line feed ending the record or at the end of data
+	if pos < len(data) {
+		pos++
+	} else if !m.final {
+		return 0, 0, 0, errNeedMore
+	}
+	return pos, lines, count, nil
+}
+
+// quotedField reads the quoted field starting at data[pos]. It returns the unquoted value,
+// the position after the closing quote and the line feeds counted so far.
+func (m *mappedCsvReader) quotedField(data []byte, pos, lines int) ([]byte, int, int, error) {
+	start, i := pos+1, pos+1
+	escaped := false
+	for {
+		j := bytes.IndexByte(data[i:], m.quote)
+		if j < 0 {
+			if !m.final {
+				return nil, 0, 0, errNeedMore
+			}
+			if !m.lazyQuotes {
+				// encoding/csv reports the end of the last line
+				end := len(data)
+				if data[end-1] == '\n' {
+					end--
+				}
+				column := len(data) - bytes.LastIndexByte(data[:end], '\n')
+				return nil, 0, 0, m.syntaxError(lines+bytes.Count(data[start:end], []byte{'\n'}), column, csv.ErrQuote)
+			}
+			// with lazy quotes an unterminated field runs to the end of input
+			return m.unquote(data[start:], escaped), len(data), lines + bytes.Count(data[start:], []byte{'\n'}), nil
+		}
+
+		q := i + j
+		next, ok, more := m.closingQuote(data, q)
+		if more {
+			return nil, 0, 0, errNeedMore
+		}
+		if ok {
+			raw := data[start:q]
+			return m.unquote(raw, escaped), next, lines + bytes.Count(raw, []byte{'\n'}), nil
+		}
+		switch {
+		case q+1 < len(data) && data[q+1] == m.quote:
+			escaped, i = true, q+2
+		case m.lazyQuotes:
+			// a stray quote is kept as it is
+			escaped, i = true, q+1
+		default:
+			return nil, 0, 0, m.syntaxError(lines+bytes.Count(data[start:q], []byte{'\n'}), column(data, q), csv.ErrQuote)
+		}
+	}
+}
+
+// closingQuote reports whether the quote at q ends the field, that is whether a delimiter, a line break
+// or the end of input follows it, and returns the position after it without a carriage return.
+// more asks for more data to decide.
+func (m *mappedCsvReader) closingQuote(data []byte, q int) (next int, ok, more bool) {
+	next = q + 1
+	if next == len(data) {
+		return next, m.final, !m.final
+	}
+	switch data[next] {
+	case m.delimiter, '\n':
+		return next, true, false
+	case '\r':
+		if next+1 == len(data) {
+			return next + 1, m.final, !m.final
+		}
+		return next + 1, data[next+1] == '\n', false
+	}
+	return next, false, false
+}
+
+// unquote returns the raw content of a quoted field with doubled quotes and CRLF line breaks
+// reduced, copying it into the scratch buffer only when something has to be changed
+func (m *mappedCsvReader) unquote(raw []byte, escaped bool) []byte {
+	if !escaped && bytes.IndexByte(raw, '\r') < 0 {
+		return raw
+	}
+	start := len(m.scratch)
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c == m.quote && i+1 < len(raw) && raw[i+1] == m.quote {
+			i++
+		} else if c == '\r' && i+1 < len(raw) && raw[i+1] == '\n' {
+			continue
+		}
+		m.scratch = append(m.scratch, c)
+	}
+	// earlier fields keep pointing into the old array when append reallocates
+	return m.scratch[start:len(m.scratch):len(m.scratch)]
+}
+
+// output projects the values onto the returned fields and transcodes the fields which need it
+func (m *mappedCsvReader) output() ([][]byte, error) {
+	fields := m.values
+	if m.columns != nil {
+		m.fields = m.fields[:0]
+		for _, c := range m.columns {
+			var value []byte
+			if c < len(m.values) {
+				value = m.values[c]
+			}
+			m.fields = append(m.fields, value)
+		}
+		fields = m.fields
+	}
+	for i, field := range fields {
+		if utf8.Valid(field) && (!m.decodeValid || asciiBytes(field)) {
+			continue
+		}
+		start := len(m.scratch)
+		decoded, _, err := transform.Append(m.decoder, m.scratch, field)
+		if err != nil {
+			return nil, err
+		}
+		m.scratch = decoded
+		fields[i] = decoded[start:len(decoded):len(decoded)]
+	}
+	return fields, nil
+}
+
+// syntaxError reports a malformed record, lines is the number of line feeds of the record
+// before the error and column its 1-based byte column
+func (m *mappedCsvReader) syntaxError(lines, column int, err error) error {
+	return &csv.ParseError{StartLine: m.line + 1, Line: m.line + 1 + lines, Column: column, Err: err}
+}
+
+// column returns the 1-based byte column of data[pos] in its line
+func column(data []byte, pos int) int {
+	return pos - bytes.LastIndexByte(data[:pos], '\n')
+}
+
+// parseError prefixes the error with the position of the record
+func (m *mappedCsvReader) parseError(err error) error {
+	pos := iface.Position{Source: m.name, Line: m.recordLine, Offset: m.offset}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		pos.Line = parseErr.Line
+	}
+	return positionError(pos, err)
+}
+
+// ReadRawRecord implements RawCsvStream.
+func (m *mappedCsvReader) ReadRawRecord(ctx context.Context) ([][]byte, error) {
+	if m == nil || m.data == nil {
+		return nil, io.EOF
+	}
+	return m.next(ctx)
+}
+
+// ReadCsvRecord implements CsvStream.
+func (m *mappedCsvReader) ReadCsvRecord(ctx context.Context) ([]string, error) {
+	fields, err := m.ReadRawRecord(ctx)
+	if fields == nil {
+		return nil, err
+	}
+	// one string holds all fields like encoding/csv does, the record slices it
+	var size int
+	for _, f := range fields {
+		size += len(f)
+	}
+	var b strings.Builder
+	b.Grow(size)
+	for _, f := range fields {
+		b.Write(f)
+	}
+	line := b.String()
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i], line = line[:len(f)], line[len(f):]
+	}
+	return record, err
+}
+
+// Position implements Positioner.
+func (m *mappedCsvReader) Position() iface.Position {
+	return m.FieldPosition(0)
+}
+
+// FieldPosition implements FieldPositioner. The column is the 1-based field number in the file.
+func (m *mappedCsvReader) FieldPosition(field int) iface.Position {
+	if m.columns != nil && field >= 0 && field < len(m.columns) {
+		field = m.columns[field]
+	}
+	return iface.Position{Source: m.name, Line: m.recordLine, Column: field + 1, Offset: m.offset}
+}
+
+// GetHeader implements CsvStream.
+func (m *mappedCsvReader) GetHeader() []string {
+	if m == nil {
+		return nil
+	}
+	return m.header
+}
+
+// Close implements io.Closer, it unmaps the file. The fields returned last become invalid.
+func (m *mappedCsvReader) Close() error {
+	m.data, m.buf, m.final = nil, nil, true
+	if m.unmap == nil {
+		return nil
+	}
+	unmap := m.unmap
+	m.unmap = nil
+	return unmap()
+}
+
+// lineEnd returns the position of the first line feed in data from pos, or the length of data
+func lineEnd(data []byte, pos int) int {
+	if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+		return pos + i
+	}
+	return len(data)
+}
+
+func asciiBytes(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
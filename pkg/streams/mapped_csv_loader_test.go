@@ -0,0 +1,270 @@
+package streams
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"propertytreeanalyzer/internal/registertest"
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+var benchRows = flag.Int("bench-rows", registertest.DefaultRows, "rows of the synthetic register file read by BenchmarkMappedCsvStream")
+
+// mappedTestFile writes the data to a file which is memory-mapped by the stream
+func mappedTestFile(t testing.TB, data string) (*os.File, int64) {
+	t.Helper()
+	return registertest.File(t, "prices.csv", func(w io.Writer) error {
+		_, err := io.WriteString(w, data)
+		return err
+	})
+}
+
+func TestMappedCsvStream(t *testing.T) {
+	data := "\xef\xbb\xbf" + parallelTestCsv(500) + "caf\xe9 street,1,  \"a\"\"b\"\r\n\r\nlast street,2,no line feed"
+	sequential, err := NewCsvStream(strings.NewReader(data), WithComment('#'), WithLazyQuotes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, wantLines := drainStream(t, sequential)
+
+	file, size := mappedTestFile(t, data)
+	sources := map[string]io.ReaderAt{"mapped": file}
+	for _, chunkSize := range []int{1, 7, 64, 1 << 20} {
+		sources[fmt.Sprintf("chunk %d", chunkSize)] = strings.NewReader(data)
+	}
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			var chunkSize int
+			fmt.Sscanf(name, "chunk %d", &chunkSize)
+			opts := []Option{WithComment('#'), WithLazyQuotes()}
+			if chunkSize > 0 {
+				opts = append(opts, WithChunkSize(chunkSize))
+			}
+			s, err := NewMappedCsvStream(source, size, opts...)
+			if err != nil {
+				t.Fatalf("NewMappedCsvStream() error = %v", err)
+			}
+			defer s.(io.Closer).Close()
+			if got := s.GetHeader(); !reflect.DeepEqual(got, sequential.GetHeader()) {
+				t.Errorf("GetHeader() = %q, want %q", got, sequential.GetHeader())
+			}
+			got, gotLines := drainStream(t, s)
+			if !reflect.DeepEqual(got, want) {
+				for i := range min(len(got), len(want)) {
+					if !slices.Equal(got[i], want[i]) {
+						t.Fatalf("record %d = %q, want %q", i, got[i], want[i])
+					}
+				}
+				t.Fatalf("got %d records, want %d", len(got), len(want))
+			}
+			if !reflect.DeepEqual(gotLines, wantLines) {
+				t.Errorf("record lines = %v, want %v", gotLines[:8], wantLines[:8])
+			}
+		})
+	}
+}
+
+func TestMappedCsvStreamFields(t *testing.T) {
+	data := "Date,Street Name,Notes,Price,County\n" +
+		"2024-01-05,Abbey Drive,\"quiet, leafy\",\"€100,000\",Dublin\n" +
+		"2024-02-11,Temple Gardens,\"has \"\"quotes\"\"\",200000,\"Dublin\nCity\"\n"
+	file, size := mappedTestFile(t, data)
+	s, err := NewMappedCsvStream(file, size, WithFields("price", "Street Name"), WithName("prices.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.(io.Closer).Close()
+	if header := s.GetHeader(); !slices.Equal(header, []string{"Price", "Street Name"}) {
+		t.Errorf("GetHeader() = %q", header)
+	}
+
+	raw := s.(iface.RawCsvStream)
+	want := []struct {
+		record []string
+		pos    string
+	}{
+		{[]string{"€100,000", "Abbey Drive"}, "prices.csv:2:4"},
+		{[]string{"200000", "Temple Gardens"}, "prices.csv:3:4"},
+	}
+	for _, w := range want {
+		fields, err := raw.ReadRawRecord(context.Background())
+		if err != nil {
+			t.Fatalf("ReadRawRecord() error = %v", err)
+		}
+		var record []string
+		for _, f := range fields {
+			record = append(record, string(f))
+		}
+		if !slices.Equal(record, w.record) {
+			t.Errorf("ReadRawRecord() = %q, want %q", record, w.record)
+		}
+		if pos := s.(iface.FieldPositioner).FieldPosition(0).String(); pos != w.pos {
+			t.Errorf("FieldPosition(0) = %s, want %s", pos, w.pos)
+		}
+	}
+	if _, err := raw.ReadRawRecord(context.Background()); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadRawRecord() at the end error = %v, want EOF", err)
+	}
+
+	if _, err := NewMappedCsvStream(file, size, WithFields("Postcode")); !errors.Is(err, errMappedFieldMissing) {
+		t.Errorf("missing field error = %v, want %v", err, errMappedFieldMissing)
+	}
+	optional, err := NewMappedCsvStream(file, size, WithFields("Street Name"), WithOptionalFields("Postcode", "price"))
+	if err != nil {
+		t.Fatalf("optional fields error = %v", err)
+	}
+	defer optional.(io.Closer).Close()
+	if header := optional.GetHeader(); !slices.Equal(header, []string{"Street Name", "Price"}) {
+		t.Errorf("GetHeader() with optional fields = %q", header)
+	}
+}
+
+func TestMappedCsvStreamAllocations(t *testing.T) {
+	data := "Street Name,Price\n" + strings.Repeat("Abbey Drive,100000\n\"Temple \"\"Gardens\"\"\",200000\n", 1000)
+	file, size := mappedTestFile(t, data)
+	s, err := NewMappedCsvStream(file, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.(io.Closer).Close()
+	raw := s.(iface.RawCsvStream)
+	ctx := context.Background()
+	// the first records size the reused buffers
+	raw.ReadRawRecord(ctx)
+	raw.ReadRawRecord(ctx)
+	if allocs := testing.AllocsPerRun(500, func() {
+		if _, err := raw.ReadRawRecord(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}); allocs != 0 {
+		t.Errorf("ReadRawRecord allocates %v times per record", allocs)
+	}
+}
+
+func TestMappedCsvStreamErrors(t *testing.T) {
+	ctx := context.Background()
+	for name, tc := range map[string]struct {
+		data string
+		want error
+		msg  string
+	}{
+		"quote":        {"street,price\na,1\nb,2\nc,\"3\nd,4\n", csv.ErrQuote, `prices.csv:5: record on line 4; parse error on line 5, column 5: extraneous or missing " in quoted-field`},
+		"bare quote":   {"street,price\na,1\nb,2\nc,3\"\n", csv.ErrBareQuote, `prices.csv:4: parse error on line 4, column 4: bare " in non-quoted-field`},
+		"field count":  {"street,price\na,1\nb,2\nc,3,4\n", csv.ErrFieldCount, `prices.csv:4: record on line 4: wrong number of fields`},
+		"stray quote":  {"street,price\na,1\nb,2\n\"c\"x,3\n", csv.ErrQuote, `prices.csv:4: parse error on line 4, column 3: extraneous or missing " in quoted-field`},
+		"no line feed": {"street,price\na,1\nb,2\n\"c", csv.ErrQuote, `prices.csv:4: parse error on line 4, column 3: extraneous or missing " in quoted-field`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sequential, err := NewCsvStream(strings.NewReader(tc.data), WithName("prices.csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewMappedCsvStream(strings.NewReader(tc.data), int64(len(tc.data)), WithName("prices.csv"), WithChunkSize(4))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, stream := range []iface.CsvStream{sequential, s} {
+				for range 2 {
+					if _, err := stream.ReadCsvRecord(ctx); err != nil {
+						t.Fatal(err)
+					}
+				}
+				_, err = stream.ReadCsvRecord(ctx)
+				if !errors.Is(err, tc.want) || err.Error() != tc.msg {
+					t.Errorf("%T.ReadCsvRecord() error = %v, want %s", stream, err, tc.msg)
+				}
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("street,price\na,1\n"))
+	zw.Close()
+	if _, err := NewMappedCsvStream(bytes.NewReader(buf.Bytes()), int64(buf.Len())); !errors.Is(err, errMappedCompressed) {
+		t.Errorf("compressed input error = %v, want %v", err, errMappedCompressed)
+	}
+	if _, err := NewMappedCsvStream(strings.NewReader("a§b\n"), 5, WithDelimiter('§')); !errors.Is(err, errMappedDialect) {
+		t.Errorf("non-ASCII delimiter error = %v, want %v", err, errMappedDialect)
+	}
+	if _, err := NewMappedCsvStream(strings.NewReader(""), 0); !errors.Is(err, io.EOF) {
+		t.Errorf("empty input error = %v, want %v", err, io.EOF)
+	}
+}
+
+// BenchmarkMappedCsvStream compares the mapped tokenizer with encoding/csv on a synthetic register
+// file of -bench-rows rows, by default ten times the size of the full register export
+func BenchmarkMappedCsvStream(b *testing.B) {
+	file, size := registertest.RegisterFile(b, *benchRows)
+	ctx := context.Background()
+	drainRaw := func(b *testing.B, s iface.CsvStream) {
+		raw := s.(iface.RawCsvStream)
+		for {
+			if _, err := raw.ReadRawRecord(ctx); err != nil {
+				if errors.Is(err, io.EOF) {
+					return
+				}
+				b.Fatal(err)
+			}
+		}
+	}
+	drainStrings := func(b *testing.B, s iface.CsvStream) {
+		for {
+			if _, err := s.ReadCsvRecord(ctx); err != nil {
+				if errors.Is(err, io.EOF) {
+					return
+				}
+				b.Fatal(err)
+			}
+		}
+	}
+
+	cases := []struct {
+		name  string
+		open  func() (iface.CsvStream, error)
+		drain func(*testing.B, iface.CsvStream)
+	}{
+		{"encoding-csv", func() (iface.CsvStream, error) {
+			return NewCsvStream(io.NewSectionReader(file, 0, size))
+		}, drainStrings},
+		{"mapped-strings", func() (iface.CsvStream, error) {
+			return NewMappedCsvStream(file, size)
+		}, drainStrings},
+		{"mapped-raw", func() (iface.CsvStream, error) {
+			return NewMappedCsvStream(file, size)
+		}, drainRaw},
+		{"mapped-raw-street-price", func() (iface.CsvStream, error) {
+			return NewMappedCsvStream(file, size, WithFields("Street Name", "Price (€)"))
+		}, drainRaw},
+		{"buffered-raw-street-price", func() (iface.CsvStream, error) {
+			return NewMappedCsvStream(io.NewSectionReader(file, 0, size), size, WithFields("Street Name", "Price (€)"))
+		}, drainRaw},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for b.Loop() {
+				s, err := c.open()
+				if err != nil {
+					b.Fatal(err)
+				}
+				c.drain(b, s)
+				if closer, ok := s.(io.Closer); ok {
+					closer.Close()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package streams
+
+import "os"
+
+// mmapFile is not available on this platform, the mapped CSV stream reads into a buffer instead
+func mmapFile(_ *os.File, _ int64) ([]byte, func() error, error) {
+	return nil, nil, errMmapUnsupported
+}
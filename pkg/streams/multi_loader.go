@@ -0,0 +1,275 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+var (
+	errNoSources          = errors.New("multi stream needs at least one source")
+	errNilSourceOpener    = errors.New("multi stream source has no opener")
+	errMultiColumnMissing = errors.New("required column not found in header")
+)
+
+// CsvSource is one input of a multi-file stream. Open is called when the stream reaches the source;
+// it returns the stream and the closer releasing its resources, or an error after cleaning up.
+// The closer may be nil.
+type CsvSource struct {
+	Name string
+	Open func() (iface.CsvStream, io.Closer, error)
+}
+
+// multiReader reads the records of several CSV streams one after another
+type multiReader struct {
+	sources  []CsvSource
+	header   []string
+	required []string
+	// next is the index of the source opened after the current one
+	next    int
+	current iface.CsvStream
+	closer  io.Closer
+	name    string
+	// remap holds the current stream field for every header column, -1 when the stream lacks it.
+	// It is nil when the stream header matches the first one.
+	remap []int
+	// raw and rawRemapped are the reused records of ReadRawRecord
+	raw         [][]byte
+	rawRemapped [][]byte
+}
+
+// rawMultiReader is the multi stream whose first source is a RawCsvStream, it passes raw records on
+type rawMultiReader struct {
+	*multiReader
+}
+
+var (
+	_ iface.CsvStream       = (*multiReader)(nil)
+	_ iface.Positioner      = (*multiReader)(nil)
+	_ iface.FieldPositioner = (*multiReader)(nil)
+	_ io.Closer             = (*multiReader)(nil)
+	_ iface.RawCsvStream    = rawMultiReader{}
+)
+
+// NewMultiCsvStream creates a CSV stream reading the sources in order, e.g. one register file per year.
+// The header of the first source is the header of the stream. Later sources may order their columns
+// differently, records are remapped by column name (case-insensitively); columns missing from a source
+// are empty and extra columns are dropped. WithRequired lists the columns every source must have.
+// Sources are opened one at a time, empty ones are skipped. The stream implements io.Closer
+// to release the source being read, and RawCsvStream when the first source does.
+func NewMultiCsvStream(sources []CsvSource, opts ...Option) (iface.CsvStream, error) {
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, errNoSources
+	}
+	for _, source := range sources {
+		if source.Open == nil {
+			return nil, fmt.Errorf("%w: %q", errNilSourceOpener, source.Name)
+		}
+	}
+
+	m := &multiReader{sources: sources, required: cfg.required}
+	if err := m.openNext(); err != nil {
+		return nil, err
+	}
+	if _, ok := m.current.(iface.RawCsvStream); ok {
+		return rawMultiReader{m}, nil
+	}
+	return m, nil
+}
+
+// openNext closes the current source and opens the next non-empty one.
+// The first opened source sets the header.
+func (m *multiReader) openNext() error {
+	if err := m.Close(); err != nil {
+		return err
+	}
+	for m.next < len(m.sources) {
+		source := m.sources[m.next]
+		m.next++
+
+		stream, closer, err := source.Open()
+		if errors.Is(err, io.EOF) {
+			// an empty input has no header either
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", source.Name, err)
+		}
+		m.current, m.closer, m.name = stream, closer, source.Name
+		if err := m.reconcile(stream.GetHeader()); err != nil {
+			m.Close()
+			return fmt.Errorf("%s: %w", source.Name, err)
+		}
+		return nil
+	}
+	return io.EOF
+}
+
+// reconcile maps the header of the current source onto the stream header
+func (m *multiReader) reconcile(header []string) error {
+	columns := make(map[string]int, len(header))
+	for i, col := range header {
+		key := columnKey(col)
+		if _, ok := columns[key]; !ok {
+			columns[key] = i
+		}
+	}
+	for _, col := range m.required {
+		if _, ok := columns[columnKey(col)]; !ok {
+			return fmt.Errorf("%w: %q", errMultiColumnMissing, col)
+		}
+	}
+
+	if m.header == nil {
+		m.header = header
+	}
+	m.remap = make([]int, len(m.header))
+	same := len(header) == len(m.header)
+	for i, col := range m.header {
+		j, ok := columns[columnKey(col)]
+		if !ok {
+			j = -1
+		}
+		m.remap[i] = j
+		same = same && j == i
+	}
+	if same {
+		m.remap = nil
+	}
+	return nil
+}
+
+func columnKey(col string) string {
+	return strings.ToLower(strings.TrimSpace(col))
+}
+
+// ReadCsvRecord implements CsvStream.
+func (m *multiReader) ReadCsvRecord(ctx context.Context) ([]string, error) {
+	if m == nil {
+		return nil, io.EOF
+	}
+	for m.current != nil {
+		record, err := m.current.ReadCsvRecord(ctx)
+		if errors.Is(err, io.EOF) {
+			if err := m.openNext(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// a record read with an error, e.g. with a wrong number of fields, is passed on with it
+		if record == nil || m.remap == nil {
+			return record, err
+		}
+		remapped := make([]string, len(m.remap))
+		for i, j := range m.remap {
+			if j >= 0 && j < len(record) {
+				remapped[i] = record[j]
+			}
+		}
+		return remapped, err
+	}
+	return nil, io.EOF
+}
+
+// ReadRawRecord implements RawCsvStream. Records of later sources which are not RawCsvStreams
+// are read with ReadCsvRecord and converted.
+func (r rawMultiReader) ReadRawRecord(ctx context.Context) ([][]byte, error) {
+	m := r.multiReader
+	for m.current != nil {
+		var fields [][]byte
+		var err error
+		if raw, ok := m.current.(iface.RawCsvStream); ok {
+			fields, err = raw.ReadRawRecord(ctx)
+		} else {
+			var record []string
+			record, err = m.current.ReadCsvRecord(ctx)
+			if record != nil {
+				fields = m.raw[:0]
+				for _, field := range record {
+					fields = append(fields, []byte(field))
+				}
+				m.raw = fields
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			if err := m.openNext(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if fields == nil || m.remap == nil {
+			return fields, err
+		}
+		remapped := m.rawRemapped[:0]
+		for _, j := range m.remap {
+			var field []byte
+			if j >= 0 && j < len(fields) {
+				field = fields[j]
+			}
+			remapped = append(remapped, field)
+		}
+		m.rawRemapped = remapped
+		return remapped, err
+	}
+	return nil, io.EOF
+}
+
+// GetHeader implements CsvStream.
+func (m *multiReader) GetHeader() []string {
+	if m == nil {
+		return nil
+	}
+	return m.header
+}
+
+// Position implements Positioner. The source defaults to the name of the current source.
+func (m *multiReader) Position() iface.Position {
+	var pos iface.Position
+	if positioner, ok := m.current.(iface.Positioner); ok {
+		pos = positioner.Position()
+	}
+	return m.withSource(pos)
+}
+
+// FieldPosition implements FieldPositioner. The field is a header column, it is located in the current source.
+func (m *multiReader) FieldPosition(field int) iface.Position {
+	if m.remap != nil && field >= 0 && field < len(m.remap) {
+		field = m.remap[field]
+		if field < 0 {
+			return m.Position()
+		}
+	}
+	var pos iface.Position
+	if positioner, ok := m.current.(iface.FieldPositioner); ok {
+		pos = positioner.FieldPosition(field)
+	}
+	return m.withSource(pos)
+}
+
+func (m *multiReader) withSource(pos iface.Position) iface.Position {
+	if pos.Source == "" {
+		pos.Source = m.name
+	}
+	return pos
+}
+
+// Close implements io.Closer, it releases the source being read
+func (m *multiReader) Close() error {
+	current, closer := m.current, m.closer
+	m.current, m.closer = nil, nil
+	if stream, ok := current.(io.Closer); ok {
+		stream.Close()
+	}
+	if closer == nil {
+		return nil
+	}
+	return closer.Close()
+}
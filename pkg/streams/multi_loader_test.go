@@ -0,0 +1,152 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+// trackingCloser records whether the source was released
+type trackingCloser struct{ closed bool }
+
+func (c *trackingCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func csvSource(name, data string, closer *trackingCloser) CsvSource {
+	return CsvSource{Name: name, Open: func() (iface.CsvStream, io.Closer, error) {
+		stream, err := NewCsvStream(strings.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		return stream, closer, nil
+	}}
+}
+
+func TestMultiCsvStream(t *testing.T) {
+	closers := []*trackingCloser{{}, {}, {}, {}}
+	s, err := NewMultiCsvStream([]CsvSource{
+		csvSource("2021.csv", "Street Name,Price,County\nmain,1,Dublin\n", closers[0]),
+		csvSource("2022.csv", "price,street name\n2,oak\n3,elm\n", closers[1]),
+		csvSource("empty.csv", "", closers[2]),
+		csvSource("2023.csv", "Street Name,Price,County\nash,4,Dublin\n", closers[3]),
+	}, WithRequired("street name", "price"))
+	if err != nil {
+		t.Fatalf("NewMultiCsvStream() error = %v", err)
+	}
+
+	if got, want := s.GetHeader(), []string{"Street Name", "Price", "County"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetHeader() = %v, want %v", got, want)
+	}
+
+	var records [][]string
+	var sources []string
+	for {
+		rec, err := s.ReadCsvRecord(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadCsvRecord() error = %v", err)
+		}
+		records = append(records, rec)
+		sources = append(sources, s.(iface.FieldPositioner).FieldPosition(1).String())
+	}
+
+	want := [][]string{
+		{"main", "1", "Dublin"},
+		{"oak", "2", ""},
+		{"elm", "3", ""},
+		{"ash", "4", "Dublin"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+	wantSources := []string{"2021.csv:2:2", "2022.csv:2:1", "2022.csv:3:1", "2023.csv:2:2"}
+	if !reflect.DeepEqual(sources, wantSources) {
+		t.Errorf("positions = %v, want %v", sources, wantSources)
+	}
+	for i, c := range closers {
+		if i != 2 && !c.closed {
+			t.Errorf("source %d was not closed", i)
+		}
+	}
+}
+
+func TestMultiCsvStreamRequiredColumn(t *testing.T) {
+	s, err := NewMultiCsvStream([]CsvSource{
+		csvSource("2021.csv", "Street Name,Price\nmain,1\n", &trackingCloser{}),
+		csvSource("2022.csv", "Street Name,Amount\noak,2\n", &trackingCloser{}),
+	}, WithRequired("Street Name", "Price"))
+	if err != nil {
+		t.Fatalf("NewMultiCsvStream() error = %v", err)
+	}
+	ctx := context.Background()
+	if _, err := s.ReadCsvRecord(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ReadCsvRecord(ctx)
+	if !errors.Is(err, errMultiColumnMissing) || !strings.HasPrefix(err.Error(), "2022.csv: ") {
+		t.Errorf("ReadCsvRecord() error = %v, want %v for 2022.csv", err, errMultiColumnMissing)
+	}
+
+	if _, err := NewMultiCsvStream(nil); !errors.Is(err, errNoSources) {
+		t.Errorf("NewMultiCsvStream(nil) error = %v, want %v", err, errNoSources)
+	}
+}
+
+func mappedSource(name, data string) CsvSource {
+	return CsvSource{Name: name, Open: func() (iface.CsvStream, io.Closer, error) {
+		stream, err := NewMappedCsvStream(strings.NewReader(data), int64(len(data)))
+		return stream, nil, err
+	}}
+}
+
+func TestMultiCsvStreamRaw(t *testing.T) {
+	s, err := NewMultiCsvStream([]CsvSource{csvSource("2021.csv", "Street Name,Price\nmain,1\n", &trackingCloser{})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(iface.RawCsvStream); ok {
+		t.Error("multi stream of sequential CSV sources is a RawCsvStream")
+	}
+
+	s, err = NewMultiCsvStream([]CsvSource{
+		mappedSource("2021.csv", "Street Name,Price\nmain,1\n"),
+		csvSource("2022.csv", "Price,Street Name\n2,oak\n", &trackingCloser{}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := s.(iface.RawCsvStream)
+	if !ok {
+		t.Fatal("multi stream of a mapped CSV source is not a RawCsvStream")
+	}
+	var records [][]string
+	for {
+		fields, err := raw.ReadRawRecord(context.Background())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadRawRecord() error = %v", err)
+		}
+		record := make([]string, len(fields))
+		for i, field := range fields {
+			record[i] = string(field)
+		}
+		records = append(records, record)
+	}
+	if want := [][]string{{"main", "1"}, {"oak", "2"}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+	if _, ok := s.(io.Closer); !ok {
+		t.Error("raw multi stream is not an io.Closer")
+	}
+}
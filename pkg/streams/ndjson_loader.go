@@ -0,0 +1,271 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+// ndjsonSampleSize is the number of objects inspected to build the header
+// when no explicit field list is given
+const ndjsonSampleSize = 100
+
+var errNdjsonNotObject = errors.New("ndjson record is not a JSON object")
+
+// ndjsonField is a key with its raw value in document order
+type ndjsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// ndjsonObject is a decoded object with the position of its opening brace
+type ndjsonObject struct {
+	fields []ndjsonField
+	pos    iface.Position
+}
+
+// ndjsonReader exposes newline delimited JSON objects as CSV records
+type ndjsonReader struct {
+	decoder *json.Decoder
+	lines   *lineCounter
+	name    string
+	header  []string
+	columns map[string]int
+	// pending holds the objects read while the header was sampled
+	pending []ndjsonObject
+	// pos is the position of the last returned object
+	pos iface.Position
+	// dropped holds the keys first seen after a sampled header, each is logged once
+	dropped map[string]struct{}
+	// started is set once the first top level value is read, array when it opens a
+	// JSON array of objects and done when that array is closed
+	started, array, done bool
+}
+
+var (
+	_ iface.CsvStream       = (*ndjsonReader)(nil)
+	_ iface.Positioner      = (*ndjsonReader)(nil)
+	_ iface.FieldPositioner = (*ndjsonReader)(nil)
+)
+
+// NewNdjsonStream creates a CSV stream from newline delimited JSON (JSON Lines) objects
+// or from a JSON document holding an array of objects.
+// The header is the field list given by WithFields, otherwise the union of keys
+// of the first objects in the order they are first seen. Each object is projected
+// onto the header: missing keys and nulls become empty strings, strings are unquoted,
+// numbers and booleans keep their JSON text and nested values stay compact JSON.
+// Keys which are not in the header are ignored, with a warning for each key missing
+// from a sampled header.
+func NewNdjsonStream(reader io.Reader, opts ...Option) (iface.CsvStream, error) {
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	text, err := newTextReader(reader, cfg.encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := &lineCounter{reader: text}
+	decoder := json.NewDecoder(lines)
+	decoder.UseNumber()
+	n := &ndjsonReader{
+		decoder: decoder,
+		lines:   lines,
+		name:    cfg.name,
+		header:  cfg.fields,
+	}
+
+	if len(n.header) == 0 {
+		if err := n.sampleHeader(); err != nil {
+			return nil, err
+		}
+		n.dropped = make(map[string]struct{})
+	}
+	n.columns = make(map[string]int, len(n.header))
+	for i, key := range n.header {
+		n.columns[key] = i
+	}
+	return n, nil
+}
+
+// sampleHeader reads the first objects and collects their keys
+func (n *ndjsonReader) sampleHeader() error {
+	seen := make(map[string]struct{})
+	for range ndjsonSampleSize {
+		object, err := n.readObject()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		n.pending = append(n.pending, object)
+		for _, field := range object.fields {
+			if _, ok := seen[field.key]; !ok {
+				seen[field.key] = struct{}{}
+				n.header = append(n.header, field.key)
+			}
+		}
+	}
+	return nil
+}
+
+// readObject decodes the next top level object, or array element, keeping the key order
+func (n *ndjsonReader) readObject() (ndjsonObject, error) {
+	var object ndjsonObject
+	if n.done {
+		return object, io.EOF
+	}
+	tok, err := n.decoder.Token()
+	if err != nil {
+		return object, n.positionError(err)
+	}
+	if delim, ok := tok.(json.Delim); ok && delim == '[' && !n.started {
+		n.array = true
+		if tok, err = n.decoder.Token(); err != nil {
+			return object, n.positionError(err)
+		}
+	}
+	n.started = true
+	if delim, ok := tok.(json.Delim); ok && delim == ']' && n.array {
+		n.done = true
+		return object, io.EOF
+	}
+	// the opening brace is a single byte just before the input offset
+	object.pos = n.lines.position(n.name, n.decoder.InputOffset()-1)
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return object, positionError(object.pos, errNdjsonNotObject)
+	}
+
+	for n.decoder.More() {
+		tok, err := n.decoder.Token()
+		if err != nil {
+			return object, n.positionError(err)
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := n.decoder.Decode(&value); err != nil {
+			return object, n.positionError(err)
+		}
+		object.fields = append(object.fields, ndjsonField{key: key, value: value})
+	}
+	// closing brace
+	if _, err := n.decoder.Token(); err != nil {
+		return object, n.positionError(err)
+	}
+	return object, nil
+}
+
+// positionError prefixes a decoding error with the position where decoding stopped
+func (n *ndjsonReader) positionError(err error) error {
+	offset := n.decoder.InputOffset()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+	return positionError(n.lines.position(n.name, offset), err)
+}
+
+// project places the object values into header order
+func (n *ndjsonReader) project(ctx context.Context, object ndjsonObject) ([]string, error) {
+	n.pos = object.pos
+	record := make([]string, len(n.header))
+	for _, field := range object.fields {
+		i, ok := n.columns[field.key]
+		if !ok {
+			n.drop(ctx, object.pos, field.key)
+			continue
+		}
+		value, err := renderJsonValue(field.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: field %q: %w", object.pos, field.key, err)
+		}
+		record[i] = value
+	}
+	return record, nil
+}
+
+// drop logs a key first seen after the header was sampled, explicit field lists drop keys quietly
+func (n *ndjsonReader) drop(ctx context.Context, pos iface.Position, key string) {
+	if n.dropped == nil {
+		return
+	}
+	if _, ok := n.dropped[key]; ok {
+		return
+	}
+	n.dropped[key] = struct{}{}
+	slog.WarnContext(ctx, "NDJSON key first seen after the header sample is dropped",
+		"key", key, "position", pos.String(), "sample", ndjsonSampleSize)
+}
+
+// renderJsonValue converts a raw JSON value into CSV field text
+func renderJsonValue(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		return "", nil
+	case raw[0] == '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	case raw[0] == '{' || raw[0] == '[':
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, raw); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	default:
+		return string(raw), nil
+	}
+}
+
+// ReadCsvRecord implements CsvStream.
+func (n *ndjsonReader) ReadCsvRecord(ctx context.Context) ([]string, error) {
+	if n == nil || n.decoder == nil {
+		return nil, io.EOF
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	if len(n.pending) > 0 {
+		object := n.pending[0]
+		n.pending = n.pending[1:]
+		return n.project(ctx, object)
+	}
+	object, err := n.readObject()
+	if err != nil {
+		return nil, err
+	}
+	return n.project(ctx, object)
+}
+
+// Position implements Positioner. It locates the opening brace of the last object.
+func (n *ndjsonReader) Position() iface.Position {
+	return n.pos
+}
+
+// FieldPosition implements FieldPositioner.
+// Keys have no fixed place in an object, so the column is the 1-based header field number.
+func (n *ndjsonReader) FieldPosition(field int) iface.Position {
+	pos := n.pos
+	pos.Column = field + 1
+	return pos
+}
+
+// GetHeader implements CsvStream.
+func (n *ndjsonReader) GetHeader() []string {
+	if n == nil {
+		return nil
+	}
+	return n.header
+}
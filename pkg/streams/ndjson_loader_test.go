@@ -0,0 +1,144 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const ndjsonData = `{"street":"main street","price":"100,000.00"}
+
+{"price":250000,"street":"oak avenue","county":"Dublin"}
+{"street":null,"price":1.5,"extra":{"a":[1, 2]},"vat":true}
+`
+
+func readAllNdjson(t *testing.T, data string, opts ...Option) [][]string {
+	t.Helper()
+	s, err := NewNdjsonStream(strings.NewReader(data), opts...)
+	if err != nil {
+		t.Fatalf("NewNdjsonStream() error = %v", err)
+	}
+	records := [][]string{s.GetHeader()}
+	for {
+		rec, err := s.ReadCsvRecord(context.Background())
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("ReadCsvRecord() error = %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestNdjsonStream(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want [][]string
+	}{
+		{
+			name: "header is the union of keys",
+			want: [][]string{
+				{"street", "price", "county", "extra", "vat"},
+				{"main street", "100,000.00", "", "", ""},
+				{"oak avenue", "250000", "Dublin", "", ""},
+				{"", "1.5", "", `{"a":[1,2]}`, "true"},
+			},
+		},
+		{
+			name: "explicit field list",
+			opts: []Option{WithFields("price", "street")},
+			want: [][]string{
+				{"price", "street"},
+				{"100,000.00", "main street"},
+				{"250000", "oak avenue"},
+				{"1.5", ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readAllNdjson(t, ndjsonData, tt.opts...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNdjsonStreamArray(t *testing.T) {
+	data := "[\n  " + strings.Join(strings.Split(strings.TrimSpace(strings.ReplaceAll(ndjsonData, "\n\n", "\n")), "\n"), ",\n  ") + "\n]\n"
+	if got, want := readAllNdjson(t, data), readAllNdjson(t, ndjsonData); !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+	if got := readAllNdjson(t, "[]"); len(got) != 1 || len(got[0]) != 0 {
+		t.Errorf("records of an empty array = %q, want only an empty header", got)
+	}
+	if _, err := NewNdjsonStream(strings.NewReader(`[{"a":1},[1,2]]`)); !errors.Is(err, errNdjsonNotObject) {
+		t.Errorf("NewNdjsonStream() error = %v, want %v", err, errNdjsonNotObject)
+	}
+}
+
+func TestNdjsonStreamNotObject(t *testing.T) {
+	_, err := NewNdjsonStream(strings.NewReader("{\"a\":1}\n[1,2]\n"))
+	if !errors.Is(err, errNdjsonNotObject) {
+		t.Errorf("NewNdjsonStream() error = %v, want %v", err, errNdjsonNotObject)
+	}
+}
+
+func TestNdjsonStreamEmpty(t *testing.T) {
+	s, err := NewNdjsonStream(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("NewNdjsonStream() error = %v", err)
+	}
+	if len(s.GetHeader()) != 0 {
+		t.Errorf("header = %v, want empty", s.GetHeader())
+	}
+	if _, err := s.ReadCsvRecord(context.Background()); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadCsvRecord() error = %v, want io.EOF", err)
+	}
+}
+
+func TestNdjsonStreamDroppedKeys(t *testing.T) {
+	data := strings.Repeat(`{"street":"main street","price":1}`+"\n", ndjsonSampleSize) +
+		`{"street":"oak avenue","price":2,"county":"Dublin"}` + "\n"
+	for _, tt := range []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{name: "sampled header", want: []string{"county"}},
+		{name: "explicit field list", opts: []Option{WithFields("street", "price")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewNdjsonStream(strings.NewReader(data), tt.opts...)
+			if err != nil {
+				t.Fatalf("NewNdjsonStream() error = %v", err)
+			}
+			var last []string
+			for {
+				rec, err := s.ReadCsvRecord(t.Context())
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadCsvRecord() error = %v", err)
+				}
+				last = rec
+			}
+			if want := []string{"oak avenue", "2"}; !reflect.DeepEqual(last, want) {
+				t.Errorf("last record = %q, want %q", last, want)
+			}
+			if got := slices.Sorted(maps.Keys(s.(*ndjsonReader).dropped)); !slices.Equal(got, tt.want) {
+				t.Errorf("dropped keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
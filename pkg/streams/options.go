@@ -0,0 +1,226 @@
+package streams
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+
+	"golang.org/x/text/encoding"
+)
+
+var (
+	errInvalidHeaderRow = errors.New("header row cannot be negative")
+	errInvalidWorkers   = errors.New("number of workers must be positive")
+	errInvalidChunkSize = errors.New("chunk size must be positive")
+	errEmptyHeader      = errors.New("header needs at least one column name")
+)
+
+// streamConfig collects stream settings before the stream is created
+type streamConfig struct {
+	dialect   CsvDialect
+	sniff     bool
+	encoding  encoding.Encoding
+	fields    []string
+	optional  []string
+	sheet     string
+	headerRow int
+	name      string
+	required  []string
+	workers   int
+	chunkSize int
+	ordered   bool
+	// header names the columns of input without a header row, syntheticHeader generates the names
+	header          []string
+	syntheticHeader bool
+}
+
+// Option configures a stream. Dialect options only affect CSV streams.
+type Option func(*streamConfig) error
+
+func newStreamConfig(opts []Option) (*streamConfig, error) {
+	cfg := &streamConfig{dialect: defaultCsvDialect, workers: runtime.GOMAXPROCS(0), chunkSize: defaultChunkSize}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// WithEncoding sets the character encoding of the input, e.g. "windows-1252", "latin1" or "utf-16le".
+// "auto" (the default) reads UTF-8 and decodes bytes which are not valid UTF-8 as Windows-1252,
+// a byte order mark selects UTF-8 or UTF-16.
+func WithEncoding(name string) Option {
+	return func(c *streamConfig) error {
+		enc, err := LookupEncoding(name)
+		if err != nil {
+			return err
+		}
+		c.encoding = enc
+		return nil
+	}
+}
+
+// WithName sets the source name, usually the file name, reported in positions and errors
+func WithName(name string) Option {
+	return func(c *streamConfig) error {
+		c.name = name
+		return nil
+	}
+}
+
+// WithFields sets the field list of streams whose records are not positional, e.g. NDJSON.
+// Records are projected onto the fields in the given order.
+func WithFields(fields ...string) Option {
+	return func(c *streamConfig) error {
+		c.fields = append([]string(nil), fields...)
+		return nil
+	}
+}
+
+// WithOptionalFields adds columns to the WithFields projection of mapped CSV and Parquet streams
+// when the input has them, missing ones are left out instead of failing
+func WithOptionalFields(fields ...string) Option {
+	return func(c *streamConfig) error {
+		c.optional = append([]string(nil), fields...)
+		return nil
+	}
+}
+
+// WithRequired lists the columns every input of a multi-file stream must have, matched case-insensitively
+func WithRequired(columns ...string) Option {
+	return func(c *streamConfig) error {
+		c.required = append([]string(nil), columns...)
+		return nil
+	}
+}
+
+// WithWorkers sets the number of goroutines of a parallel stream, GOMAXPROCS by default
+func WithWorkers(workers int) Option {
+	return func(c *streamConfig) error {
+		if workers < 1 {
+			return errInvalidWorkers
+		}
+		c.workers = workers
+		return nil
+	}
+}
+
+// WithChunkSize sets the number of bytes a parallel stream reads and parses at once, 4 MiB by default
+func WithChunkSize(size int) Option {
+	return func(c *streamConfig) error {
+		if size < 1 {
+			return errInvalidChunkSize
+		}
+		c.chunkSize = size
+		return nil
+	}
+}
+
+// WithOrdered makes a parallel stream return records in file order.
+// Without it records of different chunks arrive in the order they are parsed.
+func WithOrdered() Option {
+	return func(c *streamConfig) error {
+		c.ordered = true
+		return nil
+	}
+}
+
+// WithHeader sets the column names of CSV input without a header row, its first row is data
+func WithHeader(names ...string) Option {
+	return func(c *streamConfig) error {
+		if len(names) == 0 {
+			return errEmptyHeader
+		}
+		c.header = append([]string(nil), names...)
+		return nil
+	}
+}
+
+// WithSyntheticHeader names the columns of CSV input without a header row "col1" to "colN",
+// N is the number of fields of the first row, which is data
+func WithSyntheticHeader() Option {
+	return func(c *streamConfig) error {
+		c.syntheticHeader = true
+		return nil
+	}
+}
+
+// WithSheet selects the worksheet of a spreadsheet by name or 1-based position
+func WithSheet(sheet string) Option {
+	return func(c *streamConfig) error {
+		c.sheet = strings.TrimSpace(sheet)
+		return nil
+	}
+}
+
+// WithHeaderRow sets the 1-based spreadsheet row holding the column names, rows above it are skipped
+func WithHeaderRow(row int) Option {
+	return func(c *streamConfig) error {
+		if row < 0 {
+			return errInvalidHeaderRow
+		}
+		c.headerRow = row
+		return nil
+	}
+}
+
+// WithDialect replaces the whole dialect. Zero delimiter and quote keep their defaults.
+func WithDialect(dialect CsvDialect) Option {
+	return func(c *streamConfig) error {
+		if dialect.Delimiter == 0 {
+			dialect.Delimiter = defaultCsvDialect.Delimiter
+		}
+		if dialect.Quote == 0 {
+			dialect.Quote = defaultCsvDialect.Quote
+		}
+		c.dialect = dialect
+		return nil
+	}
+}
+
+// WithDelimiter sets the field delimiter
+func WithDelimiter(delimiter rune) Option {
+	return func(c *streamConfig) error {
+		c.dialect.Delimiter = delimiter
+		return nil
+	}
+}
+
+// WithQuote sets the quote character
+func WithQuote(quote rune) Option {
+	return func(c *streamConfig) error {
+		c.dialect.Quote = quote
+		return nil
+	}
+}
+
+// WithComment sets the character which starts comment lines
+func WithComment(comment rune) Option {
+	return func(c *streamConfig) error {
+		c.dialect.Comment = comment
+		return nil
+	}
+}
+
+// WithLazyQuotes tolerates stray quotes the way encoding/csv LazyQuotes does
+func WithLazyQuotes() Option {
+	return func(c *streamConfig) error {
+		c.dialect.LazyQuotes = true
+		return nil
+	}
+}
+
+// WithTSV reads tab separated values
+func WithTSV() Option {
+	return WithDelimiter('\t')
+}
+
+// WithSniff guesses the delimiter and quote character from the beginning of the input.
+// Other dialect settings are kept.
+func WithSniff() Option {
+	return func(c *streamConfig) error {
+		c.sniff = true
+		return nil
+	}
+}
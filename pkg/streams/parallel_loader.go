@@ -0,0 +1,549 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+const (
+	defaultChunkSize = 4 << 20
+	// chunkSlack is spare capacity of a range buffer, the head of the next range
+	// completing its last record is usually appended without a copy
+	chunkSlack = 64 << 10
+)
+
+var (
+	errParallelCompressed = errors.New("parallel CSV reading needs uncompressed input")
+	errParallelEncoding   = errors.New("parallel CSV reading needs an ASCII compatible encoding")
+	errParallelLazyQuotes = errors.New("parallel CSV reading needs balanced quotes, lazy quotes are not supported")
+)
+
+// parallelRange is a fixed byte range of the file, read concurrently with its neighbours
+type parallelRange struct {
+	offset int64
+	data   []byte
+	// quotes and newlines are the numbers of quote and line feed bytes in data
+	quotes   int
+	newlines int
+	err      error
+	done     chan struct{}
+}
+
+// parallelChunk holds whole records starting at the offset and line
+type parallelChunk struct {
+	offset int64
+	line   int
+	data   []byte
+	err    error
+	// batch receives the parsed records in ordered mode
+	batch chan *parallelBatch
+}
+
+// parallelBatch holds the records of a chunk with their lines and offsets.
+// err follows the records, parsing stops at the first error.
+type parallelBatch struct {
+	records [][]string
+	lines   []int
+	offsets []int64
+	err     error
+}
+
+// parallelCsvReader parses chunks of a CSV file on several goroutines
+type parallelCsvReader struct {
+	header   []string
+	name     string
+	dialect  CsvDialect
+	encoding encoding.Encoding
+	cancel   context.CancelFunc
+	// pending delivers chunks in file order in ordered mode, results delivers batches otherwise
+	pending chan *parallelChunk
+	results chan *parallelBatch
+	batch   *parallelBatch
+	next    int
+	// line and offset locate the last returned record
+	line   int
+	offset int64
+	// err is returned once the batches are drained
+	err error
+}
+
+var (
+	_ iface.CsvStream       = (*parallelCsvReader)(nil)
+	_ iface.Positioner      = (*parallelCsvReader)(nil)
+	_ iface.FieldPositioner = (*parallelCsvReader)(nil)
+	_ io.Closer             = (*parallelCsvReader)(nil)
+)
+
+// NewParallelCsvStream creates a CSV stream which parses a large file on several goroutines.
+// The file is split into WithChunkSize byte ranges which are read concurrently; every range is
+// moved to the first line break outside quotes, found from the parity of the quote characters
+// before it, and the resulting chunks of whole records are parsed concurrently by WithWorkers goroutines.
+// Records come in the order chunks are parsed unless WithOrdered is given.
+//
+// The input must be uncompressed, in UTF-8 or an ASCII compatible encoding, and its quotes must be
+// balanced: lazy quotes are rejected and comment lines must not contain unpaired quote characters.
+// The stream implements io.Closer to stop its goroutines before the end of input.
+func NewParallelCsvStream(reader io.ReaderAt, size int64, opts ...Option) (iface.CsvStream, error) {
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	head := bufio.NewReaderSize(io.NewSectionReader(reader, 0, size), sniffSize)
+	compression, err := DetectCompression(head)
+	if err != nil {
+		return nil, err
+	}
+	if compression != CompressionNone {
+		return nil, fmt.Errorf("%w: %s detected", errParallelCompressed, compression)
+	}
+
+	enc := cfg.encoding
+	if enc == nil {
+		if prefix, _ := head.Peek(len(utf16LEBOM)); bytes.Equal(prefix, utf16LEBOM) || bytes.Equal(prefix, utf16BEBOM) {
+			return nil, fmt.Errorf("%w: UTF-16 byte order mark found", errParallelEncoding)
+		}
+		enc = utf8WithFallback
+	} else if !asciiCompatible(enc) {
+		return nil, errParallelEncoding
+	}
+	var start int64
+	if prefix, _ := head.Peek(len(utf8BOM)); bytes.Equal(prefix, utf8BOM) {
+		start = int64(len(utf8BOM))
+		head.Discard(len(utf8BOM))
+	}
+
+	if cfg.sniff {
+		if cfg.dialect, err = sniffDialect(head, cfg.dialect); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.dialect.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.dialect.LazyQuotes {
+		return nil, errParallelLazyQuotes
+	}
+
+	p := &parallelCsvReader{name: cfg.name, dialect: cfg.dialect, encoding: enc}
+	quote := byte(cfg.dialect.Quote)
+	var comment []byte
+	if cfg.dialect.Comment != 0 {
+		comment = utf8.AppendRune(nil, cfg.dialect.Comment)
+	}
+	headerSize, headerLines, err := headerEnd(head, quote, comment)
+	if err != nil && (cfg.header == nil || !errors.Is(err, io.EOF)) {
+		return nil, err
+	}
+	if cfg.header != nil {
+		p.header = cfg.header
+	} else if p.header, err = p.readHeader(reader, start, headerSize); err != nil {
+		return nil, err
+	}
+	if cfg.syntheticHeader && cfg.header == nil {
+		p.header = syntheticHeader(len(p.header))
+	}
+	if cfg.header != nil || cfg.syntheticHeader {
+		// the first row is data
+		headerSize, headerLines = 0, 0
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	ranges := readRanges(ctx, reader, start+headerSize, size, cfg.chunkSize, cfg.workers, quote)
+	chunks := make(chan *parallelChunk, cfg.workers)
+	if cfg.ordered {
+		p.pending = make(chan *parallelChunk, cfg.workers)
+	} else {
+		p.results = make(chan *parallelBatch, cfg.workers)
+	}
+	go p.split(ctx, ranges, chunks, start+headerSize, headerLines+1, quote)
+
+	var wg sync.WaitGroup
+	for range cfg.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.parse(ctx, chunks)
+		}()
+	}
+	if !cfg.ordered {
+		go func() {
+			wg.Wait()
+			close(p.results)
+		}()
+	}
+	return p, nil
+}
+
+// asciiCompatible reports whether the encoding keeps ASCII bytes, so record boundaries
+// can be found in the raw bytes
+func asciiCompatible(enc encoding.Encoding) bool {
+	const ascii = "\t\n\r \"#',;|"
+	encoded, err := enc.NewEncoder().String(ascii)
+	return err == nil && encoded == ascii
+}
+
+// headerEnd returns the number of bytes up to the end of the header record and the number
+// of line feeds among them. Empty and comment lines before the header are skipped.
+func headerEnd(reader *bufio.Reader, quote byte, comment []byte) (int64, int, error) {
+	var (
+		n                 int64
+		lines             int
+		lineStart         = true
+		inQuote, inRecord bool
+	)
+	for {
+		if lineStart && len(comment) > 0 {
+			if prefix, _ := reader.Peek(len(comment)); bytes.Equal(prefix, comment) {
+				line, err := reader.ReadSlice('\n')
+				for errors.Is(err, bufio.ErrBufferFull) {
+					n += int64(len(line))
+					line, err = reader.ReadSlice('\n')
+				}
+				n += int64(len(line))
+				if err != nil {
+					return 0, 0, err
+				}
+				lines++
+				continue
+			}
+		}
+
+		b, err := reader.ReadByte()
+		if errors.Is(err, io.EOF) && inRecord {
+			// the header is the last line without a line break
+			return n, lines, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		n++
+		switch {
+		case b == '\n':
+			lines++
+			if inRecord && !inQuote {
+				return n, lines, nil
+			}
+			lineStart = !inRecord
+		case b == '\r' && !inRecord:
+		default:
+			if b == quote {
+				inQuote = !inQuote
+			}
+			inRecord, lineStart = true, false
+		}
+	}
+}
+
+// readHeader parses the header record in the first size bytes after start
+func (p *parallelCsvReader) readHeader(reader io.ReaderAt, start, size int64) ([]string, error) {
+	data := make([]byte, size)
+	if _, err := reader.ReadAt(data, start); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	batch := p.parseChunk(&parallelChunk{offset: start, line: 1, data: data})
+	if batch.err != nil {
+		return nil, batch.err
+	}
+	if len(batch.records) == 0 {
+		return nil, io.EOF
+	}
+	return batch.records[0], nil
+}
+
+// readRanges reads the file from offset in ranges of chunkSize bytes on several goroutines.
+// Ranges are delivered in file order, their done channel is closed once they are read.
+func readRanges(ctx context.Context, reader io.ReaderAt, offset, size int64, chunkSize, workers int, quote byte) <-chan *parallelRange {
+	ordered := make(chan *parallelRange, workers)
+	jobs := make(chan *parallelRange, workers)
+	for range workers {
+		go func() {
+			for r := range jobs {
+				r.read(reader, quote)
+				close(r.done)
+			}
+		}()
+	}
+	go func() {
+		defer close(ordered)
+		defer close(jobs)
+		for ; offset < size; offset += int64(chunkSize) {
+			n := int(min(int64(chunkSize), size-offset))
+			r := &parallelRange{offset: offset, data: make([]byte, n, n+chunkSlack), done: make(chan struct{})}
+			select {
+			case jobs <- r:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case ordered <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ordered
+}
+
+func (r *parallelRange) read(reader io.ReaderAt, quote byte) {
+	n, err := reader.ReadAt(r.data, r.offset)
+	if n == len(r.data) && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	if err != nil {
+		r.err = fmt.Errorf("read at offset %d: %w", r.offset, err)
+		return
+	}
+	r.quotes = bytes.Count(r.data, []byte{quote})
+	r.newlines = bytes.Count(r.data, []byte{'\n'})
+}
+
+// recordBoundary returns the index following the first line feed outside quotes, -1 when there is none.
+// inQuote tells whether data starts inside a quoted field.
+func recordBoundary(data []byte, quote byte, inQuote bool) int {
+	for i, b := range data {
+		switch {
+		case b == quote:
+			inQuote = !inQuote
+		case b == '\n' && !inQuote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// split turns the ranges into chunks of whole records and hands them to the parsers.
+// The quote state at the beginning of each range is the parity of all quotes before it.
+func (p *parallelCsvReader) split(ctx context.Context, ranges <-chan *parallelRange, chunks chan<- *parallelChunk, offset int64, line int, quote byte) {
+	defer close(chunks)
+	if p.pending != nil {
+		defer close(p.pending)
+	}
+
+	emit := func(c *parallelChunk) bool {
+		if p.pending != nil {
+			c.batch = make(chan *parallelBatch, 1)
+		}
+		select {
+		case chunks <- c:
+		case <-ctx.Done():
+			return false
+		}
+		if p.pending != nil {
+			select {
+			case p.pending <- c:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+
+	var (
+		carry     []byte
+		inQuote   bool
+		rangeLine = line
+	)
+	for r := range ranges {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return
+		}
+		if r.err != nil {
+			emit(&parallelChunk{offset: r.offset, line: rangeLine, err: r.err})
+			return
+		}
+
+		cut := 0
+		if carry != nil {
+			cut = recordBoundary(r.data, quote, inQuote)
+		}
+		switch {
+		case cut < 0:
+			// a record spans the whole range
+			carry = append(carry, r.data...)
+		case cut == 0:
+			carry = r.data
+		default:
+			data := append(carry, r.data[:cut]...)
+			if !emit(&parallelChunk{offset: offset, line: line, data: data}) {
+				return
+			}
+			offset = r.offset + int64(cut)
+			line = rangeLine + bytes.Count(r.data[:cut], []byte{'\n'})
+			carry = r.data[cut:]
+		}
+		inQuote = inQuote != (r.quotes%2 == 1)
+		rangeLine += r.newlines
+	}
+	if len(carry) > 0 {
+		emit(&parallelChunk{offset: offset, line: line, data: carry})
+	}
+}
+
+// parse parses chunks until there are no more
+func (p *parallelCsvReader) parse(ctx context.Context, chunks <-chan *parallelChunk) {
+	for c := range chunks {
+		batch := p.parseChunk(c)
+		if c.batch != nil {
+			c.batch <- batch
+			continue
+		}
+		select {
+		case p.results <- batch:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// parseChunk parses the records of a chunk, lines and offsets are made absolute
+func (p *parallelCsvReader) parseChunk(c *parallelChunk) *parallelBatch {
+	batch := &parallelBatch{err: c.err}
+	if c.err != nil {
+		return batch
+	}
+
+	reader, quote := newDialectReader(decodeChunk(c.data, p.encoding), p.dialect)
+	for {
+		offset := reader.InputOffset()
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return batch
+		}
+		if err != nil {
+			pos := iface.Position{Source: p.name, Offset: c.offset + offset}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				parseErr.StartLine += c.line - 1
+				parseErr.Line += c.line - 1
+				pos.Line = parseErr.Line
+			}
+			batch.err = positionError(pos, err)
+			return batch
+		}
+		if quote != 0 {
+			swapRecordQuotes(record, quote)
+		}
+		line, _ := reader.FieldPos(0)
+		batch.records = append(batch.records, record)
+		batch.lines = append(batch.lines, c.line+line-1)
+		batch.offsets = append(batch.offsets, c.offset+offset)
+	}
+}
+
+// decodeChunk transcodes a chunk to UTF-8, valid UTF-8 is read as it is
+func decodeChunk(data []byte, enc encoding.Encoding) io.Reader {
+	if enc == utf8WithFallback && utf8.Valid(data) {
+		return bytes.NewReader(data)
+	}
+	return transform.NewReader(bytes.NewReader(data), enc.NewDecoder())
+}
+
+// nextBatch waits for the next parsed chunk, it returns io.EOF after the last one
+func (p *parallelCsvReader) nextBatch(ctx context.Context) (*parallelBatch, error) {
+	if p.pending == nil {
+		select {
+		case batch, ok := <-p.results:
+			if !ok {
+				return nil, io.EOF
+			}
+			return batch, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	var chunk *parallelChunk
+	select {
+	case c, ok := <-p.pending:
+		if !ok {
+			return nil, io.EOF
+		}
+		chunk = c
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case batch := <-chunk.batch:
+		return batch, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ReadCsvRecord implements CsvStream.
+func (p *parallelCsvReader) ReadCsvRecord(ctx context.Context) ([]string, error) {
+	if p == nil || p.cancel == nil {
+		return nil, io.EOF
+	}
+	for {
+		if p.batch != nil {
+			if p.next < len(p.batch.records) {
+				i := p.next
+				p.next++
+				p.line, p.offset = p.batch.lines[i], p.batch.offsets[i]
+				return p.batch.records[i], nil
+			}
+			if p.batch.err != nil {
+				p.err = p.batch.err
+				p.cancel()
+			}
+			p.batch = nil
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+
+		batch, err := p.nextBatch(ctx)
+		if errors.Is(err, io.EOF) {
+			p.err = io.EOF
+			p.cancel()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.batch, p.next = batch, 0
+	}
+}
+
+// GetHeader implements CsvStream.
+func (p *parallelCsvReader) GetHeader() []string {
+	if p == nil {
+		return nil
+	}
+	return p.header
+}
+
+// Position implements Positioner.
+func (p *parallelCsvReader) Position() iface.Position {
+	return p.FieldPosition(0)
+}
+
+// FieldPosition implements FieldPositioner. The line is the one where the record starts.
+func (p *parallelCsvReader) FieldPosition(field int) iface.Position {
+	return iface.Position{Source: p.name, Line: p.line, Column: field + 1, Offset: p.offset}
+}
+
+// Close implements io.Closer, it stops reading and parsing ahead
+func (p *parallelCsvReader) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package streams
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"propertytreeanalyzer/internal/registertest"
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+// parallelTestCsv builds records with quoted delimiters, escaped quotes and line breaks in fields
+func parallelTestCsv(rows int) string {
+	var b strings.Builder
+	b.WriteString("# register export\n\nStreet Name,Price,Notes\n")
+	for i := range rows {
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&b, "street %d,%d,plain\n", i, i*10)
+		case 1:
+			fmt.Fprintf(&b, "\"street, %d\",\"%d\",\"say \"\"hi\"\"\"\n", i, i*10)
+		case 2:
+			fmt.Fprintf(&b, "street %d,%d,\"multi\nline\n\"\"note\"\"\"\r\n", i, i*10)
+		default:
+			fmt.Fprintf(&b, "street %d,%d,\n", i, i*10)
+		}
+	}
+	return b.String()
+}
+
+func drainStream(t testing.TB, s iface.CsvStream) ([][]string, []int) {
+	t.Helper()
+	var records [][]string
+	var lines []int
+	for {
+		rec, err := s.ReadCsvRecord(context.Background())
+		if errors.Is(err, io.EOF) {
+			return records, lines
+		}
+		if err != nil {
+			t.Fatalf("ReadCsvRecord() error = %v", err)
+		}
+		records = append(records, rec)
+		lines = append(lines, s.(iface.Positioner).Position().Line)
+	}
+}
+
+func TestParallelCsvStream(t *testing.T) {
+	data := parallelTestCsv(500)
+	sequential, err := NewCsvStream(strings.NewReader(data), WithComment('#'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, wantLines := drainStream(t, sequential)
+
+	for _, chunkSize := range []int{1, 7, 64, 1000, 1 << 20} {
+		t.Run(fmt.Sprintf("chunk %d", chunkSize), func(t *testing.T) {
+			s, err := NewParallelCsvStream(strings.NewReader(data), int64(len(data)),
+				WithComment('#'), WithChunkSize(chunkSize), WithWorkers(4), WithOrdered())
+			if err != nil {
+				t.Fatalf("NewParallelCsvStream() error = %v", err)
+			}
+			defer s.(io.Closer).Close()
+			if got := s.GetHeader(); !reflect.DeepEqual(got, sequential.GetHeader()) {
+				t.Errorf("GetHeader() = %v, want %v", got, sequential.GetHeader())
+			}
+			got, gotLines := drainStream(t, s)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("records differ from the sequential reader: got %d, want %d", len(got), len(want))
+			}
+			if !reflect.DeepEqual(gotLines, wantLines) {
+				t.Errorf("record lines = %v, want %v", gotLines[:8], wantLines[:8])
+			}
+		})
+	}
+}
+
+func TestParallelCsvStreamUnordered(t *testing.T) {
+	data := "street;price\n" + strings.Repeat("'a;b';1\n'c\nd';2\n", 200)
+	s, err := NewParallelCsvStream(strings.NewReader(data), int64(len(data)),
+		WithDelimiter(';'), WithQuote('\''), WithChunkSize(50), WithWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := drainStream(t, s)
+	keys := make([]string, 0, len(got))
+	for _, rec := range got {
+		keys = append(keys, strings.Join(rec, "|"))
+	}
+	slices.Sort(keys)
+	want := slices.Concat(slices.Repeat([]string{"a;b|1"}, 200), slices.Repeat([]string{"c\nd|2"}, 200))
+	if !slices.Equal(keys, want) {
+		t.Errorf("got %d records, want %d", len(keys), len(want))
+	}
+}
+
+func TestParallelCsvStreamErrors(t *testing.T) {
+	data := "street,price\na,1\nb,2\nc,\"3\nd,4\n"
+	s, err := NewParallelCsvStream(strings.NewReader(data), int64(len(data)), WithChunkSize(4), WithName("prices.csv"), WithOrdered())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for range 2 {
+		if _, err := s.ReadCsvRecord(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = s.ReadCsvRecord(ctx)
+	want := `prices.csv:5: record on line 4; parse error on line 5, column 5: extraneous or missing " in quoted-field`
+	if err == nil || err.Error() != want {
+		t.Errorf("ReadCsvRecord() error = %v, want %s", err, want)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(data))
+	zw.Close()
+	if _, err := NewParallelCsvStream(bytes.NewReader(buf.Bytes()), int64(buf.Len())); !errors.Is(err, errParallelCompressed) {
+		t.Errorf("compressed input error = %v, want %v", err, errParallelCompressed)
+	}
+	if _, err := NewParallelCsvStream(strings.NewReader(data), int64(len(data)), WithLazyQuotes()); !errors.Is(err, errParallelLazyQuotes) {
+		t.Errorf("lazy quotes error = %v, want %v", err, errParallelLazyQuotes)
+	}
+	if _, err := NewParallelCsvStream(strings.NewReader(data), int64(len(data)), WithEncoding("utf-16le")); !errors.Is(err, errParallelEncoding) {
+		t.Errorf("UTF-16 error = %v, want %v", err, errParallelEncoding)
+	}
+	if _, err := NewParallelCsvStream(strings.NewReader(""), 0); !errors.Is(err, io.EOF) {
+		t.Errorf("empty input error = %v, want %v", err, io.EOF)
+	}
+}
+
+func benchmarkCsvFile(b *testing.B, rows int) (*os.File, int64) {
+	b.Helper()
+	file, size := registertest.File(b, "prices.csv", func(w io.Writer) error {
+		_, err := io.WriteString(w, parallelTestCsv(rows))
+		return err
+	})
+	b.SetBytes(size)
+	return file, size
+}
+
+func BenchmarkCsvStream(b *testing.B) {
+	file, _ := benchmarkCsvFile(b, 500_000)
+	for b.Loop() {
+		s, err := NewCsvStream(io.NewSectionReader(file, 0, 1<<62), WithComment('#'))
+		if err != nil {
+			b.Fatal(err)
+		}
+		drainStream(b, s)
+	}
+}
+
+func BenchmarkParallelCsvStream(b *testing.B) {
+	file, size := benchmarkCsvFile(b, 500_000)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers %d", workers), func(b *testing.B) {
+			b.SetBytes(size)
+			for b.Loop() {
+				s, err := NewParallelCsvStream(file, size, WithComment('#'), WithWorkers(workers))
+				if err != nil {
+					b.Fatal(err)
+				}
+				drainStream(b, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+const (
+	// parquetDateLayout matches the "Date of Sale (dd/mm/yyyy)" column of the CSV register
+	parquetDateLayout = "02/01/2006"
+	// parquetValueBuffer is the number of values decoded from a page at once
+	parquetValueBuffer = 1024
+)
+
+var (
+	errParquetColumnMissing  = errors.New("parquet column not found in schema")
+	errParquetColumnRepeated = errors.New("repeated parquet columns cannot be read as CSV fields")
+)
+
+// parquetColumn reads the values of one leaf column page by page
+type parquetColumn struct {
+	index  int
+	render func(parquet.Value) string
+	pages  parquet.Pages
+	values parquet.ValueReader
+	buf    []parquet.Value
+	pos    int
+}
+
+// parquetReader exposes the rows of a Parquet file as CSV records
+type parquetReader struct {
+	file     *parquet.File
+	header   []string
+	columns  []*parquetColumn
+	rowGroup int
+	// rowsLeft is the number of rows not yet read from the current row group
+	rowsLeft int64
+	// name and row locate the last record, row is its 1-based number in the file
+	name string
+	row  int
+}
+
+var (
+	_ iface.CsvStream       = (*parquetReader)(nil)
+	_ iface.Positioner      = (*parquetReader)(nil)
+	_ iface.FieldPositioner = (*parquetReader)(nil)
+)
+
+// NewParquetStream creates a CSV stream from a Parquet file.
+// The header comes from the Parquet schema, nested fields are joined with dots.
+// WithFields restricts the stream to the named columns (matched case-insensitively),
+// only their column chunks are read; WithOptionalFields adds the named columns it finds. Decimals are rendered as plain decimal text,
+// dates as dd/mm/yyyy and timestamps as RFC 3339, nulls become empty strings.
+func NewParquetStream(reader io.ReaderAt, size int64, opts ...Option) (iface.CsvStream, error) {
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	file, err := parquet.OpenFile(reader, size, parquet.SkipPageIndex(true), parquet.SkipBloomFilters(true))
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := make(map[string]*parquet.Column)
+	var names []string
+	for _, path := range file.Schema().Columns() {
+		column := file.Root()
+		for _, name := range path {
+			column = column.Column(name)
+		}
+		name := strings.Join(path, ".")
+		leaves[strings.ToLower(name)] = column
+		if !column.Repeated() {
+			names = append(names, name)
+		}
+	}
+	if len(cfg.fields) != 0 {
+		names = cfg.fields
+	}
+
+	p := &parquetReader{file: file, rowGroup: -1, name: cfg.name}
+	if len(cfg.fields) != 0 {
+		for _, name := range cfg.optional {
+			if _, ok := leaves[strings.ToLower(strings.TrimSpace(name))]; ok {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		column, ok := leaves[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errParquetColumnMissing, name)
+		}
+		if column.Repeated() || column.MaxRepetitionLevel() > 0 {
+			return nil, fmt.Errorf("%w: %q", errParquetColumnRepeated, name)
+		}
+		p.header = append(p.header, strings.Join(column.Path(), "."))
+		p.columns = append(p.columns, &parquetColumn{
+			index:  column.Index(),
+			render: parquetRenderer(column.Type()),
+			buf:    make([]parquet.Value, 0, parquetValueBuffer),
+		})
+	}
+	return p, nil
+}
+
+// nextRowGroup switches every projected column to the next row group
+func (p *parquetReader) nextRowGroup() error {
+	p.closeColumns()
+	for {
+		p.rowGroup++
+		groups := p.file.RowGroups()
+		if p.rowGroup >= len(groups) {
+			return io.EOF
+		}
+		group := groups[p.rowGroup]
+		if group.NumRows() == 0 {
+			continue
+		}
+		chunks := group.ColumnChunks()
+		for _, column := range p.columns {
+			column.pages = chunks[column.index].Pages()
+			column.values = nil
+			column.buf = column.buf[:0]
+			column.pos = 0
+		}
+		p.rowsLeft = group.NumRows()
+		return nil
+	}
+}
+
+func (p *parquetReader) closeColumns() {
+	for _, column := range p.columns {
+		if column.pages != nil {
+			column.pages.Close()
+			column.pages = nil
+		}
+	}
+}
+
+// next returns the next value of the column, reading new pages when needed
+func (c *parquetColumn) next() (parquet.Value, error) {
+	for c.pos >= len(c.buf) {
+		if c.values == nil {
+			page, err := c.pages.ReadPage()
+			if err != nil {
+				return parquet.Value{}, err
+			}
+			c.values = page.Values()
+		}
+		n, err := c.values.ReadValues(c.buf[:cap(c.buf)])
+		c.buf, c.pos = c.buf[:n], 0
+		if errors.Is(err, io.EOF) {
+			c.values = nil
+		} else if err != nil {
+			return parquet.Value{}, err
+		}
+	}
+	v := c.buf[c.pos]
+	c.pos++
+	return v, nil
+}
+
+// ReadCsvRecord implements CsvStream.
+func (p *parquetReader) ReadCsvRecord(ctx context.Context) ([]string, error) {
+	if p == nil || p.file == nil {
+		return nil, io.EOF
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	if p.rowsLeft == 0 {
+		if err := p.nextRowGroup(); err != nil {
+			return nil, err
+		}
+	}
+	p.row++
+	record := make([]string, len(p.columns))
+	for i, column := range p.columns {
+		v, err := column.next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: parquet column %q ended before its row group: %w", p.FieldPosition(i), p.header[i], io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return nil, positionError(p.FieldPosition(i), err)
+		}
+		if !v.IsNull() {
+			record[i] = column.render(v)
+		}
+	}
+	p.rowsLeft--
+	return record, nil
+}
+
+// Position implements Positioner. The line is the 1-based row number.
+func (p *parquetReader) Position() iface.Position {
+	return iface.Position{Source: p.name, Line: p.row}
+}
+
+// FieldPosition implements FieldPositioner. The column is the 1-based projected column number.
+func (p *parquetReader) FieldPosition(field int) iface.Position {
+	return iface.Position{Source: p.name, Line: p.row, Column: field + 1}
+}
+
+// GetHeader implements CsvStream.
+func (p *parquetReader) GetHeader() []string {
+	if p == nil {
+		return nil
+	}
+	return p.header
+}
+
+// parquetRenderer chooses how values of the column type are turned into text
+func parquetRenderer(typ parquet.Type) func(parquet.Value) string {
+	logical := typ.LogicalType()
+	switch {
+	case logical != nil && logical.Decimal != nil:
+		scale := int(logical.Decimal.Scale)
+		return func(v parquet.Value) string { return formatDecimal(decimalUnscaled(v), scale) }
+	case logical != nil && logical.Date != nil:
+		return func(v parquet.Value) string {
+			return time.Unix(int64(v.Int32())*24*60*60, 0).UTC().Format(parquetDateLayout)
+		}
+	case logical != nil && logical.Timestamp != nil:
+		unit := logical.Timestamp.Unit
+		return func(v parquet.Value) string { return parquetTimestamp(v.Int64(), unit).Format(time.RFC3339Nano) }
+	}
+
+	switch typ.Kind() {
+	case parquet.Boolean:
+		return func(v parquet.Value) string { return strconv.FormatBool(v.Boolean()) }
+	case parquet.Int32:
+		return func(v parquet.Value) string { return strconv.FormatInt(int64(v.Int32()), 10) }
+	case parquet.Int64:
+		return func(v parquet.Value) string { return strconv.FormatInt(v.Int64(), 10) }
+	case parquet.Float:
+		return func(v parquet.Value) string { return strconv.FormatFloat(float64(v.Float()), 'f', -1, 32) }
+	case parquet.Double:
+		return func(v parquet.Value) string { return strconv.FormatFloat(v.Double(), 'f', -1, 64) }
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return func(v parquet.Value) string { return string(v.ByteArray()) }
+	default:
+		return parquet.Value.String
+	}
+}
+
+// decimalUnscaled returns the unscaled integer of a decimal stored as
+// INT32, INT64 or a big-endian two's complement byte array
+func decimalUnscaled(v parquet.Value) *big.Int {
+	switch v.Kind() {
+	case parquet.Int32:
+		return big.NewInt(int64(v.Int32()))
+	case parquet.Int64:
+		return big.NewInt(v.Int64())
+	}
+	b := v.ByteArray()
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return n
+}
+
+// formatDecimal renders unscaled * 10^-scale without exponent, e.g. 7950000 with scale 2 is "79500.00"
+func formatDecimal(unscaled *big.Int, scale int) string {
+	digits := new(big.Int).Abs(unscaled).String()
+	sign := ""
+	if unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if scale <= 0 {
+		return sign + digits + strings.Repeat("0", -scale)
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+func parquetTimestamp(value int64, unit format.TimeUnit) time.Time {
+	switch {
+	case unit.Millis != nil:
+		return time.UnixMilli(value).UTC()
+	case unit.Micros != nil:
+		return time.UnixMicro(value).UTC()
+	default:
+		return time.Unix(0, value).UTC()
+	}
+}
@@ -0,0 +1,134 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+type parquetSale struct {
+	Date    int32   `parquet:"Date of Sale,date"`
+	Address string  `parquet:"Address"`
+	Street  *string `parquet:"Street Name,optional"`
+	Price   int64   `parquet:"Price,decimal(2:18)"`
+	Vat     bool    `parquet:"VAT Exclusive"`
+}
+
+func writeParquet(t *testing.T, rowGroups ...[]parquetSale) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := parquet.NewGenericWriter[parquetSale](&buf)
+	for _, rows := range rowGroups {
+		if _, err := w.Write(rows); err != nil {
+			t.Fatalf("write rows: %v", err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("flush row group: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close writer: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func readAllParquet(t *testing.T, data []byte, opts ...Option) [][]string {
+	t.Helper()
+	s, err := NewParquetStream(bytes.NewReader(data), int64(len(data)), opts...)
+	if err != nil {
+		t.Fatalf("NewParquetStream() error = %v", err)
+	}
+	records := [][]string{s.GetHeader()}
+	for {
+		rec, err := s.ReadCsvRecord(context.Background())
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("ReadCsvRecord() error = %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestParquetStream(t *testing.T) {
+	park, charlemont := "the park", "charlemont"
+	// 16436 days since the epoch is 01/01/2015
+	data := writeParquet(t,
+		[]parquetSale{
+			{Date: 16436, Address: "APT 274, THE PARKLANDS", Street: &park, Price: 7950000},
+			{Date: 16440, Address: "61 CHARLEMONT", Street: &charlemont, Price: 55700000, Vat: true},
+		},
+		[]parquetSale{
+			{Date: 16441, Address: "UNKNOWN", Street: nil, Price: -5},
+		},
+	)
+
+	t.Run("all columns", func(t *testing.T) {
+		want := [][]string{
+			{"Date of Sale", "Address", "Street Name", "Price", "VAT Exclusive"},
+			{"01/01/2015", "APT 274, THE PARKLANDS", "the park", "79500.00", "false"},
+			{"05/01/2015", "61 CHARLEMONT", "charlemont", "557000.00", "true"},
+			{"06/01/2015", "UNKNOWN", "", "-0.05", "false"},
+		}
+		if got := readAllParquet(t, data); !reflect.DeepEqual(got, want) {
+			t.Errorf("records = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("projection", func(t *testing.T) {
+		want := [][]string{
+			{"Street Name", "Price"},
+			{"the park", "79500.00"},
+			{"charlemont", "557000.00"},
+			{"", "-0.05"},
+		}
+		if got := readAllParquet(t, data, WithFields("street name", "PRICE")); !reflect.DeepEqual(got, want) {
+			t.Errorf("records = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("optional columns", func(t *testing.T) {
+		want := [][]string{
+			{"Street Name", "Price"},
+			{"the park", "79500.00"},
+			{"charlemont", "557000.00"},
+			{"", "-0.05"},
+		}
+		if got := readAllParquet(t, data, WithFields("street name"), WithOptionalFields("Eircode", "price")); !reflect.DeepEqual(got, want) {
+			t.Errorf("records = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		_, err := NewParquetStream(bytes.NewReader(data), int64(len(data)), WithFields("Eircode"))
+		if !errors.Is(err, errParquetColumnMissing) {
+			t.Errorf("NewParquetStream() error = %v, want %v", err, errParquetColumnMissing)
+		}
+	})
+}
+
+func TestFormatDecimal(t *testing.T) {
+	tests := []struct {
+		unscaled int64
+		scale    int
+		want     string
+	}{
+		{7950000, 2, "79500.00"},
+		{5, 2, "0.05"},
+		{-5, 3, "-0.005"},
+		{12, 0, "12"},
+		{12, -2, "1200"},
+	}
+	for _, tt := range tests {
+		if got := formatDecimal(big.NewInt(tt.unscaled), tt.scale); got != tt.want {
+			t.Errorf("formatDecimal(%d, %d) = %s, want %s", tt.unscaled, tt.scale, got, tt.want)
+		}
+	}
+}
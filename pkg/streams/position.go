@@ -0,0 +1,61 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+// lineCounter remembers where lines start in the text passing through it,
+// so offsets reported by a decoder which reads ahead can be turned into lines and columns
+type lineCounter struct {
+	reader io.Reader
+	read   int64
+	// newlines holds offsets of line feeds which were not passed by a lookup yet
+	newlines  []int64
+	line      int
+	lineStart int64
+}
+
+var _ io.Reader = (*lineCounter)(nil)
+
+// Read implements io.Reader.
+func (l *lineCounter) Read(p []byte) (int, error) {
+	n, err := l.reader.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			l.newlines = append(l.newlines, l.read+int64(i))
+		}
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+// locate returns the 1-based line and column of the offset.
+// Offsets must not decrease between calls, passed line feeds are forgotten.
+func (l *lineCounter) locate(offset int64) (int, int) {
+	for len(l.newlines) > 0 && l.newlines[0] < offset {
+		l.lineStart = l.newlines[0] + 1
+		l.line++
+		l.newlines = l.newlines[1:]
+	}
+	return l.line + 1, int(offset-l.lineStart) + 1
+}
+
+// position builds the position of the offset in the named source
+func (l *lineCounter) position(source string, offset int64) iface.Position {
+	line, column := l.locate(offset)
+	return iface.Position{Source: source, Line: line, Column: column, Offset: offset}
+}
+
+// positionError prefixes a read error with its position.
+// End of input and cancellation are returned as they are, callers compare them directly.
+func positionError(pos iface.Position, err error) error {
+	if err == nil || err == io.EOF || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", pos, err)
+}
@@ -0,0 +1,139 @@
+package streams
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+func TestPositionString(t *testing.T) {
+	tests := []struct {
+		pos  iface.Position
+		want string
+	}{
+		{iface.Position{Source: "a.csv", Line: 3, Column: 4, Offset: 20}, "a.csv:3:4"},
+		{iface.Position{Source: "a.csv", Line: 3}, "a.csv:3"},
+		{iface.Position{Line: 3, Column: 4}, "3:4"},
+		{iface.Position{Source: "a.csv"}, "a.csv"},
+		{iface.Position{Offset: 42}, "offset 42"},
+	}
+	for _, tt := range tests {
+		if got := tt.pos.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestLineCounter(t *testing.T) {
+	l := &lineCounter{reader: strings.NewReader("ab\ncd\n\nef")}
+	buf := make([]byte, 64)
+	if _, err := l.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		offset       int64
+		line, column int
+	}{{0, 1, 1}, {1, 1, 2}, {2, 1, 3}, {3, 2, 1}, {7, 4, 1}, {9, 4, 3}} {
+		line, column := l.locate(tt.offset)
+		if line != tt.line || column != tt.column {
+			t.Errorf("locate(%d) = %d:%d, want %d:%d", tt.offset, line, column, tt.line, tt.column)
+		}
+	}
+}
+
+func TestCsvStreamPosition(t *testing.T) {
+	data := "street,price\nmain,1\n\"multi\nline\",2\noak,\"3\n"
+	s, err := NewCsvStream(strings.NewReader(data), WithName("prices.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := s.ReadCsvRecord(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.(iface.Positioner).Position(); got != (iface.Position{Source: "prices.csv", Line: 2, Column: 1, Offset: 13}) {
+		t.Errorf("Position() = %#v", got)
+	}
+
+	if _, err := s.ReadCsvRecord(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.(iface.FieldPositioner).FieldPosition(1).String(); got != "prices.csv:4:2" {
+		t.Errorf("FieldPosition(1) = %q, want %q", got, "prices.csv:4:2")
+	}
+
+	_, err = s.ReadCsvRecord(ctx)
+	if err == nil || !strings.HasPrefix(err.Error(), "prices.csv:5: ") {
+		t.Errorf("ReadCsvRecord() error = %v, want it prefixed with the position", err)
+	}
+}
+
+func TestJsonStreamPosition(t *testing.T) {
+	s, err := NewJsonStreamWithOptions(strings.NewReader("{\n  \"a\": [1,\n  2 x]}"), WithName("trees.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for range 4 {
+		if _, err := s.ReadJsonToken(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.(iface.Positioner).Position().String(); got != "trees.json:2:9" {
+		t.Errorf("Position() = %q, want %q", got, "trees.json:2:9")
+	}
+	if _, err := s.ReadJsonToken(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ReadJsonToken(ctx)
+	if err == nil || !strings.HasPrefix(err.Error(), "trees.json:3:") {
+		t.Errorf("ReadJsonToken() error = %v, want it prefixed with the position", err)
+	}
+}
+
+func TestJsonStreamTokenStart(t *testing.T) {
+	s, err := NewJsonStreamWithOptions(strings.NewReader("{\"a\" :\n\t\"x\\u00e9y\" , \"b\": [true ,null]}"), WithName("trees.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, want := range []string{"trees.json:1:1", "trees.json:1:2", "trees.json:2:2", "trees.json:2:15", "trees.json:2:20", "trees.json:2:21", "trees.json:2:27", "trees.json:2:31", "trees.json:2:32"} {
+		if _, err := s.ReadJsonToken(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.(iface.Positioner).Position().String(); got != want {
+			t.Errorf("Position() = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestNdjsonStreamPosition(t *testing.T) {
+	// the array is read while the header is sampled
+	_, err := NewNdjsonStream(strings.NewReader("{\"a\":1}\n[]\n"), WithName("prices.ndjson"))
+	if err == nil || !strings.HasPrefix(err.Error(), "prices.ndjson:2:1: ") {
+		t.Errorf("NewNdjsonStream() error = %v, want it prefixed with the position", err)
+	}
+
+	s, err := NewNdjsonStream(strings.NewReader("{\"a\":1,\"b\":2}\n\n  {\"a\":2}\n"), WithName("prices.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, want := range []struct{ object, field string }{
+		{"prices.ndjson:1:1", "prices.ndjson:1:2"},
+		{"prices.ndjson:3:3", "prices.ndjson:3:2"},
+	} {
+		if _, err := s.ReadCsvRecord(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.(iface.Positioner).Position().String(); got != want.object {
+			t.Errorf("Position() = %q, want %q", got, want.object)
+		}
+		if got := s.(iface.FieldPositioner).FieldPosition(1).String(); got != want.field {
+			t.Errorf("FieldPosition(1) = %q, want %q", got, want.field)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package streams
+
+import (
+	"context"
+	"iter"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+var (
+	_ iface.RecordIterator = (*csvReader)(nil)
+	_ iface.RecordIterator = (*fixedWidthReader)(nil)
+	_ iface.RecordIterator = (*mappedCsvReader)(nil)
+	_ iface.RecordIterator = (*multiReader)(nil)
+	_ iface.RecordIterator = (*ndjsonReader)(nil)
+	_ iface.RecordIterator = (*parallelCsvReader)(nil)
+	_ iface.RecordIterator = (*parquetReader)(nil)
+	_ iface.RecordIterator = (*xlsxReader)(nil)
+)
+
+// Records implements RecordIterator.
+func (c *csvReader) Records(ctx context.Context) iter.Seq2[[]string, error] {
+	return iface.ReadRecords(ctx, c.ReadCsvRecord)
+}
+
+// Records implements RecordIterator.
+func (f *fixedWidthReader) Records(ctx context.Context) iter.Seq2[[]string, error] {
+	return iface.ReadRecords(ctx, f.ReadCsvRecord)
+}
+
+// Records implements RecordIterator.
+func (m *mappedCsvReader) Records(ctx context.Context) iter.Seq2[[]string, error] {
+	return iface.ReadRecords(ctx, m.ReadCsvRecord)
+}
+
+// Records implements RecordIterator.
+func (m *multiReader) Records(ctx context.Context) iter.Seq2[[]string, error] {
+	return iface.ReadRecords(ctx, m.ReadCsvRecord)
+}
+
+// Records implements RecordIterator.
+func (n *ndjsonReader) Records(ctx context.Context) iter.Seq2[[]string, error] {
+	return iface.ReadRecords(ctx, n.ReadCsvRecord)
+}
+
+// Records implements RecordIterator.
+func (p *parallelCsvReader) Records(ctx context.Context) iter.Seq2[[]string, error] {
+	return iface.ReadRecords(ctx, p.ReadCsvRecord)
+}
+
+// Records implements RecordIterator.
+func (p *parquetReader) Records(ctx context.Context) iter.Seq2[[]string, error] {
+	return iface.ReadRecords(ctx, p.ReadCsvRecord)
+}
+
+// Records implements RecordIterator.
+func (x *xlsxReader) Records(ctx context.Context) iter.Seq2[[]string, error] {
+	return iface.ReadRecords(ctx, x.ReadCsvRecord)
+}
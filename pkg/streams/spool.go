@@ -0,0 +1,107 @@
+package streams
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// spoolMemoryLimit is the size up to which non-seekable input is buffered in memory,
+// larger input is copied to a temporary file
+const spoolMemoryLimit = 64 << 20
+
+// SpooledReader makes any input readable at arbitrary offsets for the streams which need
+// an io.ReaderAt, e.g. Parquet, XLSX and parallel CSV streams
+type SpooledReader struct {
+	reader io.ReaderAt
+	size   int64
+	// file is the temporary copy of the input, nil when the input is in memory or was seekable
+	file *os.File
+	// tempName is the name of the temporary file when it could not be unlinked while open
+	tempName string
+}
+
+var (
+	_ io.ReaderAt = (*SpooledReader)(nil)
+	_ io.Closer   = (*SpooledReader)(nil)
+)
+
+// Spool returns random access to the reader. Regular files and readers which already
+// support ReadAt and Size, like bytes.Reader, are used in place. Pipes, FIFOs, sockets and
+// other streams are read to the end and buffered, in memory when they are small and
+// in a temporary file otherwise. Close removes the temporary file.
+func Spool(reader io.Reader) (*SpooledReader, error) {
+	return spool(reader, spoolMemoryLimit)
+}
+
+func spool(reader io.Reader, memoryLimit int64) (*SpooledReader, error) {
+	switch r := reader.(type) {
+	case *os.File:
+		if info, err := r.Stat(); err == nil && info.Mode().IsRegular() {
+			return &SpooledReader{reader: r, size: info.Size()}, nil
+		}
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return &SpooledReader{reader: r, size: r.Size()}, nil
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, reader, memoryLimit+1)
+	if errors.Is(err, io.EOF) {
+		return &SpooledReader{reader: bytes.NewReader(buf.Bytes()), size: n}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "streams-spool-*")
+	if err != nil {
+		return nil, err
+	}
+	s := &SpooledReader{reader: file, file: file}
+	// an unlinked file is released even when the process exits without Close
+	if os.Remove(file.Name()) != nil {
+		s.tempName = file.Name()
+	}
+	if _, err := buf.WriteTo(file); err != nil {
+		s.Close()
+		return nil, err
+	}
+	rest, err := io.Copy(file, reader)
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.size = n + rest
+	return s, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (s *SpooledReader) ReadAt(p []byte, off int64) (int, error) {
+	return s.reader.ReadAt(p, off)
+}
+
+// Size returns the number of bytes of the input
+func (s *SpooledReader) Size() int64 {
+	return s.size
+}
+
+// Close implements io.Closer, it removes the temporary copy of the input.
+// The spooled input itself is not closed.
+func (s *SpooledReader) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	file := s.file
+	s.file = nil
+	err := file.Close()
+	if s.tempName != "" {
+		if rmErr := os.Remove(s.tempName); err == nil {
+			err = rmErr
+		}
+	}
+	return err
+}
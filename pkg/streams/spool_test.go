@@ -0,0 +1,106 @@
+package streams
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// onlyReader hides every method but Read, like a pipe
+type onlyReader struct{ io.Reader }
+
+func TestSpool(t *testing.T) {
+	data := strings.Repeat("0123456789", 100)
+	readAt := func(t *testing.T, s *SpooledReader) {
+		t.Helper()
+		if s.Size() != int64(len(data)) {
+			t.Fatalf("Size() = %d, want %d", s.Size(), len(data))
+		}
+		got, err := io.ReadAll(io.NewSectionReader(s, 0, s.Size()))
+		if err != nil || string(got) != data {
+			t.Errorf("read %d bytes, error = %v", len(got), err)
+		}
+	}
+
+	t.Run("in place", func(t *testing.T) {
+		r := strings.NewReader(data)
+		s, err := Spool(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.reader != io.ReaderAt(r) {
+			t.Errorf("reader was copied")
+		}
+		readAt(t, s)
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		s, err := spool(onlyReader{strings.NewReader(data)}, int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.file != nil {
+			t.Errorf("input of the memory limit size was copied to %s", s.file.Name())
+		}
+		readAt(t, s)
+	})
+
+	t.Run("temporary file", func(t *testing.T) {
+		s, err := spool(onlyReader{strings.NewReader(data)}, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.file == nil {
+			t.Fatal("input over the memory limit was not copied to a file")
+		}
+		name := s.file.Name()
+		readAt(t, s)
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Errorf("temporary file %s was not removed: %v", name, err)
+		}
+	})
+
+	t.Run("pipe", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			io.Copy(w, bytes.NewReader([]byte(data)))
+			w.Close()
+		}()
+		defer r.Close()
+		s, err := Spool(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		readAt(t, s)
+	})
+}
+
+func TestSpoolRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.csv")
+	data := "street,price\nmain,1\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	s, err := Spool(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.file != nil || s.Size() != int64(len(data)) {
+		t.Errorf("regular file was not used in place")
+	}
+}
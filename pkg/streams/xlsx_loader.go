@@ -0,0 +1,456 @@
+package streams
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	iface "propertytreeanalyzer/pkg/api/streams"
+)
+
+const (
+	xlsxWorkbook      = "xl/workbook.xml"
+	xlsxWorkbookRels  = "xl/_rels/workbook.xml.rels"
+	xlsxSharedStrings = "xl/sharedStrings.xml"
+	xlsxStyles        = "xl/styles.xml"
+	// xlsxDateLayout matches the "Date of Sale (dd/mm/yyyy)" column of the CSV register
+	xlsxDateLayout = "02/01/2006"
+)
+
+var (
+	errXlsxSheetMissing = errors.New("xlsx sheet not found")
+	errXlsxNoHeader     = errors.New("xlsx sheet has no header row")
+	errXlsxBadCellRef   = errors.New("xlsx cell reference is invalid")
+
+	// day zero of the 1900 and 1904 date systems, the 1900 one absorbs the 1900 leap year bug
+	xlsxEpoch1900 = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	xlsxEpoch1904 = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// numberFormat tells how a numeric cell is displayed
+type numberFormat struct {
+	date     bool
+	decimals int // -1 keeps the stored value as is
+}
+
+// xlsxReader exposes the rows of one worksheet as CSV records
+type xlsxReader struct {
+	sheet   io.ReadCloser
+	decoder *xml.Decoder
+	strings []string
+	formats []numberFormat // by cell style index
+	epoch   time.Time
+	header  []string
+	// nextRow is the 1-based number of the row following the last returned one
+	nextRow int
+	// name and row locate the last returned record, row is its sheet row number
+	name string
+	row  int
+}
+
+var (
+	_ iface.CsvStream       = (*xlsxReader)(nil)
+	_ iface.Positioner      = (*xlsxReader)(nil)
+	_ iface.FieldPositioner = (*xlsxReader)(nil)
+)
+
+// NewXlsxStream creates a CSV stream from a worksheet of an Office Open XML workbook.
+// WithSheet selects the worksheet (the first one by default) and WithHeaderRow the row
+// holding the column names (the first non-empty row by default), rows above it are skipped.
+// Shared and inline strings are resolved, numbers keep the decimals of their currency or
+// number format, date formatted cells are rendered as dd/mm/yyyy and empty cells as empty strings.
+func NewXlsxStream(reader io.ReaderAt, size int64, opts ...Option) (iface.CsvStream, error) {
+	cfg, err := newStreamConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	sheetPath, date1904, err := xlsxFindSheet(archive, cfg.sheet)
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxReader{epoch: xlsxEpoch1900, nextRow: 1, name: cfg.name}
+	if date1904 {
+		x.epoch = xlsxEpoch1904
+	}
+	if x.strings, err = xlsxSharedStringTable(archive); err != nil {
+		return nil, err
+	}
+	if x.formats, err = xlsxNumberFormats(archive); err != nil {
+		return nil, err
+	}
+
+	if x.sheet, err = archive.Open(sheetPath); err != nil {
+		return nil, err
+	}
+	x.decoder = xml.NewDecoder(x.sheet)
+
+	for {
+		rowNum, header, err := x.readRow()
+		if errors.Is(err, io.EOF) {
+			x.sheet.Close()
+			return nil, errXlsxNoHeader
+		}
+		if err != nil {
+			x.sheet.Close()
+			return nil, err
+		}
+		if rowNum < cfg.headerRow || isEmptyRecord(header) {
+			continue
+		}
+		x.header, x.row = header, rowNum
+		return x, nil
+	}
+}
+
+// xlsxFindSheet resolves the worksheet by name or 1-based position in the workbook
+func xlsxFindSheet(archive *zip.Reader, sheet string) (string, bool, error) {
+	var workbook struct {
+		Properties struct {
+			Date1904 bool `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xlsxDecode(archive, xlsxWorkbook, true, &workbook); err != nil {
+		return "", false, err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xlsxDecode(archive, xlsxWorkbookRels, true, &rels); err != nil {
+		return "", false, err
+	}
+
+	id := ""
+	for i, s := range workbook.Sheets {
+		if sheet == "" || strings.EqualFold(s.Name, sheet) || strconv.Itoa(i+1) == sheet {
+			id = s.ID
+			break
+		}
+	}
+	for _, rel := range rels.Relationships {
+		if id != "" && rel.ID == id {
+			target := rel.Target
+			if strings.HasPrefix(target, "/") {
+				return strings.TrimPrefix(target, "/"), workbook.Properties.Date1904, nil
+			}
+			return path.Join("xl", target), workbook.Properties.Date1904, nil
+		}
+	}
+	return "", false, fmt.Errorf("%w: %q", errXlsxSheetMissing, sheet)
+}
+
+// xlsxSharedStringTable loads the shared strings, rich text runs are concatenated
+func xlsxSharedStringTable(archive *zip.Reader) ([]string, error) {
+	var sst struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := xlsxDecode(archive, xlsxSharedStrings, false, &sst); err != nil {
+		return nil, err
+	}
+	table := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		if len(item.Runs) == 0 {
+			table[i] = item.Text
+			continue
+		}
+		var sb strings.Builder
+		for _, run := range item.Runs {
+			sb.WriteString(run.Text)
+		}
+		table[i] = sb.String()
+	}
+	return table, nil
+}
+
+// xlsxNumberFormats maps every cell style to the way its numbers are displayed
+func xlsxNumberFormats(archive *zip.Reader) ([]numberFormat, error) {
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := xlsxDecode(archive, xlsxStyles, false, &styles); err != nil {
+		return nil, err
+	}
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+	formats := make([]numberFormat, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		formats[i] = parseNumberFormat(xf.NumFmtID, custom[xf.NumFmtID])
+	}
+	return formats, nil
+}
+
+// parseNumberFormat interprets a built-in format id or a custom format code
+func parseNumberFormat(id int, code string) numberFormat {
+	switch {
+	case id >= 14 && id <= 22, id >= 45 && id <= 47:
+		return numberFormat{date: true}
+	case id == 2, id == 4, id == 7, id == 8, id == 10, id == 11, id == 39, id == 40, id == 43, id == 44:
+		return numberFormat{decimals: 2}
+	case code == "":
+		return numberFormat{decimals: -1}
+	}
+
+	// only the first (positive) section matters, quoted and escaped text is not part of the pattern
+	section, _, _ := strings.Cut(code, ";")
+	var pattern strings.Builder
+	inQuotes, inBrackets := false, false
+	for i := 0; i < len(section); i++ {
+		switch c := section[i]; {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case c == '[':
+			inBrackets = true
+		case c == ']':
+			inBrackets = false
+		case inBrackets:
+		case c == '\\' || c == '_' || c == '*':
+			i++
+		default:
+			pattern.WriteByte(c)
+		}
+	}
+	p := strings.ToLower(pattern.String())
+	if strings.ContainsAny(p, "dy") || (strings.Contains(p, "m") && !strings.ContainsAny(p, "0#?")) {
+		return numberFormat{date: true}
+	}
+	if _, frac, ok := strings.Cut(p, "."); ok {
+		return numberFormat{decimals: strings.Count(frac, "0") + strings.Count(frac, "#")}
+	}
+	if strings.ContainsAny(p, "0#") {
+		return numberFormat{decimals: 0}
+	}
+	return numberFormat{decimals: -1}
+}
+
+// xlsxDecode unmarshals an archive member, optional members may be missing
+func xlsxDecode(archive *zip.Reader, name string, required bool, v any) error {
+	member, err := archive.Open(name)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("xlsx member %s: %w", name, err)
+	}
+	defer member.Close()
+	return xml.NewDecoder(member).Decode(v)
+}
+
+// xlsxCell is a <c> element of a worksheet
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  int    `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"is"`
+}
+
+// readRow returns the number and the cells of the next <row> element
+func (x *xlsxReader) readRow() (int, []string, error) {
+	for {
+		tok, err := x.decoder.Token()
+		if err != nil {
+			return 0, nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		rowNum := x.nextRow
+		for _, a := range start.Attr {
+			if a.Name.Local == "r" {
+				if n, err := strconv.Atoi(a.Value); err == nil {
+					rowNum = n
+				}
+			}
+		}
+		x.nextRow = rowNum + 1
+
+		var record []string
+		for {
+			tok, err := x.decoder.Token()
+			if err != nil {
+				return 0, nil, err
+			}
+			if end, ok := tok.(xml.EndElement); ok && end.Name.Local == "row" {
+				return rowNum, record, nil
+			}
+			start, ok := tok.(xml.StartElement)
+			if !ok || start.Name.Local != "c" {
+				continue
+			}
+			var cell xlsxCell
+			if err := x.decoder.DecodeElement(&cell, &start); err != nil {
+				return 0, nil, err
+			}
+			col := len(record)
+			if cell.Ref != "" {
+				if col, err = xlsxColumnIndex(cell.Ref); err != nil {
+					return 0, nil, err
+				}
+			}
+			for len(record) <= col {
+				record = append(record, "")
+			}
+			record[col] = x.cellText(&cell)
+		}
+	}
+}
+
+// cellText renders a cell value the way the parsers expect it
+func (x *xlsxReader) cellText(cell *xlsxCell) string {
+	switch cell.Type {
+	case "s":
+		if i, err := strconv.Atoi(cell.Value); err == nil && i >= 0 && i < len(x.strings) {
+			return x.strings[i]
+		}
+		return ""
+	case "inlineStr":
+		if len(cell.Inline.Runs) == 0 {
+			return cell.Inline.Text
+		}
+		var sb strings.Builder
+		for _, run := range cell.Inline.Runs {
+			sb.WriteString(run.Text)
+		}
+		return sb.String()
+	case "b":
+		return strconv.FormatBool(cell.Value == "1")
+	case "", "n":
+		return x.numberText(cell.Value, cell.Style)
+	default:
+		// formula strings, errors and ISO dates are kept as stored
+		return cell.Value
+	}
+}
+
+func (x *xlsxReader) numberText(value string, style int) string {
+	if value == "" || style < 0 || style >= len(x.formats) {
+		return value
+	}
+	f := x.formats[style]
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	switch {
+	case f.date:
+		days := time.Duration(number * float64(24*time.Hour))
+		return x.epoch.Add(days).Format(xlsxDateLayout)
+	case f.decimals >= 0:
+		return strconv.FormatFloat(number, 'f', f.decimals, 64)
+	default:
+		return value
+	}
+}
+
+// xlsxColumnIndex converts the letters of a cell reference like "AB12" into a 0-based column
+func xlsxColumnIndex(ref string) (int, error) {
+	col := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		col = col*26 + int(ref[i]-'A'+1)
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("%w: %q", errXlsxBadCellRef, ref)
+	}
+	return col - 1, nil
+}
+
+func isEmptyRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadCsvRecord implements CsvStream.
+func (x *xlsxReader) ReadCsvRecord(ctx context.Context) ([]string, error) {
+	if x == nil || x.decoder == nil {
+		return nil, io.EOF
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		rowNum, record, err := x.readRow()
+		if errors.Is(err, io.EOF) {
+			x.sheet.Close()
+			x.decoder = nil
+			return nil, io.EOF
+		}
+		if err != nil {
+			// the row being decoded has already advanced nextRow
+			return nil, positionError(iface.Position{Source: x.name, Line: x.nextRow - 1}, err)
+		}
+		if isEmptyRecord(record) {
+			continue
+		}
+		x.row = rowNum
+		for len(record) < len(x.header) {
+			record = append(record, "")
+		}
+		return record, nil
+	}
+}
+
+// Position implements Positioner. The line is the sheet row number.
+func (x *xlsxReader) Position() iface.Position {
+	return iface.Position{Source: x.name, Line: x.row}
+}
+
+// FieldPosition implements FieldPositioner. The column is the 1-based sheet column, A being 1.
+func (x *xlsxReader) FieldPosition(field int) iface.Position {
+	return iface.Position{Source: x.name, Line: x.row, Column: field + 1}
+}
+
+// GetHeader implements CsvStream.
+func (x *xlsxReader) GetHeader() []string {
+	if x == nil {
+		return nil
+	}
+	return x.header
+}
@@ -0,0 +1,149 @@
+package streams
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func buildXlsx(t *testing.T, members map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range members {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+var testWorkbook = map[string]string{
+	"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="Sales" sheetId="2" r:id="rId2"/></sheets>
+</workbook>`,
+	"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/>
+</Relationships>`,
+	"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>Date of Sale</t></si><si><t>Street Name</t></si><si><t>Price</t></si>
+<si><r><t>the </t></r><r><t>park</t></r></si><si><t>Sales extract</t></si>
+</sst>`,
+	"xl/styles.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="[$€-1809]#,##0.00;[Red]\-[$€-1809]#,##0.00"/></numFmts>
+<cellXfs count="3"><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/></cellXfs>
+</styleSheet>`,
+	"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="inlineStr"><is><t>nothing here</t></is></c></row>
+</sheetData></worksheet>`,
+	"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>4</v></c></row>
+<row r="3"><c r="A3" t="s"><v>0</v></c><c r="B3" t="s"><v>1</v></c><c r="C3" t="s"><v>2</v></c></row>
+<row r="4"><c r="A4" s="1"><v>42005</v></c><c r="B4" t="s"><v>3</v></c><c r="C4" s="2"><v>79500</v></c></row>
+<row r="5"></row>
+<row r="6"><c r="B6" t="inlineStr"><is><t>charlemont</t></is></c><c r="C6" s="2"><v>557000.5</v></c></row>
+</sheetData></worksheet>`,
+}
+
+func TestXlsxStream(t *testing.T) {
+	data := buildXlsx(t, testWorkbook)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want [][]string
+	}{
+		{
+			name: "sheet by name with header row",
+			opts: []Option{WithSheet("sales"), WithHeaderRow(3)},
+			want: [][]string{
+				{"Date of Sale", "Street Name", "Price"},
+				{"01/01/2015", "the park", "79500.00"},
+				{"", "charlemont", "557000.50"},
+			},
+		},
+		{
+			name: "sheet by position, first non-empty row is the header",
+			opts: []Option{WithSheet("2")},
+			want: [][]string{
+				{"Sales extract"},
+				{"Date of Sale", "Street Name", "Price"},
+				{"01/01/2015", "the park", "79500.00"},
+				{"", "charlemont", "557000.50"},
+			},
+		},
+		{
+			name: "first sheet by default",
+			want: [][]string{{"nothing here"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewXlsxStream(bytes.NewReader(data), int64(len(data)), tt.opts...)
+			if err != nil {
+				t.Fatalf("NewXlsxStream() error = %v", err)
+			}
+			got := [][]string{s.GetHeader()}
+			for {
+				rec, err := s.ReadCsvRecord(context.Background())
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ReadCsvRecord() error = %v", err)
+				}
+				got = append(got, rec)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestXlsxStreamMissingSheet(t *testing.T) {
+	data := buildXlsx(t, testWorkbook)
+	_, err := NewXlsxStream(bytes.NewReader(data), int64(len(data)), WithSheet("Trees"))
+	if !errors.Is(err, errXlsxSheetMissing) {
+		t.Errorf("NewXlsxStream() error = %v, want %v", err, errXlsxSheetMissing)
+	}
+}
+
+func TestParseNumberFormat(t *testing.T) {
+	tests := []struct {
+		id   int
+		code string
+		want numberFormat
+	}{
+		{id: 0, want: numberFormat{decimals: -1}},
+		{id: 14, want: numberFormat{date: true}},
+		{id: 4, want: numberFormat{decimals: 2}},
+		{id: 164, code: `"€"#,##0`, want: numberFormat{decimals: 0}},
+		{id: 165, code: `dd/mm/yyyy`, want: numberFormat{date: true}},
+		{id: 166, code: `#,##0.000_);(#,##0.000)`, want: numberFormat{decimals: 3}},
+	}
+	for _, tt := range tests {
+		if got := parseNumberFormat(tt.id, tt.code); got != tt.want {
+			t.Errorf("parseNumberFormat(%d, %q) = %+v, want %+v", tt.id, tt.code, got, tt.want)
+		}
+	}
+}